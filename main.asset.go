@@ -14,7 +14,6 @@ import (
 	"github.com/Masterminds/sprig"
 	"github.com/cheggaaa/pb"
 	apiLib "github.com/hornbill/goApiLib"
-	"github.com/jmoiron/sqlx"
 )
 
 // Asset count for caching
@@ -31,7 +30,7 @@ func getAssetCount(assetType assetTypesStruct, espXmlmc *apiLib.XmlmcInstStruct)
 	hornbillImport.SetParam("queryType", "count")
 	hornbillImport.CloseElement("queryOptions")
 
-	RespBody, err := hornbillImport.Invoke("data", "queryExec")
+	RespBody, err := invokeRetry(hornbillImport, "data", "queryExec", nil)
 
 	var JSONResp xmlmcCountResponse
 	if err != nil {
@@ -47,7 +46,7 @@ func getAssetCount(assetType assetTypesStruct, espXmlmc *apiLib.XmlmcInstStruct)
 	}
 
 	//-- return Count
-	assetCount, err = strconv.ParseUint(JSONResp.Params.RowData.Row[0].Count, 10, 16)
+	assetCount, err = strconv.ParseUint(JSONResp.Params.RowData.Row[0].Count, 10, 64)
 	return
 }
 
@@ -100,7 +99,7 @@ func getAssetRecords(assetCount uint64, assetType assetTypesStruct, espXmlmc *ap
 		}
 		hornbillImport.CloseElement("queryOptions")
 
-		RespBody, err = hornbillImport.Invoke("data", "queryExec")
+		RespBody, err = invokeRetry(hornbillImport, "data", "queryExec", nil)
 		var JSONResp xmlmcAssetRecordsResponse
 		if err != nil {
 			logger(4, "Error returning page of asset records: "+err.Error(), false, true)
@@ -150,7 +149,7 @@ func getAssetClass(confAssetType string) (assetClass string, assetType int) {
 	espXmlmc.CloseElement("searchFilter")
 	espXmlmc.SetParam("maxResults", "1")
 	var XMLSTRING = espXmlmc.GetParam()
-	XMLGetMeta, xmlmcErr := espXmlmc.Invoke("data", "entityBrowseRecords2")
+	XMLGetMeta, xmlmcErr := invokeRetry(espXmlmc, "data", "entityBrowseRecords2", nil)
 	if xmlmcErr != nil {
 		logger(4, "API Call failed when retrieving Asset Class:"+xmlmcErr.Error(), false, true)
 		logger(1, "API XML: "+XMLSTRING, false, true)
@@ -184,7 +183,7 @@ func addInPolicy(assetId string, espXmlmc *apiLib.XmlmcInstStruct, buffer *bytes
 
 	var XMLSTRING = espXmlmc.GetParam()
 	if !configDryRun {
-		XMLSiteSearch, xmlmcErr := espXmlmc.Invoke("data", "entityAddRecord")
+		XMLSiteSearch, xmlmcErr := invokeRetry(espXmlmc, "data", "entityAddRecord", buffer)
 		if xmlmcErr != nil {
 			buffer.WriteString(loggerGen(4, "API Call failed when trying to bring asset in policy: "+xmlmcErr.Error()))
 			buffer.WriteString(loggerGen(1, "API XML: "+XMLSTRING))
@@ -226,7 +225,7 @@ func removeInPolicy(inPolicyId string, espXmlmc *apiLib.XmlmcInstStruct, buffer
 
 	var XMLSTRING = espXmlmc.GetParam()
 	if !configDryRun {
-		XMLSiteSearch, xmlmcErr := espXmlmc.Invoke("data", "entityDeleteRecord")
+		XMLSiteSearch, xmlmcErr := invokeRetry(espXmlmc, "data", "entityDeleteRecord", buffer)
 		if xmlmcErr != nil {
 			buffer.WriteString(loggerGen(4, "API Call failed when trying to remove the asset out of policy: "+xmlmcErr.Error()))
 			buffer.WriteString(loggerGen(1, "API XML: "+XMLSTRING))
@@ -256,12 +255,33 @@ func removeInPolicy(inPolicyId string, espXmlmc *apiLib.XmlmcInstStruct, buffer
 	return boolReturn
 }
 
-// processAssets -- Processes Assets from Asset Map
+// getSourceAssetID -- returns the asset ID of a source record, from the AssetIdentifier SourceColumn or its template,
+// or from the source itself where it implements AssetIdentifierSource
+func getSourceAssetID(assetSource Source, assetType assetTypesStruct, assetRecord map[string]interface{}) string {
+	if identifierSource, ok := assetSource.(AssetIdentifierSource); ok {
+		return identifierSource.AssetIdentifier(assetRecord, assetType)
+	}
+	assetIDIdent := fmt.Sprintf("%v", assetType.AssetIdentifier.SourceColumn)
+	if regexTemplate.MatchString(assetIDIdent) {
+		//Get the asset ID for the current record - using Go templates
+		t := template.New(assetIDIdent).Funcs(TemplateFilters).Funcs(sprig.FuncMap())
+		tmpl, _ := t.Parse(assetIDIdent)
+		buf := bytes.NewBufferString("")
+		tmpl.Execute(buf, assetRecord)
+		return buf.String()
+	}
+	//Get the asset ID for the current record - Not templated
+	return iToS(assetRecord[assetIDIdent])
+}
+
+// processAssets -- Processes Assets as they are read from the source asset stream, returning the asset IDs read and their high watermark
 // --If asset already exists on the instance, update
 // --If asset doesn't exist, create
-func processAssets(arrAssets map[string]map[string]interface{}, assetsCache map[string]map[string]interface{}, assetType assetTypesStruct) {
-	logger(3, "Processing "+strconv.Itoa(len(arrAssets))+" of "+assetType.AssetType+" Type Assets...", true, true)
-	bar := pb.StartNew(len(arrAssets))
+func processAssets(stream *assetStreamStruct, assetsCache map[string]map[string]interface{}, assetType assetTypesStruct, assetSource Source) processedAssetsStruct {
+	logger(3, "Processing "+assetType.AssetType+" Type Assets as they are read from the source...", true, true)
+	//The number of source records isn't known up front, so the bar counts the assets processed
+	bar := pb.StartNew(0)
+	processed := processedAssetsStruct{sourceIDs: make(map[string]int)}
 
 	//Get the identity of the AssetID field from the config
 	assetIDIdent := fmt.Sprintf("%v", assetType.AssetIdentifier.SourceColumn)
@@ -269,41 +289,68 @@ func processAssets(arrAssets map[string]map[string]interface{}, assetsCache map[
 	blnContractConnect := supplierManagerInstalled() && assetType.AssetIdentifier.SourceContractColumn != ""
 	blnSupplierConnect := supplierManagerInstalled() && assetType.AssetIdentifier.SourceSupplierColumn != ""
 
-	//-- Loop each asset
+	//-- Loop each asset as it is read from the source
 	maxGoroutinesGuard := make(chan struct{}, configMaxRoutines)
-	for _, assetRecord := range arrAssets {
-		maxGoroutinesGuard <- struct{}{}
-		worker.Add(1)
+	for {
+		sourceAsset, ok := stream.next()
+		if !ok {
+			break
+		}
 		var (
 			assetIDInstance string
 			hbRecordHash    string
 			hbSIRecordHash  string
 			dbRecordHash    string
 			assetForHash    []map[string]interface{}
+			assetRecord     = sourceAsset.Record
 			assetMap        = assetRecord
 			assetID         string
 		)
 
-		matched := regexTemplate.MatchString(assetIDIdent)
-		if matched {
-			//Get the asset ID for the current record - using Go templates
-			t := template.New(assetIDIdent).Funcs(TemplateFilters).Funcs(sprig.FuncMap())
-			tmpl, _ := t.Parse(assetIDIdent)
-			buf := bytes.NewBufferString("")
-			tmpl.Execute(buf, assetMap)
+		assetID = getSourceAssetID(assetSource, assetType, assetMap)
 
-			if buf != nil {
-				assetID = buf.String()
-			}
-		} else {
-			//Get the asset ID for the current record - Not templated
-			assetID = iToS(assetMap[assetIDIdent])
+		//Records are processed as they are read, so with the first policy later records with the same identifier
+		//are skipped. Other policies have already resolved duplicates
+		if position, ok := processed.sourceIDs[assetID]; ok {
+			logDuplicate(assetType, assetID, sourceAssetStruct{Position: position}, sourceAsset, "record "+strconv.Itoa(sourceAsset.Position)+" skipped")
+			continue
+		}
+		processed.sourceIDs[assetID] = sourceAsset.Position
+		if assetType.WatermarkColumn != "" {
+			processed.watermark = maxWatermark(watermarkValue(assetRecord[assetType.WatermarkColumn]), processed.watermark)
 		}
 
+		//Stop dispatching assets once the import is cancelled, letting those in progress complete
+		select {
+		case maxGoroutinesGuard <- struct{}{}:
+		case <-importCtx.Done():
+		}
+		if importCancelled() {
+			break
+		}
+		worker.Add(1)
+
 		dbRecordHash = Hash(append(assetForHash, assetRecord))
 
+		//Skip assets completed by an interrupted run against the same source record
+		if configResume {
+			if entry, ok := journalCompletedAsset(assetType.AssetType, dbRecordHash, assetID); ok {
+				debugLog(nil, "Asset already "+entry.Outcome+" in journal, skipping:", assetID, entry.HornbillAssetID)
+				incCounter(assetType.AssetType, counterAssetsResumed)
+				addReportAsset(reportAssetStruct{AssetType: assetType.AssetType, SourceID: assetID, HornbillAssetID: entry.HornbillAssetID, Action: entry.Outcome})
+				mutexBar.Lock()
+				bar.Increment()
+				mutexBar.Unlock()
+				worker.Done()
+				<-maxGoroutinesGuard
+				continue
+			}
+		}
+
+		startAsset(assetType.AssetType, assetID)
 		go func() {
 			defer worker.Done()
+			defer finishAsset(assetType.AssetType, assetID)
 			mutexBar.Lock()
 			bar.Increment()
 			mutexBar.Unlock()
@@ -313,22 +360,15 @@ func processAssets(arrAssets map[string]map[string]interface{}, assetsCache map[
 				boolUpdateSI        = false
 				boolCreate          = false
 				boolActioned        = false
+				outcome             = assetOutcomeSkipped
 				err                 error
-				db                  *sqlx.DB
 				buffer              bytes.Buffer
 				softwareRecords     map[string]map[string]interface{}
 				softwareRecordsHash string
+				hbRecord            map[string]interface{}
+				report              = reportAssetStruct{AssetType: assetType.AssetType, SourceID: assetID}
 			)
 
-			if configDB {
-				//One DB connection per worker
-				db, err = makeDBConnection()
-				if err != nil {
-					logger(4, "[DATABASE] "+err.Error(), false, true)
-				}
-				defer db.Close()
-			}
-
 			//One XMLMC connection per worker
 			espXmlmc := apiLib.NewXmlmcInstance(importConf.InstanceID)
 			espXmlmc.SetAPIKey(importConf.APIKey)
@@ -338,6 +378,7 @@ func processAssets(arrAssets map[string]map[string]interface{}, assetsCache map[
 
 			if asset, ok := assetsCache[assetID]; ok {
 				//Asset exists
+				hbRecord = asset
 				assetIDInstance = fmt.Sprintf("%v", asset["h_pk_asset_id"])
 				debugLog(&buffer, "Asset ID Instance"+assetIDInstance)
 				debugLog(&buffer, "Asset Class: "+assetType.Class)
@@ -350,31 +391,25 @@ func processAssets(arrAssets map[string]map[string]interface{}, assetsCache map[
 					if hbRecordHash != dbRecordHash || configForceUpdates {
 						boolUpdate = true
 					} else {
-						mutexCounters.Lock()
-						counters.updateSkipped++
-						mutexCounters.Unlock()
+						incCounter(assetType.AssetType, counterUpdateSkipped)
 					}
 
-					if !configCSV {
+					if inventorySource, ok := assetSource.(SoftwareInventorySource); ok {
 						//Software inventory records
 						hbSIRecordHash = fmt.Sprintf("%v", asset["h_dsc_sw_fingerprint"])
-						softwareRecords, softwareRecordsHash, err = getSoftwareRecords(assetMap, assetType, espXmlmc, db, &buffer)
+						softwareRecords, softwareRecordsHash, err = inventorySource.GetSoftwareInventory(assetMap, assetType, &buffer)
 						debugLog(&buffer, "Hornbill Asset Software Inventory Record Hash: "+hbSIRecordHash)
 						debugLog(&buffer, "Database Asset Software Inventory Record Hash: "+softwareRecordsHash)
 
 						if err != nil {
 							buffer.WriteString(loggerGen(4, err.Error()))
-							mutexCounters.Lock()
-							counters.softwareCreateFailed++
-							mutexCounters.Unlock()
+							incCounter(assetType.AssetType, counterSoftwareCreateFailed)
 						}
 						if len(softwareRecords) > 0 && hbSIRecordHash != softwareRecordsHash {
 							boolUpdateSI = true
 						} else {
 							buffer.WriteString(loggerGen(1, "Asset match found, no software inventory updates required"))
-							mutexCounters.Lock()
-							counters.softwareSkipped++
-							mutexCounters.Unlock()
+							incCounter(assetType.AssetType, counterSoftwareSkipped)
 						}
 					}
 
@@ -386,29 +421,23 @@ func processAssets(arrAssets map[string]map[string]interface{}, assetsCache map[
 					if hbRecordHash != dbRecordHash || configForceUpdates {
 						boolUpdate = true
 					} else {
-						mutexCounters.Lock()
-						counters.updateSkipped++
-						mutexCounters.Unlock()
+						incCounter(assetType.AssetType, counterUpdateSkipped)
 					}
 
-					if !configCSV {
+					if inventorySource, ok := assetSource.(SoftwareInventorySource); ok {
 						//Software inventory records
 						hbSIRecordHash = fmt.Sprintf("%v", asset["h_dsc_sw_fingerprint"])
 						debugLog(&buffer, "Hornbill Asset Software Inventory Record Hash: "+hbSIRecordHash)
-						softwareRecords, softwareRecordsHash, err = getSoftwareRecords(assetMap, assetType, espXmlmc, db, &buffer)
+						softwareRecords, softwareRecordsHash, err = inventorySource.GetSoftwareInventory(assetMap, assetType, &buffer)
 						if err != nil {
 							buffer.WriteString(loggerGen(4, err.Error()))
-							mutexCounters.Lock()
-							counters.softwareCreateFailed++
-							mutexCounters.Unlock()
+							incCounter(assetType.AssetType, counterSoftwareCreateFailed)
 						}
 						if len(softwareRecords) > 0 && hbSIRecordHash != softwareRecordsHash {
 							boolUpdateSI = true
 						} else {
 							buffer.WriteString(loggerGen(1, "Asset match found, no software inventory updates required"))
-							mutexCounters.Lock()
-							counters.softwareSkipped++
-							mutexCounters.Unlock()
+							incCounter(assetType.AssetType, counterSoftwareSkipped)
 						}
 					}
 
@@ -419,9 +448,7 @@ func processAssets(arrAssets map[string]map[string]interface{}, assetsCache map[
 					if hbRecordHash != dbRecordHash || configForceUpdates {
 						boolUpdate = true
 					} else {
-						mutexCounters.Lock()
-						counters.updateSkipped++
-						mutexCounters.Unlock()
+						incCounter(assetType.AssetType, counterUpdateSkipped)
 					}
 				default:
 					//basic
@@ -432,17 +459,13 @@ func processAssets(arrAssets map[string]map[string]interface{}, assetsCache map[
 					hbRecordHash = fmt.Sprintf("%v", asset["h_dsc_fingerprint"])
 					debugLog(&buffer, "Database Asset Record Hash: "+dbRecordHash)
 					debugLog(&buffer, "Hornbill Asset Record Hash: "+hbRecordHash)
-					if hbRecordHash != dbRecordHash || configForceUpdates {
-						boolUpdate = true
-					} else {
-						mutexCounters.Lock()
-						counters.updateSkipped++
-						mutexCounters.Unlock()
-					}
+					//These classes are always compared against the cached record, whatever their fingerprint.
+					//updateAsset counts the asset as skipped when nothing differs
 					boolUpdate = true
 				}
 				if !boolUpdate {
 					buffer.WriteString(loggerGen(1, "Asset match found, no details require updating"))
+					checkReferences(assetType, assetID, assetMap, &buffer)
 				}
 			} else {
 				debugLog(&buffer, "Asset Match Doesn't Exist - Create")
@@ -457,7 +480,8 @@ func processAssets(arrAssets map[string]map[string]interface{}, assetsCache map[
 						usedBy = iToS(assetMap["h_used_by_name"])
 					}
 					buffer.WriteString(loggerGen(1, "Update Asset: "+assetID))
-					boolActioned = updateAsset(assetType, assetMap, assetIDInstance, assetID, usedBy, espXmlmc, &buffer)
+					outcome = updateAsset(assetType, assetMap, hbRecord, assetIDInstance, assetID, usedBy, espXmlmc, &buffer)
+					boolActioned = outcome != assetOutcomeFailed
 					if strings.ToLower(assetType.InPolicyField) == "yes" {
 						inPolicyId, ok := assetMap["h_pk_confiteminpolicyid"]
 						var strIPID string
@@ -485,7 +509,12 @@ func processAssets(arrAssets map[string]map[string]interface{}, assetsCache map[
 			if boolCreate {
 				if assetType.OperationType == "" || strings.ToLower(assetType.OperationType) == "both" || strings.ToLower(assetType.OperationType) == "create" {
 					buffer.WriteString(loggerGen(1, "Create Asset: "+assetID))
-					assetIDInstance, boolActioned = createAsset(assetType, assetMap, assetID, espXmlmc, db, &buffer)
+					assetIDInstance, report.SoftwareAdded, boolActioned = createAsset(assetType, assetMap, assetID, espXmlmc, assetSource, &buffer)
+					if boolActioned {
+						outcome = assetOutcomeCreated
+					} else if !configDryRun {
+						outcome = assetOutcomeFailed
+					}
 					if strings.ToLower(assetType.InPolicyField) == "yes" {
 						addInPolicy(assetIDInstance, espXmlmc, &buffer)
 					}
@@ -495,7 +524,7 @@ func processAssets(arrAssets map[string]map[string]interface{}, assetsCache map[
 				}
 			}
 			if boolUpdateSI && !configDryRun {
-				err = updateAssetSI(assetIDInstance, softwareRecords, softwareRecordsHash, assetType, espXmlmc, &buffer)
+				report.SoftwareAdded, report.SoftwareRemoved, err = updateAssetSI(assetIDInstance, softwareRecords, softwareRecordsHash, assetType, espXmlmc, &buffer)
 				if err != nil {
 					buffer.WriteString(loggerGen(4, err.Error()))
 				}
@@ -507,37 +536,58 @@ func processAssets(arrAssets map[string]map[string]interface{}, assetsCache map[
 					supplierID := iToS(assetMap[assetType.AssetIdentifier.SourceSupplierColumn])
 					if supplierID != "" {
 
-						err = addSupplierToAsset(assetIDInstance, supplierID, espXmlmc, &buffer)
+						exists, err := addSupplierToAsset(assetType, assetIDInstance, supplierID, espXmlmc, &buffer)
 						if err != nil {
-							counters.suppliersAssociatedFailed++
+							incCounter(assetType.AssetType, counterSuppliersAssociatedFailed)
 							buffer.WriteString(loggerGen(4, "Unable to associate Supplier ["+supplierID+"] to Asset ["+assetID+"]: "+err.Error()))
+							report.Supplier = associationFailed
+						} else if exists {
+							report.Supplier = associationExists
+						} else {
+							report.Supplier = associationSuccess
 						}
 					}
 				}
 				if blnContractConnect {
 					contractID := iToS(assetMap[assetType.AssetIdentifier.SourceContractColumn])
 					if contractID != "" {
-						err = addSupplierContractToAsset(assetIDInstance, contractID, espXmlmc, &buffer)
+						exists, err := addSupplierContractToAsset(assetType, assetIDInstance, contractID, espXmlmc, &buffer)
 						if err != nil {
-							counters.supplierContractsAssociatedFailed++
+							incCounter(assetType.AssetType, counterSupplierContractsAssociatedFailed)
 							buffer.WriteString(loggerGen(4, "Unable to associate Contract ["+contractID+"] to Asset ["+assetID+"]: "+err.Error()))
+							report.SupplierContract = associationFailed
+						} else if exists {
+							report.SupplierContract = associationExists
+						} else {
+							report.SupplierContract = associationSuccess
 						}
 					}
 				}
 			}
+			writeJournal(assetType.AssetType, dbRecordHash, assetID, outcome, assetIDInstance)
+			report.HornbillAssetID = assetIDInstance
+			report.Action = outcome
+			logged := redactRecordValues(buffer.String(), assetMap, hbRecord)
+			report.Error = bufferErrors(logged)
+			addReportAsset(report)
+
 			mutexBuffer.Lock()
-			loggerWriteBuffer(buffer.String())
+			loggerWriteAssetBuffer(logged, report)
 			mutexBuffer.Unlock()
 			buffer.Reset()
 			<-maxGoroutinesGuard
 		}()
 	}
-	worker.Wait()
+	if !waitWorkers() || importCancelled() {
+		bar.FinishPrint(assetType.AssetType + " Asset Type Processing Cancelled!")
+		return processed
+	}
 	bar.FinishPrint(assetType.AssetType + " Asset Type Processing Complete!")
+	return processed
 }
 
-// createAsset -- Creates Asset record from the passed through map data
-func createAsset(assetType assetTypesStruct, u map[string]interface{}, strNewAssetID string, espXmlmc *apiLib.XmlmcInstStruct, db *sqlx.DB, buffer *bytes.Buffer) (string, bool) {
+// createAsset -- Creates Asset record from the passed through map data, returning the new asset ID and the number of software records added
+func createAsset(assetType assetTypesStruct, u map[string]interface{}, strNewAssetID string, espXmlmc *apiLib.XmlmcInstStruct, assetSource Source, buffer *bytes.Buffer) (string, int, bool) {
 
 	var (
 		newAssetHash        string
@@ -548,16 +598,12 @@ func createAsset(assetType assetTypesStruct, u map[string]interface{}, strNewAss
 
 	var assetForHash []map[string]interface{}
 	newAssetHash = Hash(append(assetForHash, u))
-	if assetType.Class == "computer" || assetType.Class == "mobileDevice" {
-
-		if !configCSV {
-			softwareRecords, softwareRecordsHash, err = getSoftwareRecords(u, assetType, espXmlmc, db, buffer)
-			if err != nil {
-				buffer.WriteString(loggerGen(4, err.Error()))
-				mutexCounters.Lock()
-				counters.softwareCreateFailed++
-				mutexCounters.Unlock()
-			}
+	inventorySource, hasInventory := assetSource.(SoftwareInventorySource)
+	if hasInventory && (assetType.Class == "computer" || assetType.Class == "mobileDevice") {
+		softwareRecords, softwareRecordsHash, err = inventorySource.GetSoftwareInventory(u, assetType, buffer)
+		if err != nil {
+			buffer.WriteString(loggerGen(4, err.Error()))
+			incCounter(assetType.AssetType, counterSoftwareCreateFailed)
 		}
 	}
 
@@ -571,13 +617,15 @@ func createAsset(assetType assetTypesStruct, u map[string]interface{}, strNewAss
 	departmentID, departmentName := getGroupID(u, "department", buffer)
 
 	//Get Owned By details
-	_, ownedByURN, ownedByName := getUserID(u, "h_owned_by", buffer, false)
+	ownedByID, ownedByURN, ownedByName := getUserID(u, "h_owned_by", buffer, false)
 
 	//Get Used By details
-	_, usedByURN, usedByName := getUserID(u, "h_used_by", buffer, false)
+	usedByID, usedByURN, usedByName := getUserID(u, "h_used_by", buffer, false)
 
 	//Get Last Logged On details
-	_, lastLoggedOnByURN, _ := getUserID(u, "h_last_logged_on_user", buffer, true)
+	lastLoggedOnByID, lastLoggedOnByURN, _ := getUserID(u, "h_last_logged_on_user", buffer, true)
+	trackReferences(assetType, strNewAssetID, siteName, siteID, companyName, companyID, departmentName, departmentID,
+		ownedByID, ownedByURN, usedByID, usedByURN, lastLoggedOnByID, lastLoggedOnByURN)
 
 	//Get/Set params from map stored against FieldMapping
 	espXmlmc.SetParam("application", appServiceManager)
@@ -687,11 +735,12 @@ func createAsset(assetType assetTypesStruct, u map[string]interface{}, strNewAss
 	if !configDryRun {
 		var XMLSTRING = espXmlmc.GetParam()
 		debugLog(buffer, "Asset Create XML:", XMLSTRING)
-		XMLCreate, xmlmcErr := espXmlmc.Invoke("data", "entityAddRecord")
+		XMLCreate, xmlmcErr := invokeRetry(espXmlmc, "data", "entityAddRecord", buffer)
 		if xmlmcErr != nil {
+			incCounter(assetType.AssetType, counterCreateFailed)
 			buffer.WriteString(loggerGen(4, "Error running entityAddRecord API for createAsset: "+xmlmcErr.Error()))
 			buffer.WriteString(loggerGen(1, "API Call XML: "+XMLSTRING))
-			return "", false
+			return "", 0, false
 		}
 
 		var xmlRespon xmlmcUpdateResponse
@@ -699,24 +748,18 @@ func createAsset(assetType assetTypesStruct, u map[string]interface{}, strNewAss
 
 		err := xml.Unmarshal([]byte(XMLCreate), &xmlRespon)
 		if err != nil {
-			mutexCounters.Lock()
-			counters.createFailed++
-			mutexCounters.Unlock()
+			incCounter(assetType.AssetType, counterCreateFailed)
 			buffer.WriteString(loggerGen(4, "Unable to read response from Hornbill instance from entityAddRecord API for createAsset:"+err.Error()))
 			buffer.WriteString(loggerGen(1, "API Call XML: "+XMLSTRING))
-			return "", false
+			return "", 0, false
 		}
 
 		if xmlRespon.MethodResult != "ok" {
 			buffer.WriteString(loggerGen(4, "Unable to add asset: "+xmlRespon.State.Error))
 			buffer.WriteString(loggerGen(1, "API Call XML: "+XMLSTRING))
-			mutexCounters.Lock()
-			counters.createFailed++
-			mutexCounters.Unlock()
+			incCounter(assetType.AssetType, counterCreateFailed)
 		} else {
-			mutexCounters.Lock()
-			counters.created++
-			mutexCounters.Unlock()
+			incCounter(assetType.AssetType, counterCreated)
 			assetID := xmlRespon.UpdatedCols.AssetPK
 			mutexAssets.Lock()
 			assets[strNewAssetID] = assetID
@@ -734,11 +777,11 @@ func createAsset(assetType assetTypesStruct, u map[string]interface{}, strNewAss
 			espXmlmc.CloseElement("primaryEntityData")
 			XMLSTRING = espXmlmc.GetParam()
 
-			XMLUpdate, xmlmcErr := espXmlmc.Invoke("data", "entityUpdateRecord")
+			XMLUpdate, xmlmcErr := invokeRetry(espXmlmc, "data", "entityUpdateRecord", buffer)
 			if xmlmcErr != nil {
 				buffer.WriteString(loggerGen(3, "API Call failed when Updating Asset URN:"+xmlmcErr.Error()))
 				buffer.WriteString(loggerGen(1, "API Call XML: "+XMLSTRING))
-				return assetID, true
+				return assetID, 0, true
 			}
 
 			var xmlRespon xmlmcResponse
@@ -746,50 +789,44 @@ func createAsset(assetType assetTypesStruct, u map[string]interface{}, strNewAss
 			if err != nil {
 				buffer.WriteString(loggerGen(3, "Unable to read response from Hornbill instance when Updating Asset URN:"+err.Error()))
 				buffer.WriteString(loggerGen(1, "API Call XML: "+XMLSTRING))
-				return assetID, true
+				return assetID, 0, true
 			}
 
 			if xmlRespon.MethodResult != "ok" {
 				buffer.WriteString(loggerGen(3, "Unable to update Asset URN: "+xmlRespon.State.Error))
 				buffer.WriteString(loggerGen(1, "API Call XML: "+XMLSTRING))
-				return assetID, true
+				return assetID, 0, true
 			}
 			buffer.WriteString(loggerGen(1, "Asset URN updated successfully: "+assetID))
 
+			softwareAdded := 0
 			if (assetType.Class == "computer" || assetType.Class == "mobile") && len(softwareRecords) > 0 {
-				buildSoftwareInventory(softwareRecords, assetType, assetID, espXmlmc, buffer)
+				softwareAdded = buildSoftwareInventory(softwareRecords, assetType, assetID, espXmlmc, buffer)
 			}
 
-			return assetID, true
+			return assetID, softwareAdded, true
 		}
 	} else {
 		//-- DEBUG XML TO LOG FILE
 		var XMLSTRING = espXmlmc.GetParam()
 		buffer.WriteString(loggerGen(1, "API Create XML: "+XMLSTRING))
-		mutexCounters.Lock()
-		counters.createSkipped++
-		mutexCounters.Unlock()
+		incCounter(assetType.AssetType, counterCreateSkipped)
 		espXmlmc.ClearParam()
 	}
-	return "", false
+	return "", 0, false
 }
 
-// updateAsset -- Updates Asset record from the passed through map data and asset ID
-// func updateAsset(assetType assetTypesStruct, u map[string]interface{}, strAssetID, strNewAssetID, usedBy string, espXmlmc *apiLib.XmlmcInstStruct, db *sqlx.DB, buffer *bytes.Buffer) bool {
-func updateAsset(assetType assetTypesStruct, u map[string]interface{}, strAssetID, strNewAssetID, usedBy string, espXmlmc *apiLib.XmlmcInstStruct, buffer *bytes.Buffer) bool {
+// updateAsset -- Updates Asset record from the passed through map data and asset ID.
+// Only the columns that differ from the cached Hornbill record (hbRecord) are sent. Returns the asset outcome:
+// updated, skipped when neither the primary nor the extended record had changes to send, or failed
+func updateAsset(assetType assetTypesStruct, u map[string]interface{}, hbRecord map[string]interface{}, strAssetID, strNewAssetID, usedBy string, espXmlmc *apiLib.XmlmcInstStruct, buffer *bytes.Buffer) string {
 
 	var (
 		newAssetHash      string
 		boolRecordUpdated = false
+		diff              = newAssetDiff(hbRecord, buffer)
 	)
 
-	//Shared clearAttrib array
-	var nilAttrib []apiLib.ParamAttribStruct
-	attrib := apiLib.ParamAttribStruct{}
-	attrib.Name = "nil"
-	attrib.Value = "true"
-	nilAttrib = append(nilAttrib, attrib)
-
 	var assetForHash []map[string]interface{}
 	newAssetHash = Hash(append(assetForHash, u))
 
@@ -809,7 +846,9 @@ func updateAsset(assetType assetTypesStruct, u map[string]interface{}, strAssetI
 	usedByID, usedByURN, usedByName := getUserID(u, "h_used_by", buffer, false)
 
 	//Get Last Logged On details
-	_, lastLoggedOnByURN, _ := getUserID(u, "h_last_logged_on_user", buffer, true)
+	lastLoggedOnByID, lastLoggedOnByURN, _ := getUserID(u, "h_last_logged_on_user", buffer, true)
+	trackReferences(assetType, strNewAssetID, siteName, siteID, companyName, companyID, departmentName, departmentID,
+		ownedByID, ownedByURN, usedByID, usedByURN, lastLoggedOnByID, lastLoggedOnByURN)
 
 	//Get/Set params from map stored against FieldMapping
 	espXmlmc.SetParam("application", appServiceManager)
@@ -818,7 +857,7 @@ func updateAsset(assetType assetTypesStruct, u map[string]interface{}, strAssetI
 	espXmlmc.OpenElement("primaryEntityData")
 	espXmlmc.OpenElement("record")
 	espXmlmc.SetParam("h_pk_asset_id", strAssetID)
-	espXmlmc.SetParam("h_asset_urn", "urn:sys:entity:com.hornbill.servicemanager:Asset:"+strAssetID)
+	diff.set(espXmlmc, "h_asset_urn", "urn:sys:entity:com.hornbill.servicemanager:Asset:"+strAssetID)
 	debugLog(buffer, "Asset Field Mapping")
 
 	//Get asset field mapping
@@ -844,63 +883,53 @@ func updateAsset(assetType assetTypesStruct, u map[string]interface{}, strAssetI
 
 		if k == "h_used_by" && usedByID != "" {
 			if usedByID == "__clear__" {
-				espXmlmc.SetParamAttr("h_used_by", "", nilAttrib)
-				espXmlmc.SetParamAttr("h_used_by_name", "", nilAttrib)
+				diff.clear(espXmlmc, "h_used_by", "h_used_by_name")
 			} else if usedByName != "" && usedByURN != "" {
-				espXmlmc.SetParam("h_used_by", usedByURN)
-				espXmlmc.SetParam("h_used_by_name", usedByName)
+				diff.set(espXmlmc, "h_used_by", usedByURN, "h_used_by_name", usedByName)
 			}
 			continue
 		}
 
 		if k == "h_owned_by" && ownedByID != "" {
 			if ownedByID == "__clear__" {
-				espXmlmc.SetParamAttr("h_owned_by", "", nilAttrib)
-				espXmlmc.SetParamAttr("h_owned_by_name", "", nilAttrib)
+				diff.clear(espXmlmc, "h_owned_by", "h_owned_by_name")
 			} else if ownedByName != "" && ownedByURN != "" {
-				espXmlmc.SetParam("h_owned_by", ownedByURN)
-				espXmlmc.SetParam("h_owned_by_name", ownedByName)
+				diff.set(espXmlmc, "h_owned_by", ownedByURN, "h_owned_by_name", ownedByName)
 			}
 			continue
 		}
 
 		if k == "h_site" && siteName != "" {
 			if siteName == "__clear__" {
-				espXmlmc.SetParamAttr("h_site", "", nilAttrib)
-				espXmlmc.SetParamAttr("h_site_id", "", nilAttrib)
+				diff.clear(espXmlmc, "h_site", "h_site_id")
 			} else if siteID != 0 {
-				espXmlmc.SetParam("h_site", siteName)
-				espXmlmc.SetParam("h_site_id", strconv.Itoa(siteID))
+				diff.set(espXmlmc, "h_site", siteName, "h_site_id", strconv.Itoa(siteID))
 			}
 			continue
 		}
 
 		if k == "h_company_name" && companyName != "" {
 			if companyName == "__clear__" {
-				espXmlmc.SetParamAttr("h_company_name", "", nilAttrib)
-				espXmlmc.SetParamAttr("h_company_id", "", nilAttrib)
+				diff.clear(espXmlmc, "h_company_name", "h_company_id")
 			} else if companyID != "" {
-				espXmlmc.SetParam("h_company_name", companyName)
-				espXmlmc.SetParam("h_company_id", companyID)
+				diff.set(espXmlmc, "h_company_name", companyName, "h_company_id", companyID)
 			}
 			continue
 		}
 
 		if k == "h_department_name" && departmentName != "" {
 			if departmentName == "__clear__" {
-				espXmlmc.SetParamAttr("h_department_name", "", nilAttrib)
-				espXmlmc.SetParamAttr("h_department_id", "", nilAttrib)
+				diff.clear(espXmlmc, "h_department_name", "h_department_id")
 			} else if departmentID != "" {
-				espXmlmc.SetParam("h_department_name", departmentName)
-				espXmlmc.SetParam("h_department_id", departmentID)
+				diff.set(espXmlmc, "h_department_name", departmentName, "h_department_id", departmentID)
 			}
 			continue
 		}
 
 		if value == "__clear__" {
-			espXmlmc.SetParamAttr(k, "", nilAttrib)
+			diff.clear(espXmlmc, k)
 		} else if strMapping != "" && value != "" {
-			espXmlmc.SetParam(k, value)
+			diff.set(espXmlmc, k, value)
 		}
 	}
 
@@ -908,54 +937,54 @@ func updateAsset(assetType assetTypesStruct, u map[string]interface{}, strAssetI
 	espXmlmc.CloseElement("primaryEntityData")
 
 	var XMLSTRING = espXmlmc.GetParam()
+	primaryChanges := diff.changes
 
 	if !configDryRun {
 		debugLog(buffer, "Asset Update XML:", XMLSTRING)
 
-		XMLUpdate, xmlmcErr := espXmlmc.Invoke("data", "entityUpdateRecord")
-		if xmlmcErr != nil {
-			buffer.WriteString(loggerGen(4, "API Call failed when Updating Asset:"+xmlmcErr.Error()))
-			buffer.WriteString(loggerGen(1, "API Call XML: "+XMLSTRING))
-			mutexCounters.Lock()
-			counters.updateFailed++
-			mutexCounters.Unlock()
-			return false
-		}
+		if primaryChanges == 0 {
+			//Nothing differs from the cached record, so there's no need to call Hornbill
+			espXmlmc.ClearParam()
+			buffer.WriteString(loggerGen(1, "Asset record has no changes to update: "+strAssetID))
+		} else {
+			XMLUpdate, xmlmcErr := invokeRetry(espXmlmc, "data", "entityUpdateRecord", buffer)
+			if xmlmcErr != nil {
+				buffer.WriteString(loggerGen(4, "API Call failed when Updating Asset:"+xmlmcErr.Error()))
+				buffer.WriteString(loggerGen(1, "API Call XML: "+XMLSTRING))
+				incCounter(assetType.AssetType, counterUpdateFailed)
+				return assetOutcomeFailed
+			}
 
-		var xmlRespon xmlmcUpdateResponse
+			var xmlRespon xmlmcUpdateResponse
 
-		err := xml.Unmarshal([]byte(XMLUpdate), &xmlRespon)
-		if err != nil {
-			buffer.WriteString(loggerGen(4, "Unable to read response from Hornbill instance when Updating Asset:"+err.Error()))
-			buffer.WriteString(loggerGen(1, "API Call XML: "+XMLUpdate))
-			mutexCounters.Lock()
-			counters.updateFailed++
-			mutexCounters.Unlock()
-			return false
-		}
+			err := xml.Unmarshal([]byte(XMLUpdate), &xmlRespon)
+			if err != nil {
+				buffer.WriteString(loggerGen(4, "Unable to read response from Hornbill instance when Updating Asset:"+err.Error()))
+				buffer.WriteString(loggerGen(1, "API Call XML: "+XMLUpdate))
+				incCounter(assetType.AssetType, counterUpdateFailed)
+				return assetOutcomeFailed
+			}
 
-		if xmlRespon.MethodResult != "ok" && xmlRespon.State.Error != "There are no values to update" && !strings.Contains(xmlRespon.State.Error, "Superfluous entity record update detected") {
-			buffer.WriteString(loggerGen(4, "Unable to Update Asset: "+xmlRespon.State.Error))
-			buffer.WriteString(loggerGen(1, "API Call XML: "+XMLUpdate))
-			mutexCounters.Lock()
-			counters.updateFailed++
-			mutexCounters.Unlock()
-			return false
-		}
+			if xmlRespon.MethodResult != "ok" && xmlRespon.State.Error != "There are no values to update" && !strings.Contains(xmlRespon.State.Error, "Superfluous entity record update detected") {
+				buffer.WriteString(loggerGen(4, "Unable to Update Asset: "+xmlRespon.State.Error))
+				buffer.WriteString(loggerGen(1, "API Call XML: "+XMLUpdate))
+				incCounter(assetType.AssetType, counterUpdateFailed)
+				return assetOutcomeFailed
+			}
 
-		if xmlRespon.MethodResult != "ok" && (xmlRespon.State.Error == "There are no values to update" || strings.Contains(xmlRespon.State.Error, "Superfluous entity record update detected")) {
-			buffer.WriteString(loggerGen(1, "API Call XML: "+XMLUpdate))
-			mutexCounters.Lock()
-			counters.updateSkipped++
-			mutexCounters.Unlock()
-		}
+			if xmlRespon.MethodResult != "ok" && (xmlRespon.State.Error == "There are no values to update" || strings.Contains(xmlRespon.State.Error, "Superfluous entity record update detected")) {
+				buffer.WriteString(loggerGen(1, "API Call XML: "+XMLUpdate))
+			}
 
-		if xmlRespon.MethodResult == "ok" {
-			buffer.WriteString(loggerGen(1, "Asset record updated successfully: "+strAssetID))
-			boolRecordUpdated = true
+			if xmlRespon.MethodResult == "ok" {
+				buffer.WriteString(loggerGen(1, "Asset record updated successfully: "+strAssetID))
+				boolRecordUpdated = true
+			}
 		}
 
+		mutexAssets.Lock()
 		assets[strNewAssetID] = strAssetID
+		mutexAssets.Unlock()
 
 		//-- now process extended record data
 		espXmlmc.SetParam("application", appServiceManager)
@@ -971,15 +1000,16 @@ func updateAsset(assetType assetTypesStruct, u map[string]interface{}, strAssetI
 		espXmlmc.SetParam("entityAction", "update")
 		espXmlmc.OpenElement("record")
 		espXmlmc.SetParam("h_pk_asset_id", strAssetID)
+		diff.changes = 0
 		switch assetType.Class {
 		case "basic":
-			espXmlmc.SetParam("h_dsc_fingerprint", newAssetHash)
+			diff.set(espXmlmc, "h_dsc_fingerprint", newAssetHash)
 		case "computer":
-			espXmlmc.SetParam("h_dsc_cf_fingerprint", newAssetHash)
+			diff.set(espXmlmc, "h_dsc_cf_fingerprint", newAssetHash)
 		case "printer":
-			espXmlmc.SetParam("h_dsc_cf_fingerprint", newAssetHash)
+			diff.set(espXmlmc, "h_dsc_cf_fingerprint", newAssetHash)
 		case "software":
-			espXmlmc.SetParam("h_dsc_fingerprint", newAssetHash)
+			diff.set(espXmlmc, "h_dsc_fingerprint", newAssetHash)
 		}
 		debugLog(buffer, "Asset Field Mapping")
 
@@ -989,61 +1019,60 @@ func updateAsset(assetType assetTypesStruct, u map[string]interface{}, strAssetI
 			value := getFieldValue(k, strMapping, u, buffer)
 			debugLog(buffer, k, ":", strMapping, ":", value)
 			if value == "__clear__" {
-				espXmlmc.SetParamAttr(k, "", nilAttrib)
+				diff.clear(espXmlmc, k)
 			} else {
 				if k == "h_last_logged_on_user" && lastLoggedOnByURN != "" {
-					espXmlmc.SetParam("h_last_logged_on_user", lastLoggedOnByURN)
+					diff.set(espXmlmc, "h_last_logged_on_user", lastLoggedOnByURN)
 				}
 				if k != "h_last_logged_on_user" && strMapping != "" && value != "" {
-					espXmlmc.SetParam(k, value)
+					diff.set(espXmlmc, k, value)
 				}
 			}
 		}
 
 		espXmlmc.CloseElement("record")
 		espXmlmc.CloseElement("relatedEntityData")
-		XMLMCRequest := espXmlmc.GetParam()
-		debugLog(buffer, "Asset Extended Update XML:", XMLMCRequest)
+		if diff.changes == 0 {
+			//Neither the fingerprint nor the mapped columns differ from the cached record, so there's no need to call Hornbill
+			espXmlmc.ClearParam()
+			buffer.WriteString(loggerGen(1, "Asset extended record has no changes to update: "+strAssetID))
+			incCounter(assetType.AssetType, counterUpdateRelatedSkipped)
+		} else {
+			XMLMCRequest := espXmlmc.GetParam()
+			debugLog(buffer, "Asset Extended Update XML:", XMLMCRequest)
 
-		XMLUpdateExt, xmlmcErrExt := espXmlmc.Invoke("data", "entityUpdateRecord")
-		if xmlmcErrExt != nil {
-			buffer.WriteString(loggerGen(4, "API Call failed when Updating Asset Extended Details:"+xmlmcErrExt.Error()))
-			buffer.WriteString(loggerGen(1, "API Call XML: "+XMLSTRING))
-			mutexCounters.Lock()
-			counters.updateFailed++
-			mutexCounters.Unlock()
-			return false
-		}
-		var xmlResponExt xmlmcUpdateResponse
+			XMLUpdateExt, xmlmcErrExt := invokeRetry(espXmlmc, "data", "entityUpdateRecord", buffer)
+			if xmlmcErrExt != nil {
+				buffer.WriteString(loggerGen(4, "API Call failed when Updating Asset Extended Details:"+xmlmcErrExt.Error()))
+				buffer.WriteString(loggerGen(1, "API Call XML: "+XMLSTRING))
+				incCounter(assetType.AssetType, counterUpdateFailed)
+				return assetOutcomeFailed
+			}
+			var xmlResponExt xmlmcUpdateResponse
 
-		err = xml.Unmarshal([]byte(XMLUpdateExt), &xmlResponExt)
-		if err != nil {
-			buffer.WriteString(loggerGen(4, "Unable to read response from Hornbill instance when Updating Asset Extended Details:"+err.Error()))
-			buffer.WriteString(loggerGen(1, "API Call XML: "+XMLUpdateExt))
-			mutexCounters.Lock()
-			counters.updateRelatedFailed++
-			mutexCounters.Unlock()
-			return false
-		}
+			err := xml.Unmarshal([]byte(XMLUpdateExt), &xmlResponExt)
+			if err != nil {
+				buffer.WriteString(loggerGen(4, "Unable to read response from Hornbill instance when Updating Asset Extended Details:"+err.Error()))
+				buffer.WriteString(loggerGen(1, "API Call XML: "+XMLUpdateExt))
+				incCounter(assetType.AssetType, counterUpdateRelatedFailed)
+				return assetOutcomeFailed
+			}
 
-		if xmlResponExt.MethodResult != "ok" && xmlResponExt.State.Error != "There are no values to update" && !strings.Contains(xmlResponExt.State.Error, "Superfluous entity record update detected") {
-			buffer.WriteString(loggerGen(4, "Unable to Update Asset Extended Details: "+xmlResponExt.State.Error))
-			buffer.WriteString(loggerGen(1, "API Call XML: "+XMLUpdateExt))
-			mutexCounters.Lock()
-			counters.updateRelatedFailed++
-			mutexCounters.Unlock()
-			return false
-		}
+			if xmlResponExt.MethodResult != "ok" && xmlResponExt.State.Error != "There are no values to update" && !strings.Contains(xmlResponExt.State.Error, "Superfluous entity record update detected") {
+				buffer.WriteString(loggerGen(4, "Unable to Update Asset Extended Details: "+xmlResponExt.State.Error))
+				buffer.WriteString(loggerGen(1, "API Call XML: "+XMLUpdateExt))
+				incCounter(assetType.AssetType, counterUpdateRelatedFailed)
+				return assetOutcomeFailed
+			}
 
-		if xmlResponExt.MethodResult != "ok" && (xmlResponExt.State.Error == "There are no values to update" || strings.Contains(xmlResponExt.State.Error, "Superfluous entity record update detected")) {
-			mutexCounters.Lock()
-			counters.updateRelatedSkipped++
-			mutexCounters.Unlock()
-		}
+			if xmlResponExt.MethodResult != "ok" && (xmlResponExt.State.Error == "There are no values to update" || strings.Contains(xmlResponExt.State.Error, "Superfluous entity record update detected")) {
+				incCounter(assetType.AssetType, counterUpdateRelatedSkipped)
+			}
 
-		if xmlResponExt.MethodResult == "ok" {
-			boolRecordUpdated = true
-			buffer.WriteString(loggerGen(1, "Asset record extended details updated successfully: "+strAssetID))
+			if xmlResponExt.MethodResult == "ok" {
+				boolRecordUpdated = true
+				buffer.WriteString(loggerGen(1, "Asset record extended details updated successfully: "+strAssetID))
+			}
 		}
 
 		if boolRecordUpdated {
@@ -1061,7 +1090,7 @@ func updateAsset(assetType assetTypesStruct, u map[string]interface{}, strAssetI
 			var XMLSTRING = espXmlmc.GetParam()
 			debugLog(buffer, "Asset Update LAST UPDATE XML:", XMLSTRING)
 
-			XMLUpdate, xmlmcErr := espXmlmc.Invoke("data", "entityUpdateRecord")
+			XMLUpdate, xmlmcErr := invokeRetry(espXmlmc, "data", "entityUpdateRecord", buffer)
 			if xmlmcErr != nil {
 				buffer.WriteString(loggerGen(4, "API Call failed when setting Last Updated values:"+xmlmcErr.Error()))
 				buffer.WriteString(loggerGen(1, "API Call XML: "+XMLSTRING))
@@ -1080,18 +1109,15 @@ func updateAsset(assetType assetTypesStruct, u map[string]interface{}, strAssetI
 					}
 				}
 			}
-			mutexCounters.Lock()
-			counters.updated++
-			mutexCounters.Unlock()
+			incCounter(assetType.AssetType, counterUpdated)
+			return assetOutcomeUpdated
 		}
-
-	} else {
-		//-- Inc Counter
-		mutexCounters.Lock()
-		counters.updateSkipped++
-		mutexCounters.Unlock()
-		buffer.WriteString(loggerGen(1, "Asset Update XML "+XMLSTRING))
-		espXmlmc.ClearParam()
+		incCounter(assetType.AssetType, counterUpdateSkipped)
+		return assetOutcomeSkipped
 	}
-	return true
+	//-- Inc Counter
+	incCounter(assetType.AssetType, counterUpdateSkipped)
+	buffer.WriteString(loggerGen(1, "Asset Update XML "+XMLSTRING))
+	espXmlmc.ClearParam()
+	return assetOutcomeSkipped
 }
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+
+	apiLib "github.com/hornbill/goApiLib"
+)
+
+// assetDiffStruct -- Compares the values of an asset update against the cached Hornbill asset record,
+// so that only the columns that differ are sent. Each difference is written to the debug log
+type assetDiffStruct struct {
+	cached  map[string]interface{}
+	buffer  *bytes.Buffer
+	changes int
+}
+
+func newAssetDiff(cached map[string]interface{}, buffer *bytes.Buffer) *assetDiffStruct {
+	return &assetDiffStruct{cached: cached, buffer: buffer}
+}
+
+// differs -- returns true if the value differs from the cached record. Columns not held in the cache can't
+// be compared, so are treated as different, as is everything when -forceupdates is set
+func (d *assetDiffStruct) differs(column, value string) bool {
+	if configForceUpdates {
+		return true
+	}
+	cachedValue, ok := d.cached[column]
+	if !ok {
+		return true
+	}
+	return iToS(cachedValue) != value
+}
+
+func (d *assetDiffStruct) logDiff(column, value string) {
+	before := "<not cached>"
+	if cachedValue, ok := d.cached[column]; ok {
+		before = iToS(cachedValue)
+	}
+	debugLog(d.buffer, "Field Diff:", column, ": ["+before+"] -> ["+value+"]")
+}
+
+// set -- takes column/value pairs, and adds them all to the update if any of them differ from the cached record,
+// so that related columns (such as h_site and h_site_id) are always sent together
+func (d *assetDiffStruct) set(espXmlmc *apiLib.XmlmcInstStruct, columnValues ...string) {
+	changed := false
+	for i := 0; i+1 < len(columnValues); i += 2 {
+		if d.differs(columnValues[i], columnValues[i+1]) {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return
+	}
+	for i := 0; i+1 < len(columnValues); i += 2 {
+		d.logDiff(columnValues[i], columnValues[i+1])
+		espXmlmc.SetParam(columnValues[i], columnValues[i+1])
+	}
+	d.changes++
+}
+
+// clear -- adds the columns to the update as nil, if any of them hold a value in the cached record
+func (d *assetDiffStruct) clear(espXmlmc *apiLib.XmlmcInstStruct, columns ...string) {
+	changed := false
+	for _, column := range columns {
+		if d.differs(column, "") {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return
+	}
+	nilAttrib := []apiLib.ParamAttribStruct{{Name: "nil", Value: "true"}}
+	for _, column := range columns {
+		d.logDiff(column, "")
+		espXmlmc.SetParamAttr(column, "", nilAttrib)
+	}
+	d.changes++
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	apiLib "github.com/hornbill/goApiLib"
+)
+
+// Record types created by AutoCreate
+const (
+	autoCreateSite       = "site"
+	autoCreateCompany    = "company"
+	autoCreateDepartment = "department"
+)
+
+// autoCreatedStruct -- a site or group created (or, in dry run, that would have been created) by AutoCreate
+type autoCreatedStruct struct {
+	Type   string `json:"Type"`
+	Name   string `json:"Name"`
+	ID     string `json:"ID,omitempty"`
+	Parent string `json:"Parent,omitempty"`
+	Error  string `json:"Error,omitempty"`
+}
+
+// xmlmcSiteAddResponse -- response from adding a site record
+type xmlmcSiteAddResponse struct {
+	MethodResult string         `xml:"status,attr"`
+	SiteID       int            `xml:"params>primaryEntityData>record>h_id"`
+	State        stateStructXML `xml:"state"`
+}
+
+var (
+	autoCreated         []autoCreatedStruct
+	autoCreateAttempted = make(map[string]bool)
+	// autoCreateLocks holds a lock per record, so workers creating different records don't wait on each other
+	autoCreateLocks = make(map[string]*sync.Mutex)
+	mutexAutoCreate = &sync.Mutex{}
+)
+
+// lockAutoCreate -- locks the record with the key, so it is only created once, returning the function to unlock it.
+// The lock is held while the record is created, where mutexAutoCreate is only held to update the shared state
+func lockAutoCreate(key string) func() {
+	mutexAutoCreate.Lock()
+	lock, ok := autoCreateLocks[key]
+	if !ok {
+		lock = &sync.Mutex{}
+		autoCreateLocks[key] = lock
+	}
+	mutexAutoCreate.Unlock()
+	lock.Lock()
+	return lock.Unlock
+}
+
+// firstAutoCreateAttempt -- returns true the first time it is called for a record key
+func firstAutoCreateAttempt(key string) bool {
+	mutexAutoCreate.Lock()
+	defer mutexAutoCreate.Unlock()
+	if autoCreateAttempted[key] {
+		return false
+	}
+	autoCreateAttempted[key] = true
+	return true
+}
+
+// addAutoCreated -- records a site or group created, or that failed to be created, for the summary and report
+func addAutoCreated(created autoCreatedStruct) {
+	mutexAutoCreate.Lock()
+	autoCreated = append(autoCreated, created)
+	mutexAutoCreate.Unlock()
+}
+
+// groupTypeName -- returns the Hornbill group type for a cached group type ID
+func groupTypeName(groupTypeID int) string {
+	if groupTypeID == 2 {
+		return autoCreateDepartment
+	}
+	return autoCreateCompany
+}
+
+// autoCreateLabel -- returns the display name of an AutoCreate record type
+func autoCreateLabel(recordType string) string {
+	switch recordType {
+	case autoCreateSite:
+		return "Site"
+	case autoCreateDepartment:
+		return "Department"
+	}
+	return "Company"
+}
+
+// groupIDFromName -- returns the ID for a new group, being its name lowercased with anything but letters and digits removed
+func groupIDFromName(groupName string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, groupName)
+}
+
+// autoCreateSiteRecord -- creates a site missing from the cache, adds it to the cache and returns its ID.
+// In dry run the site is only listed as one that would be created
+func autoCreateSiteRecord(siteName string, buffer *bytes.Buffer) (siteID int) {
+	key := autoCreateSite + ":" + cacheKey(siteName)
+	unlock := lockAutoCreate(key)
+	defer unlock()
+
+	//Another worker may have created the site since the cache was checked
+	if site, found := Sites.get(siteName); found {
+		return site.SiteID
+	}
+	if !firstAutoCreateAttempt(key) {
+		return
+	}
+	created := autoCreatedStruct{Type: autoCreateSite, Name: siteName}
+	if configDryRun {
+		buffer.WriteString(loggerGen(3, "[DRYRUN] Site ["+siteName+"] not found in Hornbill, and would be created"))
+		addAutoCreated(created)
+		return
+	}
+
+	espXmlmc := apiLib.NewXmlmcInstance(importConf.InstanceID)
+	espXmlmc.SetAPIKey(importConf.APIKey)
+	espXmlmc.SetParam("application", "com.hornbill.core")
+	espXmlmc.SetParam("entity", "Site")
+	espXmlmc.SetParam("returnModifiedData", "true")
+	espXmlmc.OpenElement("primaryEntityData")
+	espXmlmc.OpenElement("record")
+	espXmlmc.SetParam("h_site_name", siteName)
+	espXmlmc.CloseElement("record")
+	espXmlmc.CloseElement("primaryEntityData")
+
+	err := func() error {
+		XMLCreate, xmlmcErr := invokeRetry(espXmlmc, "data", "entityAddRecord", buffer)
+		if xmlmcErr != nil {
+			return xmlmcErr
+		}
+		var xmlRespon xmlmcSiteAddResponse
+		if err := xml.Unmarshal([]byte(XMLCreate), &xmlRespon); err != nil {
+			return err
+		}
+		if xmlRespon.MethodResult != "ok" {
+			return errors.New(xmlRespon.State.Error)
+		}
+		siteID = xmlRespon.SiteID
+		return nil
+	}()
+	if err != nil {
+		buffer.WriteString(loggerGen(4, "Unable to create Site ["+siteName+"]: "+err.Error()))
+		created.Error = err.Error()
+		addAutoCreated(created)
+		return 0
+	}
+	Sites.add(siteListStruct{SiteName: siteName, SiteID: siteID})
+	created.ID = strconv.Itoa(siteID)
+	addAutoCreated(created)
+	buffer.WriteString(loggerGen(3, "Site ["+siteName+"] not found in Hornbill, created with ID ["+created.ID+"]"))
+	return
+}
+
+// autoCreateGroupRecord -- creates a company or department missing from the cache, under the parent group if one is
+// given, adds it to the cache and returns its ID. In dry run the group is only listed as one that would be created
+func autoCreateGroupRecord(groupTypeID int, groupName string, parent groupListStruct, buffer *bytes.Buffer) (groupID string) {
+	groupType := groupTypeName(groupTypeID)
+	key := groupType + ":" + cacheKey(groupName)
+	unlock := lockAutoCreate(key)
+	defer unlock()
+
+	//Another worker may have created the group since the cache was checked
+	if group, found := Groups.get(groupTypeID, groupName); found {
+		return group.GroupID
+	}
+	if !firstAutoCreateAttempt(key) {
+		return
+	}
+	created := autoCreatedStruct{Type: groupType, Name: groupName, Parent: parent.GroupName}
+	if configDryRun {
+		buffer.WriteString(loggerGen(3, "[DRYRUN] "+autoCreateLabel(groupType)+" ["+groupName+"] not found in Hornbill, and would be created"))
+		addAutoCreated(created)
+		return
+	}
+
+	newGroupID := groupIDFromName(groupName)
+	espXmlmc := apiLib.NewXmlmcInstance(importConf.InstanceID)
+	espXmlmc.SetAPIKey(importConf.APIKey)
+	espXmlmc.SetParam("id", newGroupID)
+	espXmlmc.SetParam("name", groupName)
+	espXmlmc.SetParam("type", groupType)
+	if parent.GroupID != "" {
+		espXmlmc.SetParam("parent", parent.GroupID)
+	}
+
+	err := func() error {
+		if newGroupID == "" {
+			espXmlmc.ClearParam()
+			return errors.New("the name contains no letters or digits to build a group ID from")
+		}
+		XMLCreate, xmlmcErr := invokeRetry(espXmlmc, "admin", "groupCreate", buffer)
+		if xmlmcErr != nil {
+			return xmlmcErr
+		}
+		var xmlRespon xmlmcResponse
+		if err := xml.Unmarshal([]byte(XMLCreate), &xmlRespon); err != nil {
+			return err
+		}
+		if xmlRespon.MethodResult != "ok" {
+			return errors.New(xmlRespon.State.Error)
+		}
+		return nil
+	}()
+	if err != nil {
+		buffer.WriteString(loggerGen(4, "Unable to create "+autoCreateLabel(groupType)+" ["+groupName+"]: "+err.Error()))
+		created.Error = err.Error()
+		addAutoCreated(created)
+		return ""
+	}
+	Groups.add(groupListStruct{GroupName: groupName, GroupType: groupTypeID, GroupID: newGroupID})
+	created.ID = newGroupID
+	addAutoCreated(created)
+	buffer.WriteString(loggerGen(3, autoCreateLabel(groupType)+" ["+groupName+"] not found in Hornbill, created with ID ["+newGroupID+"]"))
+	return newGroupID
+}
+
+// logAutoCreated -- outputs the sites and groups created during the import, or that would have been in dry run
+func logAutoCreated() {
+	mutexAutoCreate.Lock()
+	defer mutexAutoCreate.Unlock()
+	if len(autoCreated) == 0 {
+		return
+	}
+	action := "Created"
+	if configDryRun {
+		action = "To Be Created"
+	}
+	for _, v := range autoCreated {
+		if v.Error != "" {
+			logger(3, autoCreateLabel(v.Type)+" Create Failed: "+v.Name+" ("+v.Error+")", true, true)
+		} else {
+			logger(3, autoCreateLabel(v.Type)+" "+action+": "+v.Name, true, true)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"strings"
+	"sync"
+)
+
+// userCacheStruct -- concurrency safe cache of Hornbill user accounts, indexed on one or more user columns
+type userCacheStruct struct {
+	mutex   sync.RWMutex
+	columns []string
+	index   map[string]map[string]customerListStruct
+	count   int
+}
+
+// siteCacheStruct -- concurrency safe cache of Hornbill sites, indexed on site name
+type siteCacheStruct struct {
+	mutex sync.RWMutex
+	index map[string]siteListStruct
+}
+
+// groupCacheStruct -- concurrency safe cache of Hornbill groups, indexed on group type then group name
+type groupCacheStruct struct {
+	mutex sync.RWMutex
+	index map[int]map[string]groupListStruct
+	count int
+}
+
+// cacheKey -- returns the case-folded form of a value, so cache lookups match as strings.EqualFold would
+func cacheKey(value string) string {
+	return strings.ToLower(strings.ToUpper(value))
+}
+
+// newUserCache -- returns an empty user cache, indexing on the given Hornbill user columns
+func newUserCache(columns ...string) *userCacheStruct {
+	c := &userCacheStruct{columns: columns, index: make(map[string]map[string]customerListStruct)}
+	for _, column := range columns {
+		c.index[column] = make(map[string]customerListStruct)
+	}
+	return c
+}
+
+// userAccountColumn -- returns the value of a Hornbill user column from a user account record
+func userAccountColumn(user userAccountStruct, column string) string {
+	switch column {
+	case "h_employee_id":
+		return user.HEmployeeID
+	case "h_login_id":
+		return user.HLoginID
+	case "h_email":
+		return user.HEmail
+	case "h_name":
+		return user.HName
+	case "h_attrib1":
+		return user.HAttrib1
+	case "h_attrib8":
+		return user.HAttrib8
+	default:
+		return user.HUserID
+	}
+}
+
+// add -- adds a user account to the cache, under each of the indexed columns it holds a value for.
+// Where two accounts share a value, the first one added is kept
+func (c *userCacheStruct) add(user userAccountStruct) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, column := range c.columns {
+		value := userAccountColumn(user, column)
+		if value == "" {
+			continue
+		}
+		key := cacheKey(value)
+		if _, exists := c.index[column][key]; exists {
+			continue
+		}
+		c.index[column][key] = customerListStruct{
+			CustomerID:     value,
+			UserID:         user.HUserID,
+			CustomerName:   user.HFirstName + " " + user.HLastName,
+			CustomerHandle: user.HUserID,
+		}
+	}
+	c.count++
+}
+
+// get -- returns the cached user whose column value matches the given value, ignoring case
+func (c *userCacheStruct) get(column, value string) (customer customerListStruct, found bool) {
+	if c == nil {
+		return
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	customer, found = c.index[column][cacheKey(value)]
+	return
+}
+
+// len -- returns the number of user accounts added to the cache
+func (c *userCacheStruct) len() int {
+	if c == nil {
+		return 0
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.count
+}
+
+// newSiteCache -- returns an empty site cache
+func newSiteCache() *siteCacheStruct {
+	return &siteCacheStruct{index: make(map[string]siteListStruct)}
+}
+
+// add -- adds a site to the cache
+func (c *siteCacheStruct) add(site siteListStruct) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	key := cacheKey(site.SiteName)
+	if _, exists := c.index[key]; !exists {
+		c.index[key] = site
+	}
+}
+
+// get -- returns the cached site with the given name, ignoring case
+func (c *siteCacheStruct) get(siteName string) (site siteListStruct, found bool) {
+	if c == nil {
+		return
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	site, found = c.index[cacheKey(siteName)]
+	return
+}
+
+// len -- returns the number of cached sites
+func (c *siteCacheStruct) len() int {
+	if c == nil {
+		return 0
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return len(c.index)
+}
+
+// newGroupCache -- returns an empty group cache
+func newGroupCache() *groupCacheStruct {
+	return &groupCacheStruct{index: make(map[int]map[string]groupListStruct)}
+}
+
+// add -- adds a group to the cache
+func (c *groupCacheStruct) add(group groupListStruct) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.index[group.GroupType] == nil {
+		c.index[group.GroupType] = make(map[string]groupListStruct)
+	}
+	key := cacheKey(group.GroupName)
+	if _, exists := c.index[group.GroupType][key]; !exists {
+		c.index[group.GroupType][key] = group
+		c.count++
+	}
+}
+
+// get -- returns the cached group of the given type with the given name, ignoring case
+func (c *groupCacheStruct) get(groupType int, groupName string) (group groupListStruct, found bool) {
+	if c == nil {
+		return
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	group, found = c.index[groupType][cacheKey(groupName)]
+	return
+}
+
+// len -- returns the number of cached groups
+func (c *groupCacheStruct) len() int {
+	if c == nil {
+		return 0
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.count
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestUserCacheMultipleColumns(t *testing.T) {
+	c := newUserCache("h_login_id", "h_email", "h_employee_id")
+	c.add(userAccountStruct{HUserID: "jsmith", HLoginID: "JSmith", HEmail: "John.Smith@example.com", HEmployeeID: "E100", HFirstName: "John", HLastName: "Smith"})
+	c.add(userAccountStruct{HUserID: "bjones", HLoginID: "bjones", HFirstName: "Bob", HLastName: "Jones"})
+	//Duplicate login ID - the first account added is kept
+	c.add(userAccountStruct{HUserID: "jsmith2", HLoginID: "jsmith", HFirstName: "Jane", HLastName: "Smith"})
+
+	tests := []struct {
+		column, value, wantUserID string
+		wantFound                 bool
+	}{
+		{"h_login_id", "jsmith", "jsmith", true},
+		{"h_login_id", "JSMITH", "jsmith", true},
+		{"h_email", "john.smith@EXAMPLE.com", "jsmith", true},
+		{"h_employee_id", "e100", "jsmith", true},
+		{"h_employee_id", "", "", false},
+		{"h_email", "bjones", "", false},
+		{"h_user_id", "jsmith", "", false},
+	}
+	for _, test := range tests {
+		customer, found := c.get(test.column, test.value)
+		if found != test.wantFound || customer.UserID != test.wantUserID {
+			t.Errorf("get(%s, %s) = %q, %v, want %q, %v", test.column, test.value, customer.UserID, found, test.wantUserID, test.wantFound)
+		}
+	}
+	if customer, _ := c.get("h_login_id", "jsmith"); customer.CustomerName != "John Smith" {
+		t.Errorf("CustomerName = %q, want John Smith", customer.CustomerName)
+	}
+	if c.len() != 3 {
+		t.Errorf("len() = %d, want 3", c.len())
+	}
+}
+
+func TestSiteAndGroupCache(t *testing.T) {
+	sites := newSiteCache()
+	groups := newGroupCache()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			sites.add(siteListStruct{SiteName: "Site " + strconv.Itoa(i), SiteID: i})
+			sites.get("SITE " + strconv.Itoa(i))
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			groups.add(groupListStruct{GroupName: "Group " + strconv.Itoa(i), GroupType: 5, GroupID: "g" + strconv.Itoa(i)})
+			groups.get(5, "group "+strconv.Itoa(i))
+		}(i)
+	}
+	wg.Wait()
+
+	if site, found := sites.get("site 7"); !found || site.SiteID != 7 {
+		t.Errorf("site 7 not found, got %v", site)
+	}
+	if group, found := groups.get(5, "GROUP 3"); !found || group.GroupID != "g3" {
+		t.Errorf("company group 3 not found, got %v", group)
+	}
+	if _, found := groups.get(2, "Group 3"); found {
+		t.Error("department group 3 found, but only a company was added")
+	}
+	if sites.len() != 10 || groups.len() != 10 {
+		t.Errorf("len() = %d sites, %d groups, want 10 of each", sites.len(), groups.len())
+	}
+	var nilSites *siteCacheStruct
+	if _, found := nilSites.get("Site 1"); found || nilSites.len() != 0 {
+		t.Error("expected an unloaded site cache to be empty")
+	}
+}
+
+func TestCountsAboveUint16(t *testing.T) {
+	m := newImportTest(t)
+	m.Counts["getAssetsListForImport"] = 70000
+	m.Counts["getUserAccountsList"] = 70000
+	loadTestConfig(t, m,
+		map[string]interface{}{"Source": "csv", "CSV": map[string]interface{}{"CommaCharacter": ","}},
+		desktopAssetType(map[string]interface{}{"CSVFile": "desktops.csv"}))
+
+	count, err := getAssetCount(assetTypesStruct{Class: "computer", TypeID: 1}, hornbillImport)
+	if err != nil || count != 70000 {
+		t.Errorf("getAssetCount() = %d, %v, want 70000", count, err)
+	}
+	if count := getCount("getUserAccountsList"); count != 70000 {
+		t.Errorf("getCount() = %d, want 70000", count)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Exit code when the import is stopped by SIGINT or SIGTERM
+const exitCancelled = 130
+
+// Time allowed for assets in progress to complete once the import is cancelled
+var cancelWaitTimeout = 60 * time.Second
+
+var (
+	// importCtx is cancelled when the import is interrupted, to stop dispatching assets and cancel source requests
+	importCtx, cancelImport = context.WithCancel(context.Background())
+
+	inProgressAssets = make(map[inProgressKey]int)
+	mutexInProgress  = &sync.Mutex{}
+)
+
+// inProgressKey -- identifies an asset being processed by a worker
+type inProgressKey struct {
+	assetType string
+	assetID   string
+}
+
+// handleSignals -- cancels the import on the first SIGINT or SIGTERM, letting assets in progress complete,
+// and exits straight away on the second
+func handleSignals() {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		//Not sent to the instance log, which may be held up by the rate limiter or an unresponsive instance
+		logger(5, "Received "+sig.String()+", no further assets will be processed. Waiting for assets in progress to complete, signal again to stop immediately", true, false)
+		cancelImport()
+		sig = <-signals
+		logger(4, "Received "+sig.String()+" again, stopping immediately", true, false)
+		closeJournal(false)
+		os.Exit(exitCancelled)
+	}()
+}
+
+// importCancelled -- returns true once the import has been interrupted
+func importCancelled() bool {
+	return importCtx.Err() != nil
+}
+
+// sleepContext -- waits for the duration, returning false if the import is cancelled first
+func sleepContext(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-importCtx.Done():
+		return false
+	}
+}
+
+// startAsset -- records an asset as in progress, so it can be reported if abandoned on cancellation
+func startAsset(assetType, assetID string) {
+	mutexInProgress.Lock()
+	inProgressAssets[inProgressKey{assetType, assetID}]++
+	mutexInProgress.Unlock()
+}
+
+// finishAsset -- records an asset as no longer in progress
+func finishAsset(assetType, assetID string) {
+	key := inProgressKey{assetType, assetID}
+	mutexInProgress.Lock()
+	if inProgressAssets[key]--; inProgressAssets[key] <= 0 {
+		delete(inProgressAssets, key)
+	}
+	mutexInProgress.Unlock()
+}
+
+// waitWorkers -- waits for the asset workers to complete. Once the import is cancelled, workers are given
+// cancelWaitTimeout to complete, after which the assets still in progress are logged and reported as abandoned,
+// and false is returned
+func waitWorkers() bool {
+	done := make(chan struct{})
+	go func() {
+		worker.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-importCtx.Done():
+	}
+	select {
+	case <-done:
+		return true
+	case <-time.After(cancelWaitTimeout):
+	}
+	mutexInProgress.Lock()
+	var abandoned []inProgressKey
+	for key := range inProgressAssets {
+		abandoned = append(abandoned, key)
+	}
+	mutexInProgress.Unlock()
+	sort.Slice(abandoned, func(i, j int) bool {
+		if abandoned[i].assetType != abandoned[j].assetType {
+			return abandoned[i].assetType < abandoned[j].assetType
+		}
+		return abandoned[i].assetID < abandoned[j].assetID
+	})
+	names := make([]string, len(abandoned))
+	for i, key := range abandoned {
+		names[i] = key.assetType + ": " + key.assetID
+		addReportAsset(reportAssetStruct{AssetType: key.assetType, SourceID: key.assetID, Action: assetOutcomeAbandoned,
+			Error: "Still in progress after " + cancelWaitTimeout.String() + ", so may be part processed"})
+	}
+	logger(4, strconv.Itoa(len(abandoned))+" asset(s) still in progress after "+cancelWaitTimeout.String()+" were abandoned, and may be part processed: "+strings.Join(names, ", "), true, false)
+
+	//The abandoned workers may still complete, but the journal and report are written as the import stops, so
+	//their results are dropped. The journal is kept, so the abandoned assets are processed again by -resume
+	closeJournal(false)
+	closeReport()
+	return false
+}
@@ -35,22 +35,56 @@ type certeroResponseStruct struct {
 	} `json:"value"`
 }
 
-func getAssetsFromCertero(assetType assetTypesStruct) (map[string]map[string]interface{}, error) {
-	//Initialise Asset Map
-	returnMap := make(map[string]map[string]interface{})
+// certeroSource -- Source implementation for the Certero OData API
+type certeroSource struct {
+	conf sourceConfStruct
+	key  keyDataStruct
+}
+
+func init() {
+	registerSource(func(conf sourceConfStruct, key keyDataStruct) Source {
+		return &certeroSource{conf: conf, key: key}
+	}, "certero")
+}
+
+// ValidateConfig -- Checks the Keysafe key holds the Certero API endpoint
+func (s *certeroSource) ValidateConfig() error {
+	if s.key.Endpoint == "" {
+		return errors.New("no Certero endpoint defined in the Keysafe key")
+	}
+	return nil
+}
+
+// GetAssets -- Pages through the Certero assets matching the asset type query
+func (s *certeroSource) GetAssets(assetType assetTypesStruct) (map[string]map[string]interface{}, error) {
+	return collectAssets(s, assetType)
+}
+
+// StreamAssets -- Pages through the Certero assets matching the asset type query, emitting the assets as each page is returned
+func (s *certeroSource) StreamAssets(assetType assetTypesStruct, emit assetEmitter) error {
+	return s.getAssetsFromCertero(assetType, emit)
+}
+
+// GetSoftwareInventory -- Returns the software records expanded against the Certero asset record
+func (s *certeroSource) GetSoftwareInventory(assetRecord map[string]interface{}, assetType assetTypesStruct, buffer *bytes.Buffer) (map[string]map[string]interface{}, string, error) {
+	return getSoftwareRecordsFromParentObject(assetRecord, assetType, assetType.SoftwareInventory.ParentObject, buffer)
+}
+
+func (s *certeroSource) getAssetsFromCertero(assetType assetTypesStruct, emit assetEmitter) error {
+	assetCount := 0
 	logger(3, " ", false, false)
 	logger(3, "[CERTERO] Running Certero query for "+assetType.AssetType+" assets. Please wait...", true, true)
-	if importConf.SourceConfig.Certero.PageSize == 0 {
-		importConf.SourceConfig.Certero.PageSize = 100
+	if s.conf.Certero.PageSize == 0 {
+		s.conf.Certero.PageSize = 100
 	}
-	nextPageURL := key.Endpoint + "/?$top=" + strconv.Itoa(importConf.SourceConfig.Certero.PageSize) + "&$expand=" + importConf.SourceConfig.Certero.Expand
+	nextPageURL := s.key.Endpoint + "/?$top=" + strconv.Itoa(s.conf.Certero.PageSize) + "&$expand=" + s.conf.Certero.Expand
 	if assetType.Query != "" {
 		nextPageURL += "&$filter=" + url.PathEscape(assetType.Query)
 	}
 	for {
-		assetsList, err := getDevicesPageCertero(assetType, nextPageURL)
+		assetsList, err := s.getDevicesPageCertero(assetType, nextPageURL)
 		if err != nil {
-			return returnMap, err
+			return err
 		}
 		for _, v := range assetsList.Assets {
 			//Get the asset ID for the current record
@@ -70,7 +104,10 @@ func getAssetsFromCertero(assetType assetTypesStruct) (map[string]map[string]int
 			for i := 0; i < rV.NumField(); i++ {
 				assetRecord[typeOfS.Field(i).Name] = rV.Field(i).Interface()
 			}
-			returnMap[assetIdentifier] = assetRecord
+			assetCount++
+			if !emit(assetIdentifier, assetRecord) {
+				return nil
+			}
 		}
 
 		// Break the loop if no token is returned
@@ -79,27 +116,27 @@ func getAssetsFromCertero(assetType assetTypesStruct) (map[string]map[string]int
 		}
 		nextPageURL = assetsList.Odata_nextLink
 	}
-	if len(returnMap) == 0 {
+	if assetCount == 0 {
 		logger(3, "No "+assetType.AssetType+" asset records returned from Certero - check your configuration!", true, true)
 	} else {
-		logger(2, "Total "+assetType.AssetType+" asset records returned from Certero: "+strconv.Itoa(len(returnMap)), true, true)
+		logger(2, "Total "+assetType.AssetType+" asset records returned from Certero: "+strconv.Itoa(assetCount), true, true)
 	}
-	return returnMap, nil
+	return nil
 }
 
-func getDevicesPageCertero(assetType assetTypesStruct, nextPageURL string) (assetsResponse certeroResponseStruct, err error) {
+func (s *certeroSource) getDevicesPageCertero(assetType assetTypesStruct, nextPageURL string) (assetsResponse certeroResponseStruct, err error) {
 	logger(2, "Getting page of assets from Certero, URL: "+nextPageURL, false, true)
-	req, err := http.NewRequest("GET", nextPageURL, nil)
+	req, err := http.NewRequestWithContext(importCtx, "GET", nextPageURL, nil)
 
 	if err != nil {
 		return
 	}
-	auth := base64.StdEncoding.EncodeToString([]byte(key.APIKeyName + ":" + key.APIKey))
+	auth := base64.StdEncoding.EncodeToString([]byte(s.key.APIKeyName + ":" + s.key.APIKey))
 	req.Header.Set("Authorization", "Basic "+auth)
 	req.Header.Set("User-Agent", appName+"/"+version)
 
 	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyFromEnvironment}, Timeout: 120 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := doRequestRetry(client, req, nil)
 	if err != nil {
 		return
 	}
@@ -20,10 +20,13 @@ import (
 )
 
 func initXMLMC() {
-	endpoint := apiLib.GetEndPointFromName(importConf.InstanceID)
-	if endpoint == "" {
-		logger(4, "Unable to retrieve endpoint information for the supplied InstanceID: "+importConf.InstanceID, true, false)
-		os.Exit(1)
+	//InstanceId can also hold the XMLMC endpoint URL, in which case there's no endpoint to look up
+	if !strings.HasPrefix(strings.ToLower(importConf.InstanceID), "http") {
+		endpoint := apiLib.GetEndPointFromName(importConf.InstanceID)
+		if endpoint == "" {
+			logger(4, "Unable to retrieve endpoint information for the supplied InstanceID: "+importConf.InstanceID, true, false)
+			os.Exit(1)
+		}
 	}
 
 	hornbillImport = apiLib.NewXmlmcInstance(importConf.InstanceID)
@@ -31,6 +34,10 @@ func initXMLMC() {
 	hornbillImport.SetTimeout(60)
 	hornbillImport.SetJSONResponse(true)
 
+	espLoggerXmlmc = apiLib.NewXmlmcInstance(importConf.InstanceID)
+	espLoggerXmlmc.SetAPIKey(importConf.APIKey)
+	espLoggerXmlmc.SetTimeout(60)
+
 	if pageSize == 0 {
 		pageSize = 100
 	}
@@ -71,6 +78,13 @@ func getFieldValue(k string, v string, u map[string]interface{}, buffer *bytes.B
 
 // logger -- function to append to the current log file
 func logger(t int, s string, outputtoCLI bool, outputToEsp bool) {
+	writeLog(t, s, outputtoCLI, outputToEsp, nil)
+}
+
+// writeLog -- appends to the current log file, in the -logformat format. asset is the asset being processed, if any
+func writeLog(t int, s string, outputtoCLI bool, outputToEsp bool, asset *reportAssetStruct) {
+	s = redactLog(s)
+
 	//-- Current working dir
 	cwd, _ := os.Getwd()
 
@@ -87,7 +101,7 @@ func logger(t int, s string, outputtoCLI bool, outputToEsp bool) {
 	}
 
 	//-- Log File
-	logFileName := logPath + "/Asset_Import_" + startTime.Format("20060102150405") + "_" + strconv.Itoa(logFilePart) + ".log"
+	logFileName := logPath + "/" + currentLogFileName()
 	if maxLogFileSize > 0 {
 		//Check log file size
 		fileLoad, e := os.Stat(logFileName)
@@ -97,7 +111,7 @@ func logger(t int, s string, outputtoCLI bool, outputToEsp bool) {
 			fileSize := fileLoad.Size()
 			if fileSize > maxLogFileSize {
 				logFilePart++
-				logFileName = logPath + "/Asset_Import_" + startTime.Format("20060102150405") + "_" + strconv.Itoa(logFilePart) + ".log"
+				logFileName = logPath + "/" + currentLogFileName()
 			}
 		}
 	}
@@ -157,37 +171,26 @@ func logger(t int, s string, outputtoCLI bool, outputToEsp bool) {
 	if outputToEsp {
 		espLogger(s, espLogType)
 	}
+	if configLogFormat == logFormatJSON {
+		err = writeLogJSON(f, t, s, asset)
+		if err != nil {
+			fmt.Printf("Error Writing Log File %q: %s \n", logFileName, err)
+		}
+		return
+	}
 	// assign it to the standard logger
 	log.SetOutput(f)
 	log.Println(errorLogPrefix + s)
 }
 
-func loggerGen(t int, s string) string {
-	//-- Create Log Entry
-	var errorLogPrefix = ""
-	switch t {
-	case 1:
-		errorLogPrefix = "[DEBUG] "
-	case 2:
-		errorLogPrefix = "[MESSAGE] "
-	case 3:
-		errorLogPrefix = ""
-	case 4:
-		errorLogPrefix = "[ERROR] "
-	case 5:
-		errorLogPrefix = "[WARNING] "
-	}
-	return errorLogPrefix + s + "\n\r"
+// currentLogFileName -- returns the name of the current log file, which is named after the start of the import
+func currentLogFileName() string {
+	return "Asset_Import_" + startTime.Format("20060102150405") + "_" + strconv.Itoa(logFilePart) + ".log"
 }
-func loggerWriteBuffer(s string) {
-	if s != "" {
-		logLines := strings.Split(s, "\n\r")
-		for _, line := range logLines {
-			if line != "" {
-				logger(0, line, false, false)
-			}
-		}
-	}
+
+// loggerGen -- returns a log line for a per-asset buffer, written to the log file when the asset is complete
+func loggerGen(t int, s string) string {
+	return logLinePrefix(t) + s + logBufferSeparator
 }
 
 // checkDateString - returns date from supplied string
@@ -234,33 +237,38 @@ func Hash(arr []map[string]interface{}) string {
 }
 
 // getKeysafeKey - returns key details
-func getKeysafeKey(keyId int) {
+func getKeysafeKey(keyId int) (keyData keyDataStruct, err error) {
+	if keyId == 0 {
+		return
+	}
 	//API Call to get the key data
 	hornbillImport.SetParam("keyId", strconv.Itoa(keyId))
 	hornbillImport.SetParam("wantKeyData", "true")
-	RespBody, xmlmcErr := hornbillImport.Invoke("admin", "keysafeGetKey")
+	RespBody, xmlmcErr := invokeRetry(hornbillImport, "admin", "keysafeGetKey", nil)
 	var JSONResp xmlmcKeyResponse
 	if xmlmcErr != nil {
-		logger(4, "Unable to retrieve key information from Keysafe: "+xmlmcErr.Error(), true, true)
-		os.Exit(1)
+		err = errors.New("Unable to retrieve key information from Keysafe: " + xmlmcErr.Error())
+		return
 	}
 	//Unmarashal the API response
-	err := json.Unmarshal([]byte(RespBody), &JSONResp)
+	err = json.Unmarshal([]byte(RespBody), &JSONResp)
 	if err != nil {
-		logger(4, "Unable to unmarshal key information from Keysafe: "+err.Error(), true, true)
-		os.Exit(1)
+		err = errors.New("Unable to unmarshal key information from Keysafe: " + err.Error())
+		return
 	}
 	if JSONResp.State.Error != "" {
-		logger(4, "API call to retrieve key information from Keysafe failed: "+JSONResp.State.Error, true, true)
-		os.Exit(1)
+		err = errors.New("API call to retrieve key information from Keysafe failed: " + JSONResp.State.Error)
+		return
 	}
 
 	// Now we need to unmarshal the key data itself
-	err = json.Unmarshal([]byte(JSONResp.Params.Data), &key)
+	err = json.Unmarshal([]byte(JSONResp.Params.Data), &keyData)
 	if err != nil {
-		logger(4, "Unable to unmarshal Keysafe key data JSON: "+err.Error(), true, true)
-		os.Exit(1)
+		err = errors.New("Unable to unmarshal Keysafe key data JSON: " + err.Error())
+		return
 	}
+	addRedactSecrets(keyData.APIKey, keyData.ClientSecret, keyData.Password)
+	return
 }
 
 // espLogger -- Log to ESP
@@ -268,11 +276,17 @@ func espLogger(message string, severity string) {
 	if configDryRun {
 		message = "[DRYRUN] " + message
 	}
-	hornbillImport.SetParam("fileName", appName)
-	hornbillImport.SetParam("group", "general")
-	hornbillImport.SetParam("severity", severity)
-	hornbillImport.SetParam("message", message)
-	hornbillImport.Invoke("system", "logMessage")
+	mutexEspLogger.Lock()
+	defer mutexEspLogger.Unlock()
+	espLoggerXmlmc.SetParam("fileName", appName)
+	espLoggerXmlmc.SetParam("group", "general")
+	espLoggerXmlmc.SetParam("severity", severity)
+	espLoggerXmlmc.SetParam("message", message)
+	if !waitRateLimit("system") {
+		espLoggerXmlmc.ClearParam()
+		return
+	}
+	espLoggerXmlmc.Invoke("system", "logMessage")
 }
 
 func checkConfig() (err error) {
@@ -319,7 +333,7 @@ func isAppInstalled(app string) (appInstalled bool) {
 	return
 }
 func getApplications() {
-	XMLAppList, xmlmcErr := hornbillImport.Invoke("session", "getApplicationList")
+	XMLAppList, xmlmcErr := invokeRetry(hornbillImport, "session", "getApplicationList", nil)
 	if xmlmcErr != nil {
 		logger(4, "API Call failed when trying to get application list:"+xmlmcErr.Error(), true, true)
 		return
@@ -334,9 +348,12 @@ func getApplications() {
 		logger(3, "Failed to return applications list: "+apiResponse.State.Error, true, true)
 		return
 	}
+	//Replaced rather than added to, as the daemon refreshes the cache
+	applications := make(map[string]bool)
 	for i := 0; i < len(apiResponse.Params.Applications); i++ {
-		HInstalledApplications[apiResponse.Params.Applications[i].Name] = true
+		applications[apiResponse.Params.Applications[i].Name] = true
 	}
+	HInstalledApplications = applications
 }
 
 func printOnly(r rune) rune {
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// counterName -- A count kept for each AssetType, and totalled in the summary
+type counterName int
+
+// The counters, in the order they are output in the summary
+const (
+	counterCreated counterName = iota
+	counterCreateSkipped
+	counterCreateFailed
+	counterUpdated
+	counterUpdateSkipped
+	counterUpdateFailed
+	counterUpdateRelatedSkipped
+	counterUpdateRelatedFailed
+	counterSoftwareSkipped
+	counterSoftwareCreated
+	counterSoftwareCreateFailed
+	counterSoftwareRemoved
+	counterSoftwareRemoveFailed
+	counterSuppliersAssociatedSuccess
+	counterSuppliersAssociatedFailed
+	counterSuppliersAssociatedSkipped
+	counterSupplierContractsAssociatedSuccess
+	counterSupplierContractsAssociatedFailed
+	counterSupplierContractsAssociatedSkipped
+	counterOrphansFound
+	counterOrphansActioned
+	counterOrphansFailed
+	counterOrphansSkipped
+	counterDuplicatesFound
+	counterAssetTypesFailed
+	counterAssetsResumed
+	counterXMLMCRetries
+	counterHTTPRetries
+	counterCount
+)
+
+// counterLabels -- The label of each counter in the summary
+var counterLabels = [counterCount]string{
+	counterCreated:                            "Created",
+	counterCreateSkipped:                      "Create Skipped",
+	counterCreateFailed:                       "Create Failed",
+	counterUpdated:                            "Updated",
+	counterUpdateSkipped:                      "Update Skipped",
+	counterUpdateFailed:                       "Update Failed",
+	counterUpdateRelatedSkipped:               "Update Extended Record Skipped",
+	counterUpdateRelatedFailed:                "Update Extended Record Failed",
+	counterSoftwareSkipped:                    "Assets Software Inventory Skipped",
+	counterSoftwareCreated:                    "Software Records Created",
+	counterSoftwareCreateFailed:               "Software Records Create Failed",
+	counterSoftwareRemoved:                    "Software Records Removed",
+	counterSoftwareRemoveFailed:               "Software Records Removal Failed",
+	counterSuppliersAssociatedSuccess:         "Asset Supplier Associations Success",
+	counterSuppliersAssociatedFailed:          "Asset Supplier Associations Failed",
+	counterSuppliersAssociatedSkipped:         "Asset Supplier Associations Skipped",
+	counterSupplierContractsAssociatedSuccess: "Asset Supplier Contract Associations Success",
+	counterSupplierContractsAssociatedFailed:  "Asset Supplier Contract Associations Failed",
+	counterSupplierContractsAssociatedSkipped: "Asset Supplier Contract Associations Skipped",
+	counterOrphansFound:                       "Orphaned Assets Found",
+	counterOrphansActioned:                    "Orphaned Assets Actioned",
+	counterOrphansFailed:                      "Orphaned Assets Action Failed",
+	counterOrphansSkipped:                     "Orphaned Assets Action Skipped",
+	counterDuplicatesFound:                    "Duplicate Source Asset Identifiers",
+	counterAssetTypesFailed:                   "AssetTypes Failed",
+	counterAssetsResumed:                      "Assets Skipped (Completed Before Resume)",
+	counterXMLMCRetries:                       "XMLMC Calls Retried",
+	counterHTTPRetries:                        "Source HTTP Requests Retried",
+}
+
+// counterSetStruct -- A value for each counter
+type counterSetStruct [counterCount]uint64
+
+// countersStruct -- The counters of each AssetType, in the order the AssetTypes were first counted. Retries are
+// not made for any one AssetType, and are counted against an empty AssetType
+type countersStruct struct {
+	assetTypes map[string]*counterSetStruct
+	order      []string
+}
+
+// counterSnapshotStruct -- A copy of the counters of each AssetType, and their totals
+type counterSnapshotStruct struct {
+	assetTypes []string
+	byType     map[string]counterSetStruct
+	total      counterSetStruct
+}
+
+// addCounter -- adds to a counter of an AssetType
+func addCounter(assetType string, counter counterName, n uint64) {
+	mutexCounters.Lock()
+	defer mutexCounters.Unlock()
+	set, ok := counters.assetTypes[assetType]
+	if !ok {
+		if counters.assetTypes == nil {
+			counters.assetTypes = make(map[string]*counterSetStruct)
+		}
+		set = &counterSetStruct{}
+		counters.assetTypes[assetType] = set
+		if assetType != "" {
+			counters.order = append(counters.order, assetType)
+		}
+	}
+	set[counter] += n
+}
+
+// incCounter -- adds one to a counter of an AssetType
+func incCounter(assetType string, counter counterName) {
+	addCounter(assetType, counter, 1)
+}
+
+// resetCounters -- clears the counters of every AssetType
+func resetCounters() {
+	mutexCounters.Lock()
+	counters = countersStruct{}
+	mutexCounters.Unlock()
+}
+
+// counterSnapshot -- returns a copy of the counters, taken under the counters lock
+func counterSnapshot() counterSnapshotStruct {
+	mutexCounters.Lock()
+	defer mutexCounters.Unlock()
+	snapshot := counterSnapshotStruct{
+		assetTypes: append([]string(nil), counters.order...),
+		byType:     make(map[string]counterSetStruct, len(counters.assetTypes)),
+	}
+	for assetType, set := range counters.assetTypes {
+		snapshot.byType[assetType] = *set
+		for i, value := range set {
+			snapshot.total[i] += value
+		}
+	}
+	return snapshot
+}
+
+// counterTotal -- returns the total of a counter across every AssetType
+func counterTotal(counter counterName) uint64 {
+	return counterSnapshot().total[counter]
+}
+
+// assetTypeCounter -- returns the value of a counter for an AssetType
+func assetTypeCounter(assetType string, counter counterName) uint64 {
+	mutexCounters.Lock()
+	defer mutexCounters.Unlock()
+	if set, ok := counters.assetTypes[assetType]; ok {
+		return set[counter]
+	}
+	return 0
+}
+
+// assetTypeFailures -- returns the number of assets of an AssetType that failed to be created or updated
+func assetTypeFailures(assetType string) uint64 {
+	return assetTypeCounter(assetType, counterCreateFailed) + assetTypeCounter(assetType, counterUpdateFailed) + assetTypeCounter(assetType, counterUpdateRelatedFailed)
+}
+
+// summaryCounter -- returns true when a counter is output in the summary
+func summaryCounter(counter counterName) bool {
+	return counter != counterAssetsResumed || configResume
+}
+
+// logCounters -- outputs the total of each counter, then a table of the counters that are set for each AssetType
+func logCounters() {
+	snapshot := counterSnapshot()
+	for counter := counterName(0); counter < counterCount; counter++ {
+		if summaryCounter(counter) {
+			logger(3, counterLabels[counter]+": "+fmt.Sprintf("%d", snapshot.total[counter]), true, true)
+		}
+	}
+	if len(snapshot.assetTypes) == 0 {
+		return
+	}
+
+	var buf bytes.Buffer
+	table := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "\t"+strings.Join(snapshot.assetTypes, "\t")+"\tTotal")
+	//Retries are not made for any one AssetType, so are left out of the table
+	for counter := counterName(0); counter < counterXMLMCRetries; counter++ {
+		if !summaryCounter(counter) || snapshot.total[counter] == 0 {
+			continue
+		}
+		row := counterLabels[counter] + "\t"
+		for _, assetType := range snapshot.assetTypes {
+			row += fmt.Sprintf("%d", snapshot.byType[assetType][counter]) + "\t"
+		}
+		fmt.Fprintln(table, row+fmt.Sprintf("%d", snapshot.total[counter]))
+	}
+	table.Flush()
+	fmt.Println()
+	logger(3, "-=-=-= Summary by AssetType =-=-=-", true, true)
+	for _, line := range strings.Split(strings.TrimRight(buf.String(), "\n"), "\n") {
+		logger(3, strings.TrimRight(line, " "), true, true)
+	}
+}
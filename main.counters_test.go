@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"sync"
+	"testing"
+)
+
+func TestCounters(t *testing.T) {
+	newImportTest(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 7000; j++ {
+				incCounter("Desktop", counterUpdated)
+			}
+		}()
+	}
+	wg.Wait()
+	addCounter("Server", counterUpdated, 5)
+	incCounter("", counterXMLMCRetries)
+
+	snapshot := counterSnapshot()
+	//Past the 65535 a uint16 could hold
+	if snapshot.byType["Desktop"][counterUpdated] != 70000 || snapshot.total[counterUpdated] != 70005 {
+		t.Errorf("expected 70000 Desktops and 70005 in total updated, got %d and %d", snapshot.byType["Desktop"][counterUpdated], snapshot.total[counterUpdated])
+	}
+	if len(snapshot.assetTypes) != 2 || snapshot.assetTypes[0] != "Desktop" || snapshot.assetTypes[1] != "Server" {
+		t.Errorf("expected the Desktop and Server AssetTypes in the order counted, got %v", snapshot.assetTypes)
+	}
+	if counterTotal(counterXMLMCRetries) != 1 || assetTypeCounter("Server", counterXMLMCRetries) != 0 {
+		t.Errorf("unexpected retry counts %v", snapshot.byType)
+	}
+}
+
+func TestImportCountersByAssetType(t *testing.T) {
+	m := newImportTest(t)
+	m.AssetTypes["Laptop"] = mockAssetType{Class: "computer", TypeID: 2}
+	os.WriteFile("desktops.csv", []byte(testCSV), 0644)
+	os.WriteFile("laptops.csv", []byte("Name,SerialNumber,Model,Owner,Site,Company\nLaptop001,SERIAL101,XPS 13,jsmith,Head Office,Acme\n"), 0644)
+	loadTestConfig(t, m,
+		map[string]interface{}{"Source": "csv", "CSV": map[string]interface{}{"CommaCharacter": ","}},
+		desktopAssetType(map[string]interface{}{"CSVFile": "desktops.csv"}))
+	laptops := importConf.AssetTypes[0]
+	laptops.AssetType = "Laptop"
+	laptops.CSVFile = "laptops.csv"
+	importConf.AssetTypes = append(importConf.AssetTypes, laptops)
+
+	runImport()
+
+	snapshot := counterSnapshot()
+	if snapshot.byType["Desktop"][counterCreated] != 2 || snapshot.byType["Laptop"][counterCreated] != 1 || snapshot.total[counterCreated] != 3 {
+		t.Errorf("expected 2 Desktops and 1 Laptop created, got %v", snapshot.byType)
+	}
+	content, err := os.ReadFile("log/" + currentLogFileName())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pattern := range []string{`\s+Desktop\s+Laptop\s+Total\n`, `Created\s+2\s+1\s+3\n`, `Created: 3\n`} {
+		if !regexp.MustCompile(pattern).Match(content) {
+			t.Errorf("summary missing %q:\n%s", pattern, content)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -20,18 +21,43 @@ func (r *customReader) Read(b []byte) (n int, err error) {
 	return n, nil
 }
 
-// func getAssetsFromCSV(csvFile string, assetType assetTypesStruct) (bool, []map[string]string) {
-func getAssetsFromCSV(assetType assetTypesStruct) (bool, map[string]map[string]interface{}) {
-	//Initialise Asset Map
-	arrAssetMaps := make(map[string]map[string]interface{})
+// csvSource -- Source implementation for CSV files, one file per asset type
+type csvSource struct {
+	conf sourceConfStruct
+	key  keyDataStruct
+}
+
+func init() {
+	registerSource(func(conf sourceConfStruct, key keyDataStruct) Source {
+		return &csvSource{conf: conf, key: key}
+	}, "csv")
+}
+
+// ValidateConfig -- Checks the CSV reader settings
+func (s *csvSource) ValidateConfig() error {
+	if len([]rune(s.conf.CSV.CommaCharacter)) > 1 {
+		return errors.New("the CSV CommaCharacter must be a single character: " + s.conf.CSV.CommaCharacter)
+	}
+	return nil
+}
+
+// GetAssets -- Reads the asset type CSV file
+func (s *csvSource) GetAssets(assetType assetTypesStruct) (map[string]map[string]interface{}, error) {
+	return collectAssets(s, assetType)
+}
+
+// StreamAssets -- Reads the asset type CSV file, emitting each row as it is read
+func (s *csvSource) StreamAssets(assetType assetTypesStruct, emit assetEmitter) error {
+	return s.getAssetsFromCSV(assetType, emit)
+}
+
+func (s *csvSource) getAssetsFromCSV(assetType assetTypesStruct, emit assetEmitter) error {
 	logger(3, " ", false, false)
 	logger(3, "Running CSV query for "+assetType.AssetType+" assets. Please wait...", true, true)
 
 	file, err := os.Open(assetType.CSVFile)
 	if err != nil {
-		// elements passed are separated by space automatically
-		logger(4, "Error opening CSV file: "+err.Error()+" for "+assetType.AssetType+" assets.", true, true)
-		return false, arrAssetMaps
+		return errors.New("Error opening CSV file: " + err.Error() + " for " + assetType.AssetType + " assets.")
 	}
 	defer file.Close()
 
@@ -45,23 +71,23 @@ func getAssetsFromCSV(assetType assetTypesStruct) (bool, map[string]map[string]i
 	}
 
 	var r *csv.Reader
-	if importConf.SourceConfig.CSV.CarriageReturnRemoval {
+	if s.conf.CSV.CarriageReturnRemoval {
 		custom := &customReader{file}
 		r = csv.NewReader(custom)
 	} else {
 		r = csv.NewReader(file)
 	}
 	//because the json configuration loader cannot handle runes, code here to convert string to rune-array and getting first item
-	if importConf.SourceConfig.CSV.CommaCharacter != "" {
-		CSVCommaRunes := []rune(importConf.SourceConfig.CSV.CommaCharacter)
+	if s.conf.CSV.CommaCharacter != "" {
+		CSVCommaRunes := []rune(s.conf.CSV.CommaCharacter)
 		r.Comma = CSVCommaRunes[0]
 	}
 
-	if importConf.SourceConfig.CSV.LazyQuotes {
+	if s.conf.CSV.LazyQuotes {
 		r.LazyQuotes = true
 	}
-	if importConf.SourceConfig.CSV.FieldsPerRecord > 0 {
-		r.FieldsPerRecord = importConf.SourceConfig.CSV.FieldsPerRecord
+	if s.conf.CSV.FieldsPerRecord > 0 {
+		r.FieldsPerRecord = s.conf.CSV.FieldsPerRecord
 	}
 	var header []string
 
@@ -73,8 +99,7 @@ func getAssetsFromCSV(assetType assetTypesStruct) (bool, map[string]map[string]i
 			break
 		}
 		if err != nil {
-			logger(4, "Error reading CSV data: "+err.Error()+" for "+assetType.AssetType+" assets.", true, true)
-			return false, arrAssetMaps
+			return errors.New("Error reading CSV data: " + err.Error() + " for " + assetType.AssetType + " assets.")
 		}
 		if header == nil {
 			header = record
@@ -85,9 +110,11 @@ func getAssetsFromCSV(assetType assetTypesStruct) (bool, map[string]map[string]i
 				dict[header[i]] = record[i]
 			}
 			intAssetSuccess++
-			arrAssetMaps[fmt.Sprintf("%s", dict[assetType.AssetIdentifier.SourceColumn])] = dict
+			if !emit(fmt.Sprintf("%s", dict[assetType.AssetIdentifier.SourceColumn]), dict) {
+				return nil
+			}
 		}
 	}
 	logger(3, ""+strconv.Itoa(intAssetSuccess)+" of "+strconv.Itoa(intAssetCount)+" returned assets successfully retrieved ready for processing.", true, true)
-	return true, arrAssetMaps
+	return nil
 }
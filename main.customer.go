@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -10,6 +11,13 @@ import (
 	"github.com/cheggaaa/pb"
 )
 
+// User ID normalisation rules for HornbillUserMatch
+const (
+	userNormaliseStripDomain = "stripdomain"
+	userNormaliseLowercase   = "lowercase"
+	userNormaliseUPNPrefix   = "upnprefix"
+)
+
 func loadUsers() {
 	//-- Init One connection to Hornbill to load all data
 	initXMLMC()
@@ -17,9 +25,14 @@ func loadUsers() {
 
 	count := getCount("getUserAccountsList")
 	logger(3, "getUserAccountsList Count: "+strconv.FormatUint(count, 10), false, true)
+	var columns []string
+	for _, match := range importConf.HornbillUserMatch {
+		columns = append(columns, match.Column)
+	}
+	Customers = newUserCache(columns...)
 	getUserAccountList(count)
 
-	logger(3, "Users Loaded: "+strconv.Itoa(len(Customers)), false, true)
+	logger(3, "Users Loaded: "+strconv.Itoa(Customers.len()), false, true)
 }
 
 func getUserAccountList(count uint64) {
@@ -37,7 +50,7 @@ func getUserAccountList(count uint64) {
 		hornbillImport.SetParam("rowstart", strconv.FormatUint(loopCount, 10))
 		hornbillImport.SetParam("limit", strconv.Itoa(pageSize))
 		hornbillImport.CloseElement("queryParams")
-		RespBody, xmlmcErr := hornbillImport.Invoke("data", "queryExec")
+		RespBody, xmlmcErr := invokeRetry(hornbillImport, "data", "queryExec", nil)
 
 		var JSONResp xmlmcUserListResponse
 		if xmlmcErr != nil {
@@ -55,46 +68,7 @@ func getUserAccountList(count uint64) {
 		}
 		//-- Push into Map
 		for index := range JSONResp.Params.RowData.Row {
-			var newCustomerForCache customerListStruct
-			switch importConf.HornbillUserIDColumn {
-			case "h_employee_id":
-				{
-					newCustomerForCache.CustomerID = JSONResp.Params.RowData.Row[index].HEmployeeID
-				}
-			case "h_login_id":
-				{
-					newCustomerForCache.CustomerID = JSONResp.Params.RowData.Row[index].HLoginID
-				}
-			case "h_email":
-				{
-					newCustomerForCache.CustomerID = JSONResp.Params.RowData.Row[index].HEmail
-				}
-			case "h_name":
-				{
-					newCustomerForCache.CustomerID = JSONResp.Params.RowData.Row[index].HName
-				}
-			case "h_attrib1":
-				{
-					newCustomerForCache.CustomerID = JSONResp.Params.RowData.Row[index].HAttrib1
-				}
-			case "h_attrib8":
-				{
-					newCustomerForCache.CustomerID = JSONResp.Params.RowData.Row[index].HAttrib8
-				}
-			case "h_user_id":
-				{
-					newCustomerForCache.CustomerID = JSONResp.Params.RowData.Row[index].HUserID
-				}
-			default:
-				{
-					newCustomerForCache.CustomerID = JSONResp.Params.RowData.Row[index].HUserID
-				}
-			}
-			newCustomerForCache.CustomerHandle = JSONResp.Params.RowData.Row[index].HUserID
-			newCustomerForCache.UserID = JSONResp.Params.RowData.Row[index].HUserID
-			newCustomerForCache.CustomerName = JSONResp.Params.RowData.Row[index].HFirstName + " " + JSONResp.Params.RowData.Row[index].HLastName
-			customerNamedMap := []customerListStruct{newCustomerForCache}
-			Customers = append(Customers, customerNamedMap...)
+			Customers.add(JSONResp.Params.RowData.Row[index])
 		}
 
 		// Add 100
@@ -116,7 +90,7 @@ func getCount(query string) uint64 {
 	hornbillImport.SetParam("getCount", "true")
 	hornbillImport.CloseElement("queryParams")
 
-	RespBody, xmlmcErr := hornbillImport.Invoke("data", "queryExec")
+	RespBody, xmlmcErr := invokeRetry(hornbillImport, "data", "queryExec", nil)
 
 	var JSONResp xmlmcCountResponse
 	if xmlmcErr != nil {
@@ -134,7 +108,7 @@ func getCount(query string) uint64 {
 	}
 
 	//-- return Count
-	count, errC := strconv.ParseUint(JSONResp.Params.RowData.Row[0].Count, 10, 16)
+	count, errC := strconv.ParseUint(JSONResp.Params.RowData.Row[0].Count, 10, 64)
 	//-- Check for Error
 	if errC != nil {
 		logger(4, "Unable to get Count for Query ["+query+"] "+errC.Error(), false, true)
@@ -143,6 +117,59 @@ func getCount(query string) uint64 {
 	return count
 }
 
+// checkUserMatchConfig -- Validates the HornbillUserMatch columns and normalisation rules. When no HornbillUserMatch
+// is configured, users are matched on HornbillUserIDColumn alone
+func checkUserMatchConfig() error {
+	importConf.HornbillUserIDColumn = strings.ToLower(importConf.HornbillUserIDColumn)
+	if len(importConf.HornbillUserMatch) == 0 {
+		column := importConf.HornbillUserIDColumn
+		if column == "" {
+			column = "h_user_id"
+		}
+		importConf.HornbillUserMatch = []userMatchStruct{{Column: column}}
+	}
+	for i := range importConf.HornbillUserMatch {
+		match := &importConf.HornbillUserMatch[i]
+		match.Column = strings.ToLower(match.Column)
+		switch match.Column {
+		case "h_user_id", "h_login_id", "h_email", "h_employee_id", "h_name", "h_attrib1", "h_attrib8":
+		default:
+			return errors.New("HornbillUserMatch: unsupported user column [" + match.Column + "], supported columns are: h_user_id, h_login_id, h_email, h_employee_id, h_name, h_attrib1, h_attrib8")
+		}
+		for j, rule := range match.Normalise {
+			rule = strings.ToLower(rule)
+			switch rule {
+			case userNormaliseStripDomain, userNormaliseLowercase, userNormaliseUPNPrefix:
+			default:
+				return errors.New("HornbillUserMatch: unsupported normalisation rule [" + rule + "] for " + match.Column + ", supported rules are: " + userNormaliseStripDomain + ", " + userNormaliseLowercase + ", " + userNormaliseUPNPrefix)
+			}
+			match.Normalise[j] = rule
+		}
+	}
+	return nil
+}
+
+// normaliseUserID -- applies the normalisation rules, in order, to a user ID from the source
+func normaliseUserID(userID string, rules []string) string {
+	for _, rule := range rules {
+		switch rule {
+		case userNormaliseStripDomain:
+			//DOMAIN\sam becomes sam
+			if i := strings.LastIndex(userID, "\\"); i >= 0 {
+				userID = userID[i+1:]
+			}
+		case userNormaliseLowercase:
+			userID = strings.ToLower(userID)
+		case userNormaliseUPNPrefix:
+			//sam@domain.com becomes sam
+			if i := strings.Index(userID, "@"); i >= 0 {
+				userID = userID[:i]
+			}
+		}
+	}
+	return userID
+}
+
 func getUserID(u map[string]interface{}, userCol string, buffer *bytes.Buffer, typeSpecific bool) (userID, userURN, userName string) {
 	var userMapping string
 	if typeSpecific {
@@ -156,11 +183,17 @@ func getUserID(u map[string]interface{}, userCol string, buffer *bytes.Buffer, t
 		userID = "SharedUser"
 		userURN = "urn:sys:0:" + userName + ":" + userID
 	} else if userID != "" && userID != "<nil>" && userID != "__clear__" {
-		for _, customer := range Customers {
-			if strings.EqualFold(customer.CustomerID, userID) {
+		sourceUserID := userID
+		for _, match := range importConf.HornbillUserMatch {
+			matchValue := normaliseUserID(sourceUserID, match.Normalise)
+			if matchValue == "" {
+				continue
+			}
+			if customer, found := Customers.get(match.Column, matchValue); found {
 				userName = customer.CustomerName
 				userID = customer.UserID
 				userURN = "urn:sys:0:" + userName + ":" + userID
+				buffer.WriteString(loggerGen(3, "User ["+sourceUserID+"] matched Hornbill user ["+userID+"] on "+match.Column+" ["+matchValue+"]"))
 				break
 			}
 		}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGetUserIDMatchOrder(t *testing.T) {
+	importConf = importConfStruct{
+		AssetGenericFieldMapping: map[string]interface{}{"h_owned_by": "{{.Owner}}"},
+		HornbillUserMatch: []userMatchStruct{
+			{Column: "H_Login_ID", Normalise: []string{"StripDomain", "UPNPrefix"}},
+			{Column: "h_email"},
+			{Column: "h_employee_id", Normalise: []string{"lowercase"}},
+		},
+	}
+	t.Cleanup(func() { importConf = importConfStruct{} })
+	if err := checkUserMatchConfig(); err != nil {
+		t.Fatal(err)
+	}
+	Customers = newUserCache("h_login_id", "h_email", "h_employee_id")
+	Customers.add(userAccountStruct{HUserID: "jsmith", HLoginID: "jsmith", HEmail: "john.smith@acme.com", HEmployeeID: "e100", HFirstName: "John", HLastName: "Smith"})
+	Customers.add(userAccountStruct{HUserID: "bjones", HLoginID: "bob", HEmail: "bjones@acme.com", HEmployeeID: "e200", HFirstName: "Bob", HLastName: "Jones"})
+	t.Cleanup(func() { Customers = nil })
+
+	tests := []struct {
+		owner, wantUserID, wantColumn string
+	}{
+		{`ACME\jsmith`, "jsmith", "h_login_id"},
+		{"jsmith@acme.local", "jsmith", "h_login_id"},
+		{"bjones@acme.com", "bjones", "h_email"},
+		{"E200", "bjones", "h_employee_id"},
+		{`ACME\nobody`, `ACME\nobody`, ""},
+	}
+	for _, test := range tests {
+		var buffer bytes.Buffer
+		userID, userURN, _ := getUserID(map[string]interface{}{"Owner": test.owner}, "h_owned_by", &buffer, false)
+		if userID != test.wantUserID {
+			t.Errorf("getUserID(%s) = %q, want %q", test.owner, userID, test.wantUserID)
+		}
+		if test.wantColumn == "" {
+			if userURN != "" {
+				t.Errorf("getUserID(%s) matched %s, want no match", test.owner, userURN)
+			}
+		} else if !strings.Contains(buffer.String(), "on "+test.wantColumn+" [") {
+			t.Errorf("getUserID(%s) did not log a match on %s: %s", test.owner, test.wantColumn, buffer.String())
+		}
+	}
+}
+
+func TestCheckUserMatchConfig(t *testing.T) {
+	t.Cleanup(func() { importConf = importConfStruct{} })
+
+	importConf = importConfStruct{HornbillUserIDColumn: "H_Email"}
+	if err := checkUserMatchConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if len(importConf.HornbillUserMatch) != 1 || importConf.HornbillUserMatch[0].Column != "h_email" {
+		t.Errorf("expected HornbillUserIDColumn to be used when HornbillUserMatch is not set, got %v", importConf.HornbillUserMatch)
+	}
+
+	importConf = importConfStruct{HornbillUserMatch: []userMatchStruct{{Column: "h_mobile"}}}
+	if err := checkUserMatchConfig(); err == nil {
+		t.Error("expected an error for an unsupported user column")
+	}
+	importConf = importConfStruct{HornbillUserMatch: []userMatchStruct{{Column: "h_login_id", Normalise: []string{"uppercase"}}}}
+	if err := checkUserMatchConfig(); err == nil {
+		t.Error("expected an error for an unsupported normalisation rule")
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Defaults for the Daemon configuration
+const (
+	daemonDefaultStatusAddress   = "127.0.0.1:8089"
+	daemonDefaultCacheTTLMinutes = 60
+	daemonDefaultHistorySize     = 50
+)
+
+// daemonRunStruct -- The outcome of an import run by the daemon
+type daemonRunStruct struct {
+	Started  time.Time `json:"Started"`
+	Finished time.Time `json:"Finished"`
+	Duration string    `json:"Duration"`
+	ExitCode int       `json:"ExitCode"`
+	Reasons  []string  `json:"Reasons,omitempty"`
+	Created  uint64    `json:"Created"`
+	Updated  uint64    `json:"Updated"`
+	Skipped  uint64    `json:"Skipped"`
+	Failed   uint64    `json:"Failed"`
+	LogFile  string    `json:"LogFile"`
+}
+
+// daemonStatusStruct -- The state of the daemon and its run history, newest first, served on the status page
+type daemonStatusStruct struct {
+	Version      string            `json:"Version"`
+	Config       string            `json:"Config"`
+	Instance     string            `json:"Instance"`
+	Schedule     string            `json:"Schedule"`
+	Started      time.Time         `json:"Started"`
+	Running      bool              `json:"Running"`
+	RunStarted   time.Time         `json:"RunStarted"`
+	NextRun      time.Time         `json:"NextRun"`
+	CachesLoaded time.Time         `json:"CachesLoaded"`
+	Runs         []daemonRunStruct `json:"Runs"`
+}
+
+var (
+	// cachesLoaded is when the Hornbill user, site, group and application caches were last loaded
+	cachesLoaded time.Time
+
+	daemonStatus daemonStatusStruct
+	mutexDaemon  = &sync.Mutex{}
+)
+
+var daemonStatusTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="30">
+<title>Hornbill Asset Import - {{.Config}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+.failed { color: #b00; }
+</style>
+</head>
+<body>
+<h1>Hornbill Asset Import v{{.Version}}</h1>
+<table>
+<tr><th>Configuration</th><td>{{.Config}}</td></tr>
+<tr><th>Instance</th><td>{{.Instance}}</td></tr>
+<tr><th>Schedule</th><td>{{.Schedule}}</td></tr>
+<tr><th>Daemon Started</th><td>{{.Started.Format "2006-01-02 15:04:05"}}</td></tr>
+<tr><th>Status</th><td>{{if .Running}}Running since {{.RunStarted.Format "2006-01-02 15:04:05"}}{{else}}Idle{{end}}</td></tr>
+<tr><th>Next Run</th><td>{{if .NextRun.IsZero}}-{{else}}{{.NextRun.Format "2006-01-02 15:04:05"}}{{end}}</td></tr>
+<tr><th>Hornbill Caches Loaded</th><td>{{if .CachesLoaded.IsZero}}-{{else}}{{.CachesLoaded.Format "2006-01-02 15:04:05"}}{{end}}</td></tr>
+</table>
+<h2>Run History</h2>
+<table>
+<tr><th>Started</th><th>Duration</th><th>Exit Code</th><th>Created</th><th>Updated</th><th>Skipped</th><th>Failed</th><th>Log File</th><th>Reasons</th></tr>
+{{range .Runs}}<tr{{if .ExitCode}} class="failed"{{end}}><td>{{.Started.Format "2006-01-02 15:04:05"}}</td><td>{{.Duration}}</td><td>{{.ExitCode}}</td><td>{{.Created}}</td><td>{{.Updated}}</td><td>{{.Skipped}}</td><td>{{.Failed}}</td><td>{{.LogFile}}</td><td>{{range .Reasons}}{{.}}<br>{{end}}</td></tr>
+{{else}}<tr><td colspan="9">No runs yet</td></tr>
+{{end}}</table>
+<p><a href="status">JSON</a> | <a href="metrics">Metrics</a></p>
+</body>
+</html>
+`))
+
+// checkDaemonConfig -- Validates the Daemon configuration, returning the schedule it sets
+func checkDaemonConfig() (Schedule, error) {
+	conf := importConf.Daemon
+	if conf.IntervalMinutes < 0 || conf.CacheTTLMinutes < 0 || conf.HistorySize < 0 {
+		return nil, errors.New("Daemon IntervalMinutes, CacheTTLMinutes and HistorySize must not be negative")
+	}
+	if conf.Cron != "" && conf.IntervalMinutes > 0 {
+		return nil, errors.New("Daemon configuration can set either Cron or IntervalMinutes, not both")
+	}
+	if conf.Cron != "" {
+		return parseCron(conf.Cron)
+	}
+	if conf.IntervalMinutes > 0 {
+		return intervalScheduleStruct{interval: time.Duration(conf.IntervalMinutes) * time.Minute}, nil
+	}
+	return nil, errors.New("Daemon configuration must set Cron or IntervalMinutes to run with -daemon")
+}
+
+// cachesExpired -- returns true when the Hornbill caches need loading. They are loaded for every import, unless
+// running as a daemon, when they are kept for CacheTTLMinutes
+func cachesExpired() bool {
+	if !configDaemon || cachesLoaded.IsZero() {
+		return true
+	}
+	ttl := importConf.Daemon.CacheTTLMinutes
+	if ttl == 0 {
+		ttl = daemonDefaultCacheTTLMinutes
+	}
+	return time.Since(cachesLoaded) >= time.Duration(ttl)*time.Minute
+}
+
+// resetRun -- clears the counters and outcomes of the previous import, so each daemon run starts afresh.
+// The Hornbill session and caches are kept
+func resetRun() {
+	startTime = time.Now()
+	logFilePart = 0
+	resetCounters()
+	mutexAssets.Lock()
+	assets = make(map[string]string)
+	mutexAssets.Unlock()
+	mutexReport.Lock()
+	reportAssets = nil
+	reportClosed = false
+	mutexReport.Unlock()
+	mutexAutoCreate.Lock()
+	autoCreated = nil
+	autoCreateAttempted = make(map[string]bool)
+	mutexAutoCreate.Unlock()
+	mutexUnresolved.Lock()
+	unresolvedRefs = make(map[string]*unresolvedStruct)
+	mutexUnresolved.Unlock()
+	mutexJournal.Lock()
+	journalCompleted = make(map[string]journalEntryStruct)
+	mutexJournal.Unlock()
+}
+
+// runDaemon -- Serves the status page, and runs the import on the Daemon schedule until SIGINT or SIGTERM.
+// Returns the exit code
+func runDaemon() int {
+	schedule, err := checkDaemonConfig()
+	if err != nil {
+		logger(4, err.Error(), true, true)
+		return exitConfigError
+	}
+	address := importConf.Daemon.StatusAddress
+	if address == "" {
+		address = daemonDefaultStatusAddress
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		logger(4, "Unable to serve the daemon status page: "+err.Error(), true, true)
+		return exitConfigError
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", daemonStatusHandler)
+	mux.HandleFunc("/status", daemonStatusJSONHandler)
+	mux.HandleFunc("/metrics", metricsHandler)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go server.Serve(listener)
+	defer server.Close()
+
+	logger(3, "Daemon started, importing "+schedule.String()+". Status page: http://"+listener.Addr().String()+"/", true, true)
+	exitCode := daemonLoop(schedule, importConf.Daemon.RunOnStart)
+	logger(3, "Daemon stopped", true, true)
+	return exitCode
+}
+
+// daemonLoop -- Runs the import each time the schedule is due, until the import is cancelled. Runs never overlap:
+// a run that is due while the previous run is still in progress is skipped. Returns exitCancelled when a run was
+// interrupted, otherwise exitSuccess
+func daemonLoop(schedule Schedule, runOnStart bool) int {
+	mutexDaemon.Lock()
+	daemonStatus = daemonStatusStruct{
+		Version:  version,
+		Config:   configFileName,
+		Instance: importConf.InstanceID,
+		Schedule: schedule.String(),
+		Started:  time.Now(),
+	}
+	mutexDaemon.Unlock()
+
+	due := time.Now()
+	if !runOnStart {
+		due = schedule.Next(due)
+	}
+	for {
+		mutexDaemon.Lock()
+		daemonStatus.NextRun = due
+		mutexDaemon.Unlock()
+		logger(3, "Next import due at "+due.Format("2006-01-02 15:04:05"), true, false)
+		if !sleepContext(time.Until(due)) {
+			return exitSuccess
+		}
+
+		daemonRun()
+		if importCancelled() {
+			return exitCancelled
+		}
+
+		next := schedule.Next(due)
+		skipped := 0
+		for !next.IsZero() && !next.After(time.Now()) {
+			skipped++
+			next = schedule.Next(next)
+		}
+		if skipped > 0 {
+			logger(5, strconv.Itoa(skipped)+" scheduled import(s) skipped, as the previous import was still in progress", true, true)
+		}
+		if next.IsZero() {
+			logger(5, "No further imports are scheduled", true, true)
+			return exitSuccess
+		}
+		due = next
+	}
+}
+
+// daemonRun -- Runs an import, and adds its outcome to the run history
+func daemonRun() {
+	resetRun()
+	mutexDaemon.Lock()
+	daemonStatus.Running = true
+	daemonStatus.RunStarted = startTime
+	mutexDaemon.Unlock()
+
+	exitCode := importAndRecord()
+
+	run := daemonRunStruct{
+		Started:  startTime,
+		Finished: time.Now(),
+		Duration: time.Since(startTime).Round(time.Second).String(),
+		ExitCode: exitCode,
+		LogFile:  currentLogFileName(),
+	}
+	if exitCode == exitConfigError {
+		run.Reasons = []string{"the configuration is invalid, or the import could not be started"}
+	} else {
+		_, run.Reasons = importExitCode()
+	}
+	c := counterSnapshot().total
+	run.Created = c[counterCreated]
+	run.Updated = c[counterUpdated]
+	run.Skipped = c[counterCreateSkipped] + c[counterUpdateSkipped]
+	run.Failed = c[counterCreateFailed] + c[counterUpdateFailed]
+
+	historySize := importConf.Daemon.HistorySize
+	if historySize == 0 {
+		historySize = daemonDefaultHistorySize
+	}
+	mutexDaemon.Lock()
+	daemonStatus.Running = false
+	daemonStatus.RunStarted = time.Time{}
+	daemonStatus.CachesLoaded = cachesLoaded
+	daemonStatus.Runs = append([]daemonRunStruct{run}, daemonStatus.Runs...)
+	if len(daemonStatus.Runs) > historySize {
+		daemonStatus.Runs = daemonStatus.Runs[:historySize]
+	}
+	mutexDaemon.Unlock()
+}
+
+// daemonStatusSnapshot -- returns a copy of the daemon status, taken under the daemon lock
+func daemonStatusSnapshot() daemonStatusStruct {
+	mutexDaemon.Lock()
+	defer mutexDaemon.Unlock()
+	status := daemonStatus
+	status.Runs = append([]daemonRunStruct(nil), daemonStatus.Runs...)
+	return status
+}
+
+// daemonStatusHandler -- serves the status page, with the run history
+func daemonStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := daemonStatusTemplate.Execute(w, daemonStatusSnapshot())
+	if err != nil {
+		logger(4, "Unable to write the daemon status page: "+err.Error(), false, false)
+	}
+}
+
+// daemonStatusJSONHandler -- serves the daemon status and run history as JSON
+func daemonStatusJSONHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(daemonStatusSnapshot())
+	if err != nil {
+		logger(4, "Unable to write the daemon status: "+err.Error(), false, false)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDaemon(t *testing.T) {
+	m := newImportTest(t)
+	os.WriteFile("desktops.csv", []byte(testCSV), 0644)
+	loadTestConfig(t, m,
+		map[string]interface{}{"Source": "csv", "CSV": map[string]interface{}{"CommaCharacter": ","}},
+		desktopAssetType(map[string]interface{}{"CSVFile": "desktops.csv"}))
+	configDaemon = true
+
+	interval := 300 * time.Millisecond
+	done := make(chan int)
+	go func() {
+		done <- daemonLoop(intervalScheduleStruct{interval: interval}, true)
+	}()
+	deadline := time.After(10 * time.Second)
+	for len(daemonStatusSnapshot().Runs) < 2 {
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for two daemon runs")
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	cancelImport()
+	if code := <-done; code != exitSuccess && code != exitCancelled {
+		t.Errorf("unexpected daemon exit code %d", code)
+	}
+
+	status := daemonStatusSnapshot()
+	latest, first := status.Runs[0], status.Runs[1]
+	if first.Created != 2 || first.ExitCode != exitSuccess {
+		t.Errorf("expected the first run to create 2 assets, got %+v", first)
+	}
+	if latest.Created != 0 || latest.Skipped != 2 {
+		t.Errorf("expected the second run to skip the 2 unchanged assets, got %+v", latest)
+	}
+	if latest.Started.Before(first.Finished) || latest.Started.Sub(first.Started) < interval/2 {
+		t.Errorf("runs overlapped or started %v apart, expected %v", latest.Started.Sub(first.Started), interval)
+	}
+	//The caches are kept warm between runs
+	if calls := m.CallCount("session::getApplicationList"); calls != 1 {
+		t.Errorf("expected the application cache to be loaded once, got %d calls", calls)
+	}
+	if status.CachesLoaded.IsZero() || !strings.HasPrefix(first.LogFile, "Asset_Import_") {
+		t.Errorf("unexpected daemon status %+v", status)
+	}
+
+	rec := httptest.NewRecorder()
+	daemonStatusJSONHandler(rec, httptest.NewRequest("GET", "/status", nil))
+	var decoded daemonStatusStruct
+	if err := json.Unmarshal(rec.Body.Bytes(), &decoded); err != nil || len(decoded.Runs) < 2 || decoded.Schedule != "every 300ms" {
+		t.Errorf("unexpected /status response (%v): %s", err, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	daemonStatusHandler(rec, httptest.NewRequest("GET", "/", nil))
+	if !strings.Contains(rec.Body.String(), "<td>"+first.Duration+"</td><td>0</td><td>2</td>") {
+		t.Errorf("status page missing the first run:\n%s", rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	daemonStatusHandler(rec, httptest.NewRequest("GET", "/missing", nil))
+	if rec.Code != 404 {
+		t.Errorf("expected 404 for an unknown path, got %d", rec.Code)
+	}
+}
+
+func TestDaemonConfig(t *testing.T) {
+	for _, conf := range []daemonConfStruct{
+		{},
+		{Cron: "0 2 * * *", IntervalMinutes: 30},
+		{Cron: "0 2 * *"},
+		{IntervalMinutes: -5},
+		{IntervalMinutes: 30, CacheTTLMinutes: -1},
+	} {
+		importConf.Daemon = conf
+		if _, err := checkDaemonConfig(); err == nil {
+			t.Errorf("expected a configuration error for %+v", conf)
+		}
+	}
+	importConf.Daemon = daemonConfStruct{IntervalMinutes: 30}
+	if schedule, err := checkDaemonConfig(); err != nil || schedule.String() != "every 30m0s" {
+		t.Errorf("unexpected schedule %v (%v)", schedule, err)
+	}
+	importConf.Daemon = daemonConfStruct{}
+}
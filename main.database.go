@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"net/url"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"text/template"
@@ -13,74 +15,199 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
+// dbSource -- Source implementation for SQL databases, via the registered SQL drivers
+type dbSource struct {
+	conf       sourceConfStruct
+	key        keyDataStruct
+	driver     string
+	connString string
+	baseQuery  string
+}
+
+func init() {
+	registerSource(func(conf sourceConfStruct, key keyDataStruct) Source {
+		driver := strings.ToLower(conf.Source)
+		//Set SWSQLDriver to mysql320
+		if driver == "swsql" {
+			driver = "mysql320"
+		}
+		//lib/pq registers as postgres
+		if driver == "postgresql" {
+			driver = "postgres"
+		}
+		return &dbSource{conf: conf, key: key, driver: driver}
+	}, "mssql", "mysql", "mysql320", "swsql", "odbc", "postgres", "postgresql", "sqlite")
+}
+
+// ValidateConfig -- Builds the connection string from the Keysafe key and SourceConfig.Database
+func (s *dbSource) ValidateConfig() error {
+	s.connString = s.buildConnectionString()
+	if s.connString == "" {
+		return errors.New("[DATABASE] Database Connection String Empty. Check the SQLConf section of your configuration.")
+	}
+	s.baseQuery = s.conf.Database.Query
+	return nil
+}
+
+// GetAssets -- Runs the base query plus the asset type query against the database
+func (s *dbSource) GetAssets(assetType assetTypesStruct) (map[string]map[string]interface{}, error) {
+	return collectAssets(s, assetType)
+}
+
+// StreamAssets -- Runs the base query plus the asset type query against the database, emitting each row as it is scanned
+func (s *dbSource) StreamAssets(assetType assetTypesStruct, emit assetEmitter) error {
+	return s.queryAssets(assetType, emit)
+}
+
+// GetSoftwareInventory -- Runs the asset type software inventory query for a single asset
+func (s *dbSource) GetSoftwareInventory(assetRecord map[string]interface{}, assetType assetTypesStruct, buffer *bytes.Buffer) (map[string]map[string]interface{}, string, error) {
+	if assetType.SoftwareInventory.Query == "" || assetType.SoftwareInventory.AssetIDColumn == "" {
+		return make(map[string]map[string]interface{}), "", nil
+	}
+	swAssetID := getSoftwareAssetID(assetRecord, assetType)
+	if swAssetID == "" {
+		return make(map[string]map[string]interface{}), "", errors.New("unable to read software inventory records from source db, asset ID not found in db record")
+	}
+	//One DB connection per asset
+	db, err := s.connect()
+	if err != nil {
+		return make(map[string]map[string]interface{}), "", errors.New("Unable to read software inventory records from source DB:[DATABASE] " + err.Error())
+	}
+	defer db.Close()
+	softwareRecords, softwareRecordsHash, err := querySoftwareInventoryRecords(swAssetID, assetType, db, buffer)
+	if err != nil {
+		err = errors.New("Unable to read software inventory records from source DB:" + err.Error())
+	}
+	return softwareRecords, softwareRecordsHash, err
+}
+
 // buildConnectionString -- Build the connection string for the SQL driver
-func buildConnectionString() string {
-	if key.Database == "" ||
-		importConf.SourceConfig.Database.Authentication == "SQL" && (key.Username == "" || key.Password == "") {
+func (s *dbSource) buildConnectionString() string {
+	//SQLite databases are local files, so need no server or credentials
+	if s.driver == "sqlite" {
+		if s.key.Database == "" {
+			logger(4, "Database configuration not set, the Keysafe key database field must hold the SQLite file path.", true, true)
+			return ""
+		}
+		logger(3, "Opening SQLite Database: "+s.key.Database, true, true)
+		return sqliteConnectionString(s.key.Database)
+	}
+	if s.key.Database == "" ||
+		s.conf.Database.Authentication == "SQL" && (s.key.Username == "" || s.key.Password == "") {
 		//Conf not set - log error and return empty string
 		logger(4, "Database configuration not set.", true, true)
 		return ""
 	}
-	if importConf.SourceConfig.Source != "odbc" {
-		logger(3, "Connecting to Database Server: "+key.Server, true, true)
+	if s.driver != "odbc" {
+		logger(3, "Connecting to Database Server: "+s.key.Server, true, true)
 	} else {
-		logger(3, "Connecting to ODBC Data Source: "+key.Database, true, true)
+		logger(3, "Connecting to ODBC Data Source: "+s.key.Database, true, true)
 	}
 
 	connectString := ""
-	switch importConf.SourceConfig.Source {
+	switch s.driver {
 	case "mssql":
-		connectString = "server=" + key.Server
-		connectString = connectString + ";database=" + key.Database
-		if importConf.SourceConfig.Database.Authentication == "Windows" {
+		connectString = "server=" + s.key.Server
+		connectString = connectString + ";database=" + s.key.Database
+		if s.conf.Database.Authentication == "Windows" {
 			connectString = connectString + ";Trusted_Connection=True"
 		} else {
-			connectString = connectString + ";user id=" + key.Username
-			connectString = connectString + ";password=" + key.Password
+			connectString = connectString + ";user id=" + s.key.Username
+			connectString = connectString + ";password=" + s.key.Password
 		}
 
-		if !importConf.SourceConfig.Database.Encrypt {
+		if !s.conf.Database.Encrypt {
 			connectString = connectString + ";encrypt=disable"
 		}
-		if key.Port != 0 {
-			dbPortSetting := strconv.Itoa(int(key.Port))
+		if s.key.Port != 0 {
+			dbPortSetting := strconv.Itoa(int(s.key.Port))
 			connectString = connectString + ";port=" + dbPortSetting
 		}
 	case "mysql":
-		connectString = key.Username + ":" + key.Password
-		connectString = connectString + "@tcp(" + key.Server + ":"
-		if key.Port != 0 {
-			dbPortSetting := strconv.Itoa(int(key.Port))
+		connectString = s.key.Username + ":" + s.key.Password
+		connectString = connectString + "@tcp(" + s.key.Server + ":"
+		if s.key.Port != 0 {
+			dbPortSetting := strconv.Itoa(int(s.key.Port))
 			connectString = connectString + dbPortSetting
 		} else {
 			connectString = connectString + "3306"
 		}
-		connectString = connectString + ")/" + key.Database
+		connectString = connectString + ")/" + s.key.Database
 	case "mysql320":
 		dbPortSetting := "3306"
-		if key.Port != 0 {
-			dbPortSetting = strconv.Itoa(int(key.Port))
+		if s.key.Port != 0 {
+			dbPortSetting = strconv.Itoa(int(s.key.Port))
 		}
-		connectString = "tcp:" + key.Server + ":" + dbPortSetting
-		connectString = connectString + "*" + key.Database + "/" + key.Username + "/" + key.Password
+		connectString = "tcp:" + s.key.Server + ":" + dbPortSetting
+		connectString = connectString + "*" + s.key.Database + "/" + s.key.Username + "/" + s.key.Password
 	case "odbc":
-		connectString = "DSN=" + key.Database + ";UID=" + key.Username + ";PWD=" + key.Password
+		connectString = "DSN=" + s.key.Database + ";UID=" + s.key.Username + ";PWD=" + s.key.Password
+	case "postgres":
+		dbPortSetting := "5432"
+		if s.key.Port != 0 {
+			dbPortSetting = strconv.Itoa(int(s.key.Port))
+		}
+		sslMode := s.conf.Database.SSLMode
+		if sslMode == "" {
+			sslMode = "disable"
+			if s.conf.Database.Encrypt {
+				sslMode = "require"
+			}
+		}
+		params := [][2]string{
+			{"host", s.key.Server},
+			{"port", dbPortSetting},
+			{"dbname", s.key.Database},
+			{"user", s.key.Username},
+			{"password", s.key.Password},
+			{"sslmode", sslMode},
+		}
+		if s.conf.Database.SearchPath != "" {
+			params = append(params, [2]string{"search_path", s.conf.Database.SearchPath})
+		}
+		var pairs []string
+		for _, param := range params {
+			if param[1] != "" {
+				pairs = append(pairs, param[0]+"="+postgresQuoteValue(param[1]))
+			}
+		}
+		connectString = strings.Join(pairs, " ")
 	}
 
-	BaseSQLQuery = importConf.SourceConfig.Database.Query
-
 	return connectString
 }
 
-func makeDBConnection() (db *sqlx.DB, err error) {
+// postgresQuoteValue -- quotes a value for a PostgreSQL key/value connection string
+func postgresQuoteValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+// sqliteConnectionString -- returns a read only URI for a SQLite database file
+func sqliteConnectionString(path string) string {
+	path = filepath.ToSlash(path)
+	//Windows drive paths need a leading slash in a file URI
+	if filepath.VolumeName(path) != "" {
+		path = "/" + path
+	}
+	uri := (&url.URL{Path: path}).EscapedPath()
+	if strings.HasPrefix(uri, "/") {
+		return "file://" + uri + "?mode=ro"
+	}
+	return "file:" + uri + "?mode=ro"
+}
+
+// connect -- opens a connection to the source database
+func (s *dbSource) connect() (db *sqlx.DB, err error) {
 	//Connect to the config specified DB
-	db, err = sqlx.Open(importConf.SourceConfig.Source, connString)
+	db, err = sqlx.Open(s.driver, s.connString)
 	if err != nil {
 		err = errors.New("DB Connection Error: " + err.Error())
 		return
 	}
 	//Check connection is open
-	err = db.Ping()
+	err = db.PingContext(importCtx)
 	if err != nil {
 		err = errors.New("DB Ping Error: " + err.Error())
 		return
@@ -89,33 +216,33 @@ func makeDBConnection() (db *sqlx.DB, err error) {
 }
 
 // queryAssets -- Query Asset Database for assets of current type
-// -- Builds map of assets, returns true if successful
-func queryAssets(sqlAppend string, assetType assetTypesStruct) (bool, map[string]map[string]interface{}) {
-	//Initialise Asset Map
-	arrAssetMaps := make(map[string]map[string]interface{})
-
-	db, err := makeDBConnection()
+// -- Emits each asset as it is scanned
+func (s *dbSource) queryAssets(assetType assetTypesStruct, emit assetEmitter) error {
+	db, err := s.connect()
 	if err != nil {
-		logger(4, "[DATABASE] "+err.Error(), true, true)
-		return false, arrAssetMaps
+		return errors.New("[DATABASE] " + err.Error())
 	}
 	defer db.Close()
 	logger(3, " ", false, false)
 	logger(3, "[DATABASE] Running database query for "+assetType.AssetType+" assets. Please wait...", true, true)
 	//build query
-	sqlAssetQuery := BaseSQLQuery + " " + sqlAppend
+	sqlAssetQuery := s.baseQuery + " " + assetType.Query
 	logger(3, "[DATABASE] Query for "+assetType.AssetType+" assets:"+sqlAssetQuery, false, true)
 	//Run Query
-	rows, err := db.Queryx(sqlAssetQuery)
+	rows, err := db.QueryxContext(importCtx, sqlAssetQuery)
 	if err != nil {
-		logger(4, " [DATABASE] Database Query Error: "+err.Error(), true, true)
-		return false, arrAssetMaps
+		return errors.New(" [DATABASE] Database Query Error: " + err.Error())
 	}
 	defer rows.Close()
 
 	//Build map full of assets
 	intAssetCount := 0
 	intAssetSuccess := 0
+	//Native date and time values of the columns compared between records are formatted to sort as strings
+	compareColumns := map[string]bool{assetType.WatermarkColumn: true}
+	if assetType.Duplicates != nil {
+		compareColumns[assetType.Duplicates.Column] = true
+	}
 	for rows.Next() {
 		intAssetCount++
 		results := make(map[string]interface{})
@@ -126,9 +253,14 @@ func queryAssets(sqlAppend string, assetType assetTypesStruct) (bool, map[string
 			//Stick marshalled data map in to parent slice
 			for k, val := range results {
 				if results[k] != nil {
-					results[k] = iToS(val)
+					if compareColumns[k] {
+						results[k] = watermarkValue(val)
+					} else {
+						results[k] = iToS(val)
+					}
 				}
 			}
+			var assetID string
 			assetIDIdent := fmt.Sprintf("%v", assetType.AssetIdentifier.SourceColumn)
 			matched := regexTemplate.MatchString(assetIDIdent)
 			if matched {
@@ -137,18 +269,21 @@ func queryAssets(sqlAppend string, assetType assetTypesStruct) (bool, map[string
 				tmpl, _ := t.Parse(assetIDIdent)
 				buf := bytes.NewBufferString("")
 				tmpl.Execute(buf, results)
-
-				if buf != nil {
-					assetID := buf.String()
-					arrAssetMaps[assetID] = results
-				}
+				assetID = buf.String()
 			} else {
-				arrAssetMaps[fmt.Sprintf("%s", results[assetType.AssetIdentifier.SourceColumn])] = results
+				assetID = fmt.Sprintf("%s", results[assetType.AssetIdentifier.SourceColumn])
+			}
+			intAssetSuccess++
+			if !emit(assetID, results) {
+				return nil
 			}
 		}
 	}
+	if err = rows.Err(); err != nil {
+		return errors.New(" [DATABASE] Database Query Error: " + err.Error())
+	}
 	logger(3, "[DATABASE] "+strconv.Itoa(intAssetSuccess)+" of "+strconv.Itoa(intAssetCount)+" returned assets successfully retrieved ready for processing.", true, true)
-	return true, arrAssetMaps
+	return nil
 }
 
 func querySoftwareInventoryRecords(assetID string, assetTypeDetails assetTypesStruct, db *sqlx.DB, buffer *bytes.Buffer) (map[string]map[string]interface{}, string, error) {
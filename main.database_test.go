@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestBuildConnectionString(t *testing.T) {
+	m := newImportTest(t)
+	//Only loaded to initialise the logging XMLMC session
+	loadTestConfig(t, m, map[string]interface{}{"Source": "csv"}, desktopAssetType(nil))
+
+	tests := []struct {
+		name   string
+		source string
+		conf   dbConfStruct
+		key    keyDataStruct
+		want   string
+	}{
+		{
+			name:   "postgres defaults",
+			source: "postgresql",
+			conf:   dbConfStruct{Authentication: "SQL"},
+			key:    keyDataStruct{Server: "db.example.com", Database: "glpi", Username: "import", Password: "pa'ss word"},
+			want:   `host='db.example.com' port='5432' dbname='glpi' user='import' password='pa\'ss word' sslmode='disable'`,
+		},
+		{
+			name:   "postgres sslmode and search_path",
+			source: "postgres",
+			conf:   dbConfStruct{Authentication: "SQL", SSLMode: "verify-full", SearchPath: "snipeit,public"},
+			key:    keyDataStruct{Server: "db.example.com", Port: 6432, Database: "snipeit", Username: "import", Password: "secret"},
+			want:   `host='db.example.com' port='6432' dbname='snipeit' user='import' password='secret' sslmode='verify-full' search_path='snipeit,public'`,
+		},
+		{
+			name:   "postgres encrypt",
+			source: "postgres",
+			conf:   dbConfStruct{Authentication: "SQL", Encrypt: true},
+			key:    keyDataStruct{Server: "db.example.com", Database: "ocs", Username: "import", Password: "secret"},
+			want:   `host='db.example.com' port='5432' dbname='ocs' user='import' password='secret' sslmode='require'`,
+		},
+		{
+			name:   "sqlite",
+			source: "sqlite",
+			key:    keyDataStruct{Database: "/data/asset inventory.db"},
+			want:   "file:///data/asset%20inventory.db?mode=ro",
+		},
+		{
+			name:   "sqlite relative path",
+			source: "sqlite",
+			key:    keyDataStruct{Database: "assets.db"},
+			want:   "file:assets.db?mode=ro",
+		},
+		{
+			name:   "sqlite without path",
+			source: "sqlite",
+			want:   "",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			src, err := newSource(sourceConfStruct{Source: test.source, Database: test.conf}, test.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := src.(*dbSource).buildConnectionString(); got != test.want {
+				t.Errorf("buildConnectionString() = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
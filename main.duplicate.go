@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Duplicates policies, deciding which of the source records sharing an asset identifier is imported
+const (
+	duplicatePolicyFirst  = "first"
+	duplicatePolicyLast   = "last"
+	duplicatePolicyNewest = "newest"
+	duplicatePolicyFail   = "fail"
+)
+
+// checkDuplicatesConfig -- Validates the Duplicates configuration of an asset type
+func checkDuplicatesConfig(assetType assetTypesStruct) error {
+	switch duplicatePolicy(assetType) {
+	case duplicatePolicyFirst, duplicatePolicyLast, duplicatePolicyFail:
+	case duplicatePolicyNewest:
+		if assetType.Duplicates.Column == "" {
+			return errors.New("Duplicates Policy Newest requires a Column")
+		}
+	default:
+		return errors.New("unsupported Duplicates Policy: " + assetType.Duplicates.Policy)
+	}
+	return nil
+}
+
+// duplicatePolicy -- returns the Duplicates policy of an asset type, defaulting to keeping the first record
+func duplicatePolicy(assetType assetTypesStruct) string {
+	if assetType.Duplicates == nil || assetType.Duplicates.Policy == "" {
+		return duplicatePolicyFirst
+	}
+	return strings.ToLower(assetType.Duplicates.Policy)
+}
+
+// resolveDuplicates -- applies the last, newest and fail policies, which need every source record read before
+// any can be processed. The records to process are returned as a new stream, in source order, or an error when
+// the fail policy finds duplicates. The first policy is applied by processAssets, so records still stream
+func resolveDuplicates(stream *assetStreamStruct, assetType assetTypesStruct, assetSource Source) (*assetStreamStruct, error) {
+	policy := duplicatePolicy(assetType)
+	if policy == duplicatePolicyFirst {
+		return stream, nil
+	}
+	var (
+		records    []sourceAssetStruct
+		index      = make(map[string]int)
+		duplicates = 0
+	)
+	for {
+		asset, ok := stream.next()
+		if !ok {
+			break
+		}
+		asset.ID = getSourceAssetID(assetSource, assetType, asset.Record)
+		i, seen := index[asset.ID]
+		if !seen {
+			index[asset.ID] = len(records)
+			records = append(records, asset)
+			continue
+		}
+		kept := records[i]
+		switch policy {
+		case duplicatePolicyLast:
+			records[i] = asset
+			logDuplicate(assetType, asset.ID, kept, asset, "record "+strconv.Itoa(kept.Position)+" skipped")
+		case duplicatePolicyNewest:
+			//Compared as the WatermarkColumn is, numerically when both are numbers, otherwise as strings
+			value := watermarkValue(asset.Record[assetType.Duplicates.Column])
+			if value != "" && watermarkGreater(value, watermarkValue(kept.Record[assetType.Duplicates.Column])) {
+				records[i] = asset
+				logDuplicate(assetType, asset.ID, kept, asset, "record "+strconv.Itoa(kept.Position)+" skipped, as record "+strconv.Itoa(asset.Position)+" has a newer "+assetType.Duplicates.Column)
+			} else {
+				logDuplicate(assetType, asset.ID, kept, asset, "record "+strconv.Itoa(asset.Position)+" skipped, as record "+strconv.Itoa(kept.Position)+" has a newer "+assetType.Duplicates.Column)
+			}
+		case duplicatePolicyFail:
+			duplicates++
+			logDuplicate(assetType, asset.ID, kept, asset, "asset type will not be processed")
+		}
+	}
+	err := stream.close()
+	if duplicates > 0 {
+		message := strconv.Itoa(duplicates) + " duplicate asset identifier(s) found in the source, with Duplicates Policy Fail. No assets were processed"
+		if err != nil {
+			message += ". The source also failed: " + err.Error()
+		}
+		return nil, errors.New(message)
+	}
+	return bufferedStream(records, err), nil
+}
+
+// logDuplicate -- logs and counts a source record sharing its asset identifier with an earlier record, with both
+// records where they are held. With the first policy the earlier record has already been processed, so only its
+// position is logged
+func logDuplicate(assetType assetTypesStruct, assetID string, first, duplicate sourceAssetStruct, action string) {
+	incCounter(assetType.AssetType, counterDuplicatesFound)
+
+	message := "Duplicate " + assetType.AssetType + " asset identifier [" + assetID + "] in source records " +
+		strconv.Itoa(first.Position) + " and " + strconv.Itoa(duplicate.Position) + ", " + action
+	for _, asset := range []sourceAssetStruct{first, duplicate} {
+		if asset.Record == nil {
+			continue
+		}
+		jsonBytes, _ := json.Marshal(asset.Record)
+		message += ". Record " + strconv.Itoa(asset.Position) + ": " + string(jsonBytes)
+	}
+	logger(5, message, false, true)
+}
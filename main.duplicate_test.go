@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+const duplicatesCSV = `Name,SerialNumber,Model,Owner,Site,Company,Updated
+Desktop001,SERIAL001,Inspiron 3891,jsmith,Head Office,Acme,2026-01-02
+Desktop002,SERIAL002,Inspiron 3891,bjones,Branch Office,Acme,2026-03-04
+Newest001,SERIAL001,Inspiron 3891,jsmith,Head Office,Acme,2026-05-01
+Last001,SERIAL001,Inspiron 3891,jsmith,Head Office,Acme,2025-12-31
+`
+
+func TestImportDuplicates(t *testing.T) {
+	tests := []struct {
+		policy  string
+		created uint64
+		name    string
+	}{
+		{"", 2, "Desktop001"},
+		{"first", 2, "Desktop001"},
+		{"last", 2, "Last001"},
+		{"Newest", 2, "Newest001"},
+		{"fail", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run("policy "+tt.policy, func(t *testing.T) {
+			m := newImportTest(t)
+			os.WriteFile("desktops.csv", []byte(duplicatesCSV), 0644)
+			loadTestConfig(t, m,
+				map[string]interface{}{"Source": "csv", "CSV": map[string]interface{}{"CommaCharacter": ","}},
+				desktopAssetType(map[string]interface{}{
+					"CSVFile":    "desktops.csv",
+					"Duplicates": map[string]interface{}{"Policy": tt.policy, "Column": "Updated"},
+				}))
+
+			runImport()
+
+			if counterTotal(counterCreated) != tt.created {
+				t.Errorf("expected %d assets created, got %d", tt.created, counterTotal(counterCreated))
+			}
+			if counterTotal(counterDuplicatesFound) != 2 {
+				t.Errorf("expected 2 duplicates found, got %d", counterTotal(counterDuplicatesFound))
+			}
+			asset := m.FindAsset("h_serial_number", "SERIAL001")
+			if tt.name == "" {
+				if asset != nil {
+					t.Errorf("expected no assets imported, got %v", asset)
+				}
+			} else if asset == nil || asset["h_name"] != tt.name {
+				t.Errorf("expected SERIAL001 imported from the %s record, got %v", tt.name, asset)
+			}
+		})
+	}
+
+	for policy, column := range map[string]string{"newest": "", "random": "Updated"} {
+		assetType := assetTypesStruct{Duplicates: &duplicatesConfStruct{Policy: policy, Column: column}}
+		if err := checkDuplicatesConfig(assetType); err == nil {
+			t.Errorf("expected a configuration error for policy %q with column %q", policy, column)
+		}
+	}
+}
+
+func TestResolveDuplicatesFailSourceError(t *testing.T) {
+	newImportTest(t)
+	assetType := assetTypesStruct{AssetType: "Desktop", Duplicates: &duplicatesConfStruct{Policy: "Fail"}}
+	assetType.AssetIdentifier.SourceColumn = "SerialNumber"
+	records := []sourceAssetStruct{
+		{Record: map[string]interface{}{"SerialNumber": "SERIAL001"}, Position: 1},
+		{Record: map[string]interface{}{"SerialNumber": "SERIAL001"}, Position: 2},
+	}
+
+	//The source error is reported along with the duplicates
+	_, err := resolveDuplicates(bufferedStream(records, errors.New("connection reset")), assetType, nil)
+	if err == nil || !strings.Contains(err.Error(), "1 duplicate") || !strings.Contains(err.Error(), "connection reset") {
+		t.Errorf("expected the duplicates and the source error, got %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Exit codes for the outcome of the import. exitCancelled (130) is used when the import is interrupted, and 100, 101
+// and 102 when the log file, log folder or configuration file can't be opened. The failure codes can be changed with
+// the ExitCodes configuration
+const (
+	// Every AssetType was read and every asset imported, or any failures were within the ExitCodes Thresholds
+	exitSuccess = 0
+	// The configuration is invalid, or the import could not be started
+	exitConfigError = 1
+	// One or more AssetTypes could not be read from their source, or prepared for import
+	exitSourceFailure = 2
+	// Some assets, or their software, supplier or orphan updates, failed to import
+	exitPartialFailure = 3
+	// Assets failed to import, and none were imported successfully
+	exitTotalFailure = 4
+)
+
+// exitThresholdStruct -- a failure counter that can be given a threshold, as a percentage of the attempts
+type exitThresholdStruct struct {
+	name     string
+	failed   uint64
+	attempts uint64
+}
+
+// exitThresholds -- returns the failure counters that decide whether the import partially failed, from the totals
+func exitThresholds(c counterSetStruct) []exitThresholdStruct {
+	return []exitThresholdStruct{
+		{"CreateFailed", c[counterCreateFailed], c[counterCreated] + c[counterCreateFailed]},
+		{"UpdateFailed", c[counterUpdateFailed], c[counterUpdated] + c[counterUpdateFailed]},
+		{"UpdateExtendedRecordFailed", c[counterUpdateRelatedFailed], c[counterUpdated] + c[counterUpdateFailed]},
+		{"SoftwareCreateFailed", c[counterSoftwareCreateFailed], c[counterSoftwareCreated] + c[counterSoftwareCreateFailed]},
+		{"SoftwareRemoveFailed", c[counterSoftwareRemoveFailed], c[counterSoftwareRemoved] + c[counterSoftwareRemoveFailed]},
+		{"SupplierAssociationsFailed", c[counterSuppliersAssociatedFailed], c[counterSuppliersAssociatedSuccess] + c[counterSuppliersAssociatedFailed]},
+		{"SupplierContractAssociationsFailed", c[counterSupplierContractsAssociatedFailed], c[counterSupplierContractsAssociatedSuccess] + c[counterSupplierContractsAssociatedFailed]},
+		{"OrphansFailed", c[counterOrphansFailed], c[counterOrphansActioned] + c[counterOrphansFailed]},
+	}
+}
+
+// checkExitCodesConfig -- Validates the ExitCodes configuration
+func checkExitCodesConfig() error {
+	conf := importConf.ExitCodes
+	for name, code := range map[string]int{"SourceFailure": conf.SourceFailure, "PartialFailure": conf.PartialFailure, "TotalFailure": conf.TotalFailure} {
+		if code < 0 || code > 125 {
+			return errors.New("ExitCodes " + name + " must be between 1 and 125, or 0 for the default")
+		}
+	}
+	var names []string
+	for _, threshold := range exitThresholds(counterSetStruct{}) {
+		names = append(names, threshold.name)
+	}
+	for name, percent := range conf.Thresholds {
+		found := false
+		for _, known := range names {
+			if strings.EqualFold(name, known) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return errors.New("unsupported ExitCodes Thresholds counter [" + name + "], supported counters are: " + strings.Join(names, ", "))
+		}
+		if percent < 0 || percent > 100 {
+			return errors.New("ExitCodes Thresholds [" + name + "] must be between 0 and 100")
+		}
+	}
+	return nil
+}
+
+// exitThresholdPercent -- returns the percentage of failures allowed for a counter, which is 0 unless configured
+func exitThresholdPercent(name string) float64 {
+	for thresholdName, percent := range importConf.ExitCodes.Thresholds {
+		if strings.EqualFold(thresholdName, name) {
+			return percent
+		}
+	}
+	return 0
+}
+
+// importExitCode -- returns the exit code for the outcome of the import, computed from the counters, and the reasons
+// for it. When more than one applies, the import being cancelled is reported first, then a total failure, a source
+// failure and a partial failure
+func importExitCode() (int, []string) {
+	if importCancelled() {
+		return exitCancelled, []string{"import cancelled"}
+	}
+	c := counterSnapshot().total
+
+	var exceeded []string
+	for _, threshold := range exitThresholds(c) {
+		if threshold.failed == 0 {
+			continue
+		}
+		//Extended record updates can fail for assets whose main record was unchanged, so are not always among the
+		//attempts counted. Failures are never a greater share than all of the attempts
+		if threshold.attempts < threshold.failed {
+			threshold.attempts = threshold.failed
+		}
+		percent := float64(threshold.failed) / float64(threshold.attempts) * 100
+		allowed := exitThresholdPercent(threshold.name)
+		if percent > allowed {
+			exceeded = append(exceeded, threshold.name+" "+fmt.Sprintf("%.1f", percent)+"% ("+strconv.FormatUint(threshold.failed, 10)+" of "+strconv.FormatUint(threshold.attempts, 10)+") above the "+fmt.Sprintf("%v", allowed)+"% threshold")
+		}
+	}
+	sort.Strings(exceeded)
+
+	conf := importConf.ExitCodes
+	failedAssets := c[counterCreateFailed] + c[counterUpdateFailed]
+	if failedAssets > 0 && c[counterCreated]+c[counterUpdated]+c[counterUpdateSkipped] == 0 {
+		return exitCodeOrDefault(conf.TotalFailure, exitTotalFailure), []string{strconv.FormatUint(failedAssets, 10) + " asset(s) failed to import, and none were imported successfully"}
+	}
+	if c[counterAssetTypesFailed] > 0 {
+		return exitCodeOrDefault(conf.SourceFailure, exitSourceFailure), append([]string{strconv.FormatUint(c[counterAssetTypesFailed], 10) + " AssetType(s) could not be read from their source or prepared for import"}, exceeded...)
+	}
+	if len(exceeded) > 0 {
+		return exitCodeOrDefault(conf.PartialFailure, exitPartialFailure), exceeded
+	}
+	return exitSuccess, nil
+}
+
+// assetTypeFailed -- logs why an AssetType could not be imported, and counts it towards a source failure
+func assetTypeFailed(assetType assetTypesStruct, message string) {
+	logger(4, "AssetType: "+assetType.AssetType+" "+message, true, true)
+	incCounter(assetType.AssetType, counterAssetTypesFailed)
+}
+
+func exitCodeOrDefault(code, defaultCode int) int {
+	if code == 0 {
+		return defaultCode
+	}
+	return code
+}
+
+// logExitCode -- outputs the exit code for the outcome of the import, and the reasons for it
+func logExitCode(code int, reasons []string) {
+	if code == exitSuccess {
+		logger(3, "Exit Code: 0", true, true)
+		return
+	}
+	logger(4, "Exit Code: "+strconv.Itoa(code)+" - "+strings.Join(reasons, "; "), true, true)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestImportExitCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		counters counterSetStruct
+		conf     exitCodesConfStruct
+		want     int
+	}{
+		{"success", counterSetStruct{counterCreated: 10, counterUpdated: 5}, exitCodesConfStruct{}, exitSuccess},
+		{"partial failure", counterSetStruct{counterUpdated: 19, counterUpdateFailed: 1}, exitCodesConfStruct{}, exitPartialFailure},
+		{"within threshold", counterSetStruct{counterUpdated: 19, counterUpdateFailed: 1}, exitCodesConfStruct{Thresholds: map[string]float64{"updatefailed": 5}}, exitSuccess},
+		{"above threshold", counterSetStruct{counterUpdated: 19, counterUpdateFailed: 1}, exitCodesConfStruct{Thresholds: map[string]float64{"UpdateFailed": 4}}, exitPartialFailure},
+		{"other counters keep no threshold", counterSetStruct{counterUpdated: 19, counterUpdateFailed: 1, counterSoftwareCreated: 9, counterSoftwareCreateFailed: 1}, exitCodesConfStruct{Thresholds: map[string]float64{"UpdateFailed": 5}}, exitPartialFailure},
+		{"total failure", counterSetStruct{counterCreateFailed: 2, counterUpdateFailed: 1}, exitCodesConfStruct{}, exitTotalFailure},
+		{"source failure", counterSetStruct{counterCreated: 1, counterAssetTypesFailed: 1}, exitCodesConfStruct{}, exitSourceFailure},
+		{"configured code", counterSetStruct{counterCreated: 1, counterOrphansFailed: 1}, exitCodesConfStruct{PartialFailure: 10}, 10},
+		{"failures without attempts", counterSetStruct{counterUpdateSkipped: 1, counterUpdateRelatedFailed: 1}, exitCodesConfStruct{Thresholds: map[string]float64{"UpdateExtendedRecordFailed": 50}}, exitPartialFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetCounters()
+			for counter, n := range tt.counters {
+				addCounter("Desktop", counterName(counter), n)
+			}
+			importConf.ExitCodes = tt.conf
+			if got, reasons := importExitCode(); got != tt.want {
+				t.Errorf("exit code = %d, want %d (%v)", got, tt.want, reasons)
+			}
+			if _, reasons := importExitCode(); strings.Contains(strings.Join(reasons, "; "), "Inf") {
+				t.Errorf("reasons hold an infinite percentage: %v", reasons)
+			}
+		})
+	}
+
+	for _, conf := range []exitCodesConfStruct{
+		{TotalFailure: 200},
+		{Thresholds: map[string]float64{"Unknown": 5}},
+		{Thresholds: map[string]float64{"CreateFailed": 101}},
+		{SourceFailure: -1},
+	} {
+		importConf.ExitCodes = conf
+		if err := checkExitCodesConfig(); err == nil {
+			t.Errorf("expected a configuration error for %+v", conf)
+		}
+	}
+	//0 leaves the default exit code
+	importConf.ExitCodes = exitCodesConfStruct{SourceFailure: 0, PartialFailure: 125}
+	if err := checkExitCodesConfig(); err != nil {
+		t.Errorf("expected 0 and 125 to be valid exit codes, got %v", err)
+	}
+	importConf.ExitCodes = exitCodesConfStruct{}
+}
+
+func TestImportExitCodeSourceFailure(t *testing.T) {
+	m := newImportTest(t)
+	loadTestConfig(t, m,
+		map[string]interface{}{"Source": "csv", "CSV": map[string]interface{}{"CommaCharacter": ","}},
+		desktopAssetType(map[string]interface{}{"CSVFile": "missing.csv"}))
+
+	if got := runImport(); got != exitSourceFailure {
+		t.Errorf("expected exit code %d for a missing CSV file, got %d", exitSourceFailure, got)
+	}
+
+	os.WriteFile("missing.csv", []byte(testCSV), 0644)
+	resetCounters()
+	if got := runImport(); got != exitSuccess {
+		t.Errorf("expected exit code %d once the CSV file exists, got %d", exitSuccess, got)
+	}
+}
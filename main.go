@@ -19,6 +19,8 @@ import (
 	_ "github.com/denisenkom/go-mssqldb"
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/hornbill/mysql320"
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
 )
 
 // ----- Main Function -----
@@ -33,6 +35,11 @@ func main() {
 	flag.IntVar(&configMaxRoutines, "concurrent", 1, "Maximum number of Assets to import concurrently.")
 	flag.BoolVar(&configVersion, "version", false, "Return version and end")
 	flag.BoolVar(&configForceUpdates, "forceupdates", false, "Force updates (ignoring hash calculation; CI only - NOT software (type needs to be set to Update or Both))")
+	flag.BoolVar(&configFullImport, "full", false, "Ignore stored watermarks, and import all records from the source")
+	flag.BoolVar(&configResume, "resume", false, "Skip assets already completed by an interrupted run, where the source data is unchanged")
+	flag.StringVar(&configReportFile, "report", "", "Write a report of the outcome of each asset to this file, as CSV if it has a .csv extension, otherwise as JSON")
+	flag.BoolVar(&configDaemon, "daemon", false, "Run continuously, importing on the Daemon schedule in the configuration file")
+	flag.StringVar(&configLogFormat, "logformat", logFormatText, "Format of the log file: text, or json for a JSON object per line")
 	flag.Parse()
 
 	//-- If configVersion just output version number and die
@@ -40,6 +47,10 @@ func main() {
 		fmt.Printf("%v \n", version)
 		return
 	}
+	if !checkLogFormat() {
+		color.Red("Unsupported -logformat " + configLogFormat + ", use text or json")
+		os.Exit(exitConfigError)
+	}
 
 	//--
 	//-- Load Configuration File Into Struct
@@ -68,164 +79,254 @@ func main() {
 	logger(1, "Flag - Config File "+configFileName, true, true)
 	logger(1, "Flag - Dry Run "+fmt.Sprintf("%v", configDryRun), true, true)
 	logger(1, "Flag - Concurrent "+strconv.Itoa(configMaxRoutines), true, true)
+	logger(1, "Flag - Full Import "+fmt.Sprintf("%v", configFullImport), true, true)
+	logger(1, "Flag - Resume "+fmt.Sprintf("%v", configResume), true, true)
+	if configReportFile != "" {
+		logger(1, "Flag - Report "+configReportFile, true, true)
+	}
+	logger(1, "Flag - Daemon "+fmt.Sprintf("%v", configDaemon), true, true)
+	logger(1, "Flag - Log Format "+configLogFormat, true, true)
 
 	if configMaxRoutines < 1 || configMaxRoutines > maxGoRoutines {
 		color.Red("The maximum concurrent value allowed is between 1 and 10 (inclusive).\n\n")
 		color.Red("You have selected " + strconv.Itoa(configMaxRoutines) + ". Please try again, with a valid value against ")
 		color.Red("the -concurrent argument.")
-		return
+		os.Exit(exitConfigError)
 	}
 
-	if importConf.KeysafeKeyID != 0 {
-		getKeysafeKey(importConf.KeysafeKeyID)
-	}
-	//Set SWSQLDriver to mysql320
-	if importConf.SourceConfig.Source == "swsql" {
-		importConf.SourceConfig.Source = "mysql320"
+	handleSignals()
+	if configDaemon {
+		os.Exit(runDaemon())
 	}
+	os.Exit(importAndRecord())
+}
 
-	processCaching()
+// importAndRecord -- Runs the import, then records its metrics and writes the metrics text file.
+// Returns the exit code for the outcome of the import
+func importAndRecord() int {
+	exitCode := runImport()
+	recordRunMetrics(exitCode)
+	err := writeMetricsTextFile()
+	if err != nil {
+		logger(4, err.Error(), true, true)
+	}
+	return exitCode
+}
 
-	//Get asset types, process accordingly
-	configCSV = (strings.ToLower(importConf.SourceConfig.Source) == "csv")
-	configNexthink = (strings.ToLower(importConf.SourceConfig.Source) == "nexthink")
-	configLDAP = (strings.EqualFold(importConf.SourceConfig.Source, "ldap"))
-	configGoogle = (strings.EqualFold(importConf.SourceConfig.Source, "google"))
-	configCertero = (strings.EqualFold(importConf.SourceConfig.Source, "certero"))
-	configWorkspaceOne = (strings.EqualFold(importConf.SourceConfig.Source, "workspaceone"))
-	configDB = (!configCSV && !configNexthink && !configLDAP && !configGoogle && !configCertero && !configWorkspaceOne)
+// runImport -- Loads the asset sources and Hornbill caches, imports each asset type and outputs the summary.
+// Returns the exit code for the outcome of the import
+func runImport() int {
+	err := checkRedactConfig()
+	if err != nil {
+		logger(4, err.Error(), true, true)
+		return exitConfigError
+	}
+	globalKey, err := getKeysafeKey(importConf.KeysafeKeyID)
+	if err != nil {
+		logger(4, err.Error(), true, true)
+		return exitConfigError
+	}
 
-	if configDB {
-		//Build DB connection string
-		connString = buildConnectionString()
-		if connString == "" {
-			logger(4, " [DATABASE] Database Connection String Empty. Check the SQLConf section of your configuration.", true, true)
-			return
+	//Get the asset source for each asset type, and check their configuration
+	assetSources, err := loadSources(globalKey)
+	if err != nil {
+		logger(4, err.Error(), true, true)
+		return exitConfigError
+	}
+	err = checkUserMatchConfig()
+	if err != nil {
+		logger(4, err.Error(), true, true)
+		return exitConfigError
+	}
+	err = checkRetryConfig()
+	if err != nil {
+		logger(4, err.Error(), true, true)
+		return exitConfigError
+	}
+	err = checkRateLimitConfig()
+	if err != nil {
+		logger(4, err.Error(), true, true)
+		return exitConfigError
+	}
+	err = checkExitCodesConfig()
+	if err != nil {
+		logger(4, err.Error(), true, true)
+		return exitConfigError
+	}
+	err = checkMetricsConfig()
+	if err != nil {
+		logger(4, err.Error(), true, true)
+		return exitConfigError
+	}
+	initRateLimiter()
+	for _, v := range importConf.AssetTypes {
+		err = checkOrphanConfig(v)
+		if err != nil {
+			logger(4, "AssetType: "+v.AssetType+" "+err.Error(), true, true)
+			return exitConfigError
+		}
+		err = checkDuplicatesConfig(v)
+		if err != nil {
+			logger(4, "AssetType: "+v.AssetType+" "+err.Error(), true, true)
+			return exitConfigError
 		}
 	}
+
+	err = loadState()
+	if err != nil {
+		logger(4, err.Error(), true, true)
+		return exitConfigError
+	}
+
+	err = openJournal()
+	if err != nil {
+		logger(4, err.Error(), true, true)
+		return exitConfigError
+	}
+
+	if cachesExpired() {
+		processCaching()
+	} else {
+		logger(3, "Using the Hornbill records cached at "+cachesLoaded.Format("2006-01-02 15:04:05"), true, true)
+	}
+
 	setTemplateFilters()
 
 	templateFault := checkTemplate()
 	if templateFault {
 		logger(4, " [Template] Issues were found with the template.", true, true)
-		return
+		return exitConfigError
 	}
 
-	for _, v := range importConf.AssetTypes {
-		StrAssetType = v.AssetType
-		StrSQLAppend = v.Query
-		//Set Asset Class & Type vars from instance
-		if !strings.HasPrefix(v.AssetType, "__all__:") {
-			AssetClass, AssetTypeID = getAssetClass(StrAssetType)
-			v.TypeID = AssetTypeID
-			v.Class = AssetClass
-			debugLog(nil, "Asset Type and Class:", StrAssetType, strconv.Itoa(AssetTypeID), AssetClass)
-		} else {
-			if !strings.EqualFold(v.OperationType, "Update") {
-				logger(4, "AssetType: "+v.AssetType+" has an unsupported OperationType defined: "+v.OperationType, true, true)
-				continue
-			}
-			v.TypeID = 0
-			v.Class = strings.Split(v.AssetType, ":")[1]
+	for i, v := range importConf.AssetTypes {
+		if importCancelled() {
+			logger(5, "Import cancelled, AssetType "+v.AssetType+" not processed", true, true)
+			continue
 		}
+		importAssetType(v, assetSources[i])
+	}
 
-		//-- Query Data Source
-		boolSQLAssets := false
-		var arrAssets map[string]map[string]interface{}
-		if configCSV {
-			//-- Read CSV
-			boolSQLAssets, arrAssets = getAssetsFromCSV(v)
-		} else if configNexthink {
-			//-- Query Nexthink
-			arrAssets, err = getAssetsFromNexthink(v)
-			if err != nil {
-				logger(4, err.Error(), true, true)
-			} else {
-				boolSQLAssets = true
-			}
-		} else if configLDAP {
-			//-- Query LDAP
-			arrAssets, boolSQLAssets = queryLDAP(v)
-		} else if configGoogle {
-			//-- Query Google
-			arrAssets, err = getAssetsFromGoogle(v)
-			if err != nil {
-				logger(4, err.Error(), true, true)
-			} else {
-				boolSQLAssets = true
-			}
-		} else if configCertero {
-			//-- Query Certero
-			arrAssets, err = getAssetsFromCertero(v)
-			if err != nil {
-				logger(4, err.Error(), true, true)
-			} else {
-				boolSQLAssets = true
-			}
-		} else if configWorkspaceOne {
-			tokenObj, err := generateWorkspaceOneAccessToken()
-			if err != nil {
-				logger(4, err.Error(), true, true)
-			} else {
-				key.AccessToken = tokenObj.AccessToken
-				arrAssets, err = getAssetsFromWorkspaceOne(v)
-				if err != nil {
-					logger(4, err.Error(), true, true)
-				} else {
-					boolSQLAssets = true
-				}
-			}
-		} else {
-			//-- Query database
-			boolSQLAssets, arrAssets = queryAssets(StrSQLAppend, v)
-		}
-		if boolSQLAssets && len(arrAssets) > 0 {
-			//Cache instance asset records of class & optional type
-			logger(3, "Caching "+v.AssetType+" Asset Records from Hornbill...", true, true)
-			assetCount, err := getAssetCount(v, hornbillImport)
-			if err != nil {
-				logger(4, "Unable to count asset records: "+err.Error(), true, true)
-				continue
-			}
-			var assetCache map[string]map[string]interface{}
-			if assetCount > 0 {
-				assetCache, err = getAssetRecords(assetCount, v, hornbillImport)
-				if err != nil {
-					logger(4, "Unable to cache asset records: "+err.Error(), true, true)
-					continue
-				}
-			}
-			//Process records returned by query & cache
-			processAssets(arrAssets, assetCache, v)
-		}
+	//The journal is kept when cancelled, so the run can be resumed
+	closeJournal(!importCancelled())
+
+	err = writeReport()
+	if err != nil {
+		logger(4, err.Error(), true, true)
 	}
 
 	//-- End output
 	fmt.Println()
 	logger(3, "-=-=-= Summary =-=-=-", true, true)
-	logger(3, "Created: "+fmt.Sprintf("%d", counters.created), true, true)
-	logger(3, "Create Skipped: "+fmt.Sprintf("%d", counters.createSkipped), true, true)
-	logger(3, "Create Failed: "+fmt.Sprintf("%d", counters.createFailed), true, true)
-	logger(3, "Updated: "+fmt.Sprintf("%d", counters.updated), true, true)
-	logger(3, "Update Skipped: "+fmt.Sprintf("%d", counters.updateSkipped), true, true)
-	logger(3, "Update Failed: "+fmt.Sprintf("%d", counters.updateFailed), true, true)
-	logger(3, "Update Extended Record Skipped: "+fmt.Sprintf("%d", counters.updateRelatedSkipped), true, true)
-	logger(3, "Update Extended Record Failed: "+fmt.Sprintf("%d", counters.updateRelatedFailed), true, true)
-	logger(3, "Assets Software Inventory Skipped: "+fmt.Sprintf("%d", counters.softwareSkipped), true, true)
-	logger(3, "Software Records Created: "+fmt.Sprintf("%d", counters.softwareCreated), true, true)
-	logger(3, "Software Records Create Failed: "+fmt.Sprintf("%d", counters.softwareCreateFailed), true, true)
-	logger(3, "Software Records Removed: "+fmt.Sprintf("%d", counters.softwareRemoved), true, true)
-	logger(3, "Software Records Removal Failed: "+fmt.Sprintf("%d", counters.softwareRemoveFailed), true, true)
-	logger(3, "Asset Supplier Associations Success: "+fmt.Sprintf("%d", counters.suppliersAssociatedSuccess), true, true)
-	logger(3, "Asset Supplier Associations Failed: "+fmt.Sprintf("%d", counters.suppliersAssociatedFailed), true, true)
-	logger(3, "Asset Supplier Associations Skipped: "+fmt.Sprintf("%d", counters.suppliersAssociatedSkipped), true, true)
-	logger(3, "Asset Supplier Contract Associations Success: "+fmt.Sprintf("%d", counters.supplierContractsAssociatedSuccess), true, true)
-	logger(3, "Asset Supplier Contract Associations Failed: "+fmt.Sprintf("%d", counters.supplierContractsAssociatedFailed), true, true)
-	logger(3, "Asset Supplier Contract Associations Skipped: "+fmt.Sprintf("%d", counters.supplierContractsAssociatedSkipped), true, true)
+	logCounters()
+	logRateLimit()
+	logAutoCreated()
+	logUnresolved()
+
+	if importCancelled() {
+		logger(5, "Import cancelled before all assets were processed, the counts above are for the assets processed. Run again with -resume to skip the assets already completed", true, true)
+	}
+
+	exitCode, reasons := importExitCode()
+	logExitCode(exitCode, reasons)
 
 	//-- Show Time Takens
 	logger(3, "Time Taken: "+fmt.Sprintf("%v", time.Since(startTime).Round(time.Second)), true, true)
 	logger(3, "---- XMLMC Database Asset Import Complete ---- ", true, true)
+	return exitCode
+}
+
+// importAssetType -- Streams the source records of an asset type into the asset workers, then moves the watermark
+// on and processes orphans when every record was read
+func importAssetType(v assetTypesStruct, assetSource Source) {
+	var err error
+	StrAssetType = v.AssetType
+	//Set Asset Class & Type vars from instance
+	if !strings.HasPrefix(v.AssetType, "__all__:") {
+		AssetClass, AssetTypeID = getAssetClass(StrAssetType)
+		v.TypeID = AssetTypeID
+		v.Class = AssetClass
+		debugLog(nil, "Asset Type and Class:", StrAssetType, strconv.Itoa(AssetTypeID), AssetClass)
+	} else {
+		if !strings.EqualFold(v.OperationType, "Update") {
+			assetTypeFailed(v, "has an unsupported OperationType defined: "+v.OperationType)
+			return
+		}
+		v.TypeID = 0
+		v.Class = strings.Split(v.AssetType, ":")[1]
+	}
+
+	//-- Apply the stored watermark to the query for delta imports
+	watermark := getWatermark(v)
+	v.Query, err = applyWatermark(v)
+	if err != nil {
+		assetTypeFailed(v, err.Error())
+		return
+	}
+	if watermark != "" {
+		logger(3, "Running delta import of "+v.AssetType+" assets changed since watermark: "+watermark, true, true)
+	}
+
+	//-- Stream records from the Data Source
+	stream, err := resolveDuplicates(streamAssets(assetSource, v), v, assetSource)
+	if err != nil {
+		assetTypeFailed(v, err.Error())
+		return
+	}
+	if stream.empty() {
+		err = stream.close()
+		if err != nil && !importCancelled() {
+			assetTypeFailed(v, err.Error())
+		}
+		return
+	}
+	//Cache instance asset records of class & optional type
+	logger(3, "Caching "+v.AssetType+" Asset Records from Hornbill...", true, true)
+	assetCount, err := getAssetCount(v, hornbillImport)
+	if err != nil {
+		stream.close()
+		assetTypeFailed(v, "Unable to count asset records: "+err.Error())
+		return
+	}
+	var assetCache map[string]map[string]interface{}
+	if assetCount > 0 {
+		assetCache, err = getAssetRecords(assetCount, v, hornbillImport)
+		if err != nil {
+			stream.close()
+			assetTypeFailed(v, "Unable to cache asset records: "+err.Error())
+			return
+		}
+	}
+	metricsSet("hornbill_assets_cached", float64(len(assetCache)), "asset_type", v.AssetType)
+	//Process records as they are read from the source
+	failedBefore := assetTypeFailures(v.AssetType)
+	processed := processAssets(stream, assetCache, v, assetSource)
+	sourceErr := stream.close()
+	failedAfter := assetTypeFailures(v.AssetType)
+
+	//Not every asset was processed, so the watermark stays put and there are no orphans to find
+	if importCancelled() {
+		return
+	}
+	if sourceErr != nil {
+		assetTypeFailed(v, "source failed after "+strconv.Itoa(len(processed.sourceIDs))+" assets were read, watermark and orphans not processed: "+sourceErr.Error())
+		return
+	}
+
+	//Only move the watermark on when every asset was processed successfully
+	if v.WatermarkColumn != "" {
+		if failedAfter == failedBefore {
+			setWatermark(v, maxWatermark(processed.watermark, watermark))
+		} else {
+			logger(5, "Watermark for "+v.AssetType+" assets not updated, as some assets failed to import", true, true)
+		}
+	}
+
+	//Action Hornbill records that no longer exist in the source - a delta import only holds changed records
+	if watermark == "" {
+		processOrphans(processed.sourceIDs, assetCache, v)
+	} else if v.Orphans != nil {
+		logger(3, "Orphan processing of "+v.AssetType+" assets skipped for delta import, run with -full to process orphans", true, true)
+	}
 }
 
 // loadConfig -- Function to Load Configruation File
@@ -263,7 +364,6 @@ func loadConfig() importConfStruct {
 func processCaching() {
 
 	//only load if any of the user colums are set
-	importConf.HornbillUserIDColumn = strings.ToLower(importConf.HornbillUserIDColumn)
 	blnHasUserConfigured := false
 	if val, ok := importConf.AssetGenericFieldMapping["h_owned_by"]; ok {
 		if val != "" {
@@ -312,6 +412,7 @@ func processCaching() {
 	}
 	logger(3, "Caching Application Records from Hornbill...", true, true)
 	getApplications()
+	cachesLoaded = time.Now()
 }
 
 func doSelfUpdate() {
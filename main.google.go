@@ -32,9 +32,38 @@ type googlePayloadStruct struct {
 	OrgUnitPath string `json:"orgUnitPath"`
 }
 
-func getAssetsFromGoogle(assetType assetTypesStruct) (map[string]map[string]interface{}, error) {
-	//Initialise Asset Map
-	returnMap := make(map[string]map[string]interface{})
+// googleSource -- Source implementation for Chrome OS devices in Google Workspace, via iBridge
+type googleSource struct {
+	conf sourceConfStruct
+	key  keyDataStruct
+}
+
+func init() {
+	registerSource(func(conf sourceConfStruct, key keyDataStruct) Source {
+		return &googleSource{conf: conf, key: key}
+	}, "google")
+}
+
+// ValidateConfig -- Checks a Keysafe key has been provided for the iBridge Google Workspace credential
+func (s *googleSource) ValidateConfig() error {
+	if s.conf.KeysafeKeyID == 0 {
+		return errors.New("a KeysafeKeyID is required to import from Google Workspace")
+	}
+	return nil
+}
+
+// GetAssets -- Pages through the Chrome OS devices matching the SourceConfig.Google query
+func (s *googleSource) GetAssets(assetType assetTypesStruct) (map[string]map[string]interface{}, error) {
+	return collectAssets(s, assetType)
+}
+
+// StreamAssets -- Pages through the Chrome OS devices matching the SourceConfig.Google query, emitting the devices as each page is returned
+func (s *googleSource) StreamAssets(assetType assetTypesStruct, emit assetEmitter) error {
+	return s.getAssetsFromGoogle(assetType, emit)
+}
+
+func (s *googleSource) getAssetsFromGoogle(assetType assetTypesStruct, emit assetEmitter) error {
+	assetCount := 0
 	logger(3, " ", false, false)
 	logger(3, "[GOOGLE] Running Google query for "+assetType.AssetType+" assets. Please wait...", true, true)
 
@@ -43,13 +72,16 @@ func getAssetsFromGoogle(assetType assetTypesStruct) (map[string]map[string]inte
 	gEspXmlmc := apiLib.NewXmlmcInstance(importConf.InstanceID)
 	gEspXmlmc.SetAPIKey(importConf.APIKey)
 	for {
-		assetsList, err := getDevicesPageGoogle(gEspXmlmc, nextPageToken)
+		assetsList, err := s.getDevicesPageGoogle(gEspXmlmc, nextPageToken)
 		if err != nil {
-			return returnMap, err
+			return err
 		}
 		for _, v := range assetsList.Params.Data.ChromeOSDevices {
 			assetIdentifier := fmt.Sprintf("%s", v[assetType.AssetIdentifier.SourceColumn])
-			returnMap[assetIdentifier] = v
+			assetCount++
+			if !emit(assetIdentifier, v) {
+				return nil
+			}
 		}
 		// Google's API will return a token even when on the last page of data.
 		// So break the loop if no token is returned
@@ -58,21 +90,21 @@ func getAssetsFromGoogle(assetType assetTypesStruct) (map[string]map[string]inte
 		}
 		nextPageToken = assetsList.Params.Data.NextPageToken
 	}
-	if len(returnMap) == 0 {
+	if assetCount == 0 {
 		logger(3, "No "+assetType.AssetType+" asset records returned from Google - check your configuration!", true, true)
 	} else {
-		logger(2, "Total "+assetType.AssetType+" asset records returned from Google: "+strconv.Itoa(len(returnMap)), true, true)
+		logger(2, "Total "+assetType.AssetType+" asset records returned from Google: "+strconv.Itoa(assetCount), true, true)
 	}
-	return returnMap, nil
+	return nil
 }
 
-func getDevicesPageGoogle(gEspXmlmc *apiLib.XmlmcInstStruct, pageToken string) (assetsResponse googleResponseStruct, err error) {
+func (s *googleSource) getDevicesPageGoogle(gEspXmlmc *apiLib.XmlmcInstStruct, pageToken string) (assetsResponse googleResponseStruct, err error) {
 	var payload = googlePayloadStruct{
-		Customer:    importConf.SourceConfig.Google.Customer,
+		Customer:    s.conf.Google.Customer,
 		MaxResults:  200,
 		PageToken:   pageToken,
-		Query:       importConf.SourceConfig.Google.Query,
-		OrgUnitPath: importConf.SourceConfig.Google.OrgUnitPath,
+		Query:       s.conf.Google.Query,
+		OrgUnitPath: s.conf.Google.OrgUnitPath,
 	}
 
 	strPayload, err := json.Marshal(payload)
@@ -84,11 +116,11 @@ func getDevicesPageGoogle(gEspXmlmc *apiLib.XmlmcInstStruct, pageToken string) (
 	gEspXmlmc.SetParam("requestPayload", string(strPayload))
 	gEspXmlmc.OpenElement("credential")
 	gEspXmlmc.SetParam("id", "googleworkspace")
-	gEspXmlmc.SetParam("keyId", strconv.Itoa(importConf.KeysafeKeyID))
+	gEspXmlmc.SetParam("keyId", strconv.Itoa(s.conf.KeysafeKeyID))
 	gEspXmlmc.CloseElement("credential")
 
 	requestPayloadXML := gEspXmlmc.GetParam()
-	responsePayloadXML, err := gEspXmlmc.Invoke("bpm", "iBridgeInvoke")
+	responsePayloadXML, err := invokeRetry(gEspXmlmc, "bpm", "iBridgeInvoke", nil)
 	if err != nil {
 		logger(4, "getDevicesPage::iBridgeInvoke:invoke:"+err.Error(), true, true)
 		logger(4, "Request XML: "+requestPayloadXML, false, true)
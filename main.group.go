@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"strconv"
-	"strings"
 
 	"github.com/cheggaaa/pb"
 )
@@ -13,10 +12,11 @@ import (
 func loadGroups(groups []string) {
 	//-- Init One connection to Hornbill to load all data
 	logger(3, "Loading Groups from Hornbill", false, true)
+	Groups = newGroupCache()
 	count := getGroupCount(groups)
 	logger(3, "getGroupCount Count: "+strconv.Itoa(count), false, true)
 	getGroupList(groups, count)
-	logger(3, "Groups Loaded: "+strconv.Itoa(len(Groups)), false, true)
+	logger(3, "Groups Loaded: "+strconv.Itoa(Groups.len()), false, true)
 }
 
 func getGroupCount(groups []string) int {
@@ -34,7 +34,7 @@ func getGroupCount(groups []string) int {
 	hornbillImport.SetParam("pageSize", "1")
 	hornbillImport.CloseElement("pageInfo")
 
-	RespBody, xmlmcErr := hornbillImport.Invoke("admin", "groupGetList2")
+	RespBody, xmlmcErr := invokeRetry(hornbillImport, "admin", "groupGetList2", nil)
 
 	var JSONResp xmlmcGroupResponse
 	if xmlmcErr != nil {
@@ -78,7 +78,7 @@ func getGroupList(groups []string, count int) {
 		hornbillImport.SetParam("pageSize", strconv.Itoa(pageSize))
 		hornbillImport.CloseElement("pageInfo")
 
-		RespBody, xmlmcErr := hornbillImport.Invoke("admin", "groupGetList2")
+		RespBody, xmlmcErr := invokeRetry(hornbillImport, "admin", "groupGetList2", nil)
 
 		var JSONResp xmlmcGroupResponse
 		if xmlmcErr != nil {
@@ -106,8 +106,7 @@ func getGroupList(groups []string, count int) {
 			case "department":
 				newGroupForCache.GroupType = 2
 			}
-			name := []groupListStruct{newGroupForCache}
-			Groups = append(Groups, name...)
+			Groups.add(newGroupForCache)
 		}
 
 		bar.Add(len(JSONResp.Params.Group))
@@ -118,6 +117,25 @@ func getGroupList(groups []string, count int) {
 	bar.FinishPrint("Groups Loaded  \n")
 }
 
+// getParentCompany -- returns the company of an asset, for the parent of a new department. A missing company is
+// created when AutoCreate Companies is enabled, otherwise the department is created without a parent
+func getParentCompany(u map[string]interface{}, buffer *bytes.Buffer) (company groupListStruct) {
+	companyNameMapping := fmt.Sprintf("%v", importConf.AssetGenericFieldMapping["h_company_name"])
+	company.GroupName = getFieldValue("h_company_name", companyNameMapping, u, buffer)
+	if company.GroupName == "" || company.GroupName == "<nil>" || company.GroupName == "__clear__" {
+		return groupListStruct{}
+	}
+	if group, found := Groups.get(5, company.GroupName); found {
+		return group
+	}
+	if !importConf.AutoCreate.Companies {
+		buffer.WriteString(loggerGen(5, "Parent Company ["+company.GroupName+"] not found in Hornbill, and AutoCreate Companies is not enabled, so the Department is created without a parent"))
+		return groupListStruct{}
+	}
+	company.GroupID = autoCreateGroupRecord(5, company.GroupName, groupListStruct{}, buffer)
+	return
+}
+
 func getGroupID(u map[string]interface{}, groupType string, buffer *bytes.Buffer) (groupID, groupName string) {
 	groupCol := ""
 	groupTypeID := 0
@@ -133,11 +151,16 @@ func getGroupID(u map[string]interface{}, groupType string, buffer *bytes.Buffer
 	groupName = getFieldValue(groupCol, groupNameMapping, u, buffer)
 	if groupName != "" && groupName != "<nil>" && groupName != "__clear__" {
 		//-- Check if group is in Cache
-		for _, group := range Groups {
-			if strings.EqualFold(group.GroupName, groupName) && group.GroupType == groupTypeID {
-				groupID = group.GroupID
-				break
+		if group, found := Groups.get(groupTypeID, groupName); found {
+			groupID = group.GroupID
+		} else if groupType == "company" && importConf.AutoCreate.Companies {
+			groupID = autoCreateGroupRecord(groupTypeID, groupName, groupListStruct{}, buffer)
+		} else if groupType == "department" && importConf.AutoCreate.Departments {
+			var parent groupListStruct
+			if importConf.AutoCreate.ParentCompany {
+				parent = getParentCompany(u, buffer)
 			}
+			groupID = autoCreateGroupRecord(groupTypeID, groupName, parent, buffer)
 		}
 	}
 	debugLog(buffer, "Group Mapping:", groupCol, ":", groupNameMapping, ":", groupName, ":", groupID)
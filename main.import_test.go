@@ -0,0 +1,700 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testCSV = `Name,SerialNumber,Model,Owner,Site,Company
+Desktop001,SERIAL001,Inspiron 3891,jsmith,Head Office,Acme
+Desktop002,SERIAL002,Inspiron 3891,bjones,Branch Office,Acme
+`
+
+// newImportTest -- resets the import state, and returns a mock instance holding a Desktop asset type,
+// two users, two sites and a company. The test runs in its own working directory, for the logs and state files
+func newImportTest(t *testing.T) *mockHornbill {
+	cwd, _ := os.Getwd()
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(cwd) })
+
+	importCtx, cancelImport = context.WithCancel(context.Background())
+	resetRun()
+	Sites = nil
+	Groups = nil
+	Customers = nil
+	HInstalledApplications = make(map[string]bool)
+	cachesLoaded = time.Time{}
+	redactor = redactorStruct{maxPayloadBytes: defaultMaxPayloadBytes}
+	importState = importStateStruct{Watermarks: make(map[string]string)}
+	for _, family := range metricFamilies {
+		family.series = nil
+	}
+	configFileName = "conf.json"
+	configDaemon = false
+	configDebug = false
+	configLogFormat = logFormatText
+	configDryRun = false
+	configFullImport = false
+	configResume = false
+	configReportFile = ""
+	configMaxRoutines = 2
+
+	m := newMockHornbill(t)
+	m.AssetTypes["Desktop"] = mockAssetType{Class: "computer", TypeID: 1}
+	m.Users = []map[string]string{
+		{"h_user_id": "jsmith", "h_first_name": "John", "h_last_name": "Smith"},
+		{"h_user_id": "bjones", "h_first_name": "Bob", "h_last_name": "Jones"},
+	}
+	m.Sites = []map[string]string{
+		{"h_id": "1", "h_site_name": "Head Office"},
+		{"h_id": "2", "h_site_name": "Branch Office"},
+	}
+	m.Groups = []map[string]string{{"id": "acme", "name": "Acme", "type": "company"}}
+	m.Apps = []string{"com.hornbill.servicemanager"}
+	return m
+}
+
+// loadTestConfig -- writes and loads an import configuration with the given source and asset type
+func loadTestConfig(t *testing.T, m *mockHornbill, sourceConfig map[string]interface{}, assetType map[string]interface{}) {
+	conf := map[string]interface{}{
+		"APIKey":               "mockapikey",
+		"InstanceId":           m.URL(),
+		"KeysafeKeyID":         0,
+		"HornbillUserIDColumn": "h_user_id",
+		"SourceConfig":         sourceConfig,
+		"AssetTypes":           []interface{}{assetType},
+		"AssetGenericFieldMapping": map[string]interface{}{
+			"h_name":         "{{.Name}}",
+			"h_owned_by":     "{{.Owner}}",
+			"h_site":         "{{.Site}}",
+			"h_company_name": "{{.Company}}",
+		},
+		"AssetTypeFieldMapping": map[string]interface{}{
+			"h_name":          "{{.Name}}",
+			"h_model":         "{{.Model}}",
+			"h_serial_number": "{{.SerialNumber}}",
+		},
+	}
+	content, _ := json.Marshal(conf)
+	if err := os.WriteFile(configFileName, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	importConf = loadConfig()
+	initXMLMC()
+}
+
+func desktopAssetType(source map[string]interface{}) map[string]interface{} {
+	assetType := map[string]interface{}{
+		"AssetType":     "Desktop",
+		"OperationType": "Both",
+		"AssetIdentifier": map[string]interface{}{
+			"SourceColumn": "SerialNumber",
+			"Entity":       "AssetsComputer",
+			"EntityColumn": "h_serial_number",
+		},
+	}
+	for k, v := range source {
+		assetType[k] = v
+	}
+	return assetType
+}
+
+func TestImportCSV(t *testing.T) {
+	m := newImportTest(t)
+	existingID := m.AddAsset(map[string]string{"h_class": "computer", "h_type": "1", "h_name": "Old Name", "h_serial_number": "SERIAL002"})
+	os.WriteFile("desktops.csv", []byte(testCSV), 0644)
+	loadTestConfig(t, m,
+		map[string]interface{}{"Source": "csv", "CSV": map[string]interface{}{"CommaCharacter": ","}},
+		desktopAssetType(map[string]interface{}{"CSVFile": "desktops.csv"}))
+
+	runImport()
+
+	if counterTotal(counterCreated) != 1 || counterTotal(counterUpdated) != 1 || counterTotal(counterCreateFailed) != 0 || counterTotal(counterUpdateFailed) != 0 {
+		t.Fatalf("unexpected counters after first import: %+v", counterSnapshot().total)
+	}
+	created := m.FindAsset("h_serial_number", "SERIAL001")
+	if created == nil {
+		t.Fatal("asset SERIAL001 was not created")
+	}
+	expected := map[string]string{
+		"h_name":          "Desktop001",
+		"h_model":         "Inspiron 3891",
+		"h_class":         "computer",
+		"h_type":          "1",
+		"h_owned_by":      "urn:sys:0:John Smith:jsmith",
+		"h_owned_by_name": "John Smith",
+		"h_site":          "Head Office",
+		"h_site_id":       "1",
+		"h_company_id":    "acme",
+		"h_company_name":  "Acme",
+		"h_asset_urn":     "urn:sys:entity:com.hornbill.servicemanager:Asset:" + created["h_pk_asset_id"],
+	}
+	for k, v := range expected {
+		if created[k] != v {
+			t.Errorf("created asset %s = %q, expected %q", k, created[k], v)
+		}
+	}
+	updated := m.Assets[existingID]
+	if updated["h_name"] != "Desktop002" || updated["h_owned_by"] != "urn:sys:0:Bob Jones:bjones" || updated["h_site"] != "Branch Office" {
+		t.Errorf("existing asset not updated: %v", updated)
+	}
+
+	//A second run against unchanged source data should make no changes
+	resetCounters()
+	runImport()
+	if counterTotal(counterCreated) != 0 || counterTotal(counterUpdated) != 0 || counterTotal(counterUpdateSkipped) != 2 {
+		t.Errorf("unexpected counters after second import: %+v", counterSnapshot().total)
+	}
+	if len(m.Assets) != 2 {
+		t.Errorf("expected 2 assets, found %d", len(m.Assets))
+	}
+}
+
+func TestImportUpdatesChangedFieldsOnly(t *testing.T) {
+	m := newImportTest(t)
+	existingID := m.AddAsset(map[string]string{
+		"h_class":         "computer",
+		"h_type":          "1",
+		"h_name":          "Desktop002",
+		"h_model":         "Old Model",
+		"h_serial_number": "SERIAL002",
+		"h_owned_by":      "urn:sys:0:Bob Jones:bjones",
+		"h_owned_by_name": "Bob Jones",
+		"h_site":          "Branch Office",
+		"h_site_id":       "2",
+	})
+	m.Assets[existingID]["h_asset_urn"] = "urn:sys:entity:com.hornbill.servicemanager:Asset:" + existingID
+	os.WriteFile("desktops.csv", []byte(testCSV), 0644)
+	loadTestConfig(t, m,
+		map[string]interface{}{"Source": "csv", "CSV": map[string]interface{}{"CommaCharacter": ","}},
+		desktopAssetType(map[string]interface{}{"CSVFile": "desktops.csv"}))
+
+	runImport()
+
+	if counterTotal(counterUpdated) != 1 || counterTotal(counterUpdateFailed) != 0 {
+		t.Fatalf("unexpected counters: %+v", counterSnapshot().total)
+	}
+	sent := make(map[string]bool)
+	for _, column := range m.Updated[existingID] {
+		sent[column] = true
+	}
+	for _, column := range []string{"h_model", "h_company_name", "h_company_id", "h_dsc_cf_fingerprint"} {
+		if !sent[column] {
+			t.Errorf("changed column %s was not sent, sent: %v", column, m.Updated[existingID])
+		}
+	}
+	for _, column := range []string{"h_name", "h_serial_number", "h_owned_by", "h_site", "h_site_id", "h_asset_urn"} {
+		if sent[column] {
+			t.Errorf("unchanged column %s was sent, sent: %v", column, m.Updated[existingID])
+		}
+	}
+	if m.Assets[existingID]["h_model"] != "Inspiron 3891" {
+		t.Errorf("h_model not updated: %v", m.Assets[existingID])
+	}
+}
+
+func TestImportBasicClassUnchanged(t *testing.T) {
+	m := newImportTest(t)
+	m.AssetTypes["Desktop"] = mockAssetType{Class: "basic", TypeID: 1}
+	os.WriteFile("desktops.csv", []byte(testCSV), 0644)
+	loadTestConfig(t, m,
+		map[string]interface{}{"Source": "csv", "CSV": map[string]interface{}{"CommaCharacter": ","}},
+		desktopAssetType(map[string]interface{}{"CSVFile": "desktops.csv"}))
+	runImport()
+	if counterTotal(counterCreated) != 2 {
+		t.Fatalf("expected 2 assets created, got %+v", counterSnapshot().total)
+	}
+
+	//Basic assets are always compared against the cached record, so with nothing changed the primary and
+	//extended records aren't sent, and each asset is skipped once
+	resetRun()
+	configReportFile = "report.json"
+	updates := m.CallCount("data::entityUpdateRecord")
+	runImport()
+	if calls := m.CallCount("data::entityUpdateRecord"); calls != updates {
+		t.Errorf("expected no record updates, got %d", calls-updates)
+	}
+	if counterTotal(counterUpdateSkipped) != 2 || counterTotal(counterUpdated) != 0 {
+		t.Errorf("expected 2 assets skipped, got %+v", counterSnapshot().total)
+	}
+	content, err := os.ReadFile("report.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report reportStruct
+	if err := json.Unmarshal(content, &report); err != nil {
+		t.Fatal(err)
+	}
+	for _, asset := range report.Assets {
+		if asset.Action != assetOutcomeSkipped {
+			t.Errorf("asset %s reported as %s, want skipped", asset.SourceID, asset.Action)
+		}
+	}
+}
+
+func TestImportCSVDryRun(t *testing.T) {
+	m := newImportTest(t)
+	os.WriteFile("desktops.csv", []byte(testCSV), 0644)
+	loadTestConfig(t, m,
+		map[string]interface{}{"Source": "csv", "CSV": map[string]interface{}{"CommaCharacter": ","}},
+		desktopAssetType(map[string]interface{}{"CSVFile": "desktops.csv"}))
+	configDryRun = true
+	configReportFile = "report.json"
+
+	runImport()
+
+	if len(m.Assets) != 0 || m.CallCount("data::entityAddRecord") != 0 {
+		t.Errorf("dry run changed the instance: %d assets, %d entityAddRecord calls", len(m.Assets), m.CallCount("data::entityAddRecord"))
+	}
+	if counterTotal(counterCreateSkipped) != 2 {
+		t.Errorf("expected 2 creates skipped, got %d", counterTotal(counterCreateSkipped))
+	}
+	content, err := os.ReadFile("report.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report reportStruct
+	if err = json.Unmarshal(content, &report); err != nil {
+		t.Fatal(err)
+	}
+	if !report.DryRun || len(report.Assets) != 2 {
+		t.Errorf("unexpected report: %s", content)
+	}
+}
+
+func TestImportSQLite(t *testing.T) {
+	m := newImportTest(t)
+	dbPath := filepath.Join(t.TempDir(), "assets.db")
+	m.Keys["1"] = `{"database":"` + filepath.ToSlash(dbPath) + `"}`
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, stmt := range []string{
+		`CREATE TABLE devices (name TEXT, serial TEXT, model TEXT, owner TEXT, site TEXT, company TEXT)`,
+		`CREATE TABLE software (serial TEXT, app_id TEXT, app_name TEXT)`,
+		`INSERT INTO devices VALUES ('Desktop001', 'SERIAL001', 'OptiPlex 7010', 'jsmith', 'Head Office', 'Acme')`,
+		`INSERT INTO devices VALUES ('Desktop002', 'SERIAL002', 'OptiPlex 7010', 'bjones', 'Branch Office', 'Acme')`,
+		`INSERT INTO software VALUES ('SERIAL001', 'app1', 'Editor'), ('SERIAL001', 'app2', 'Browser'), ('SERIAL002', 'app1', 'Editor')`,
+	} {
+		if _, err = db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	loadTestConfig(t, m,
+		map[string]interface{}{
+			"Source":       "sqlite",
+			"KeysafeKeyID": 1,
+			"Database":     map[string]interface{}{"Query": "SELECT name AS Name, serial AS SerialNumber, model AS Model, owner AS Owner, site AS Site, company AS Company FROM devices"},
+		},
+		desktopAssetType(map[string]interface{}{
+			"Query": "ORDER BY serial",
+			"SoftwareInventory": map[string]interface{}{
+				"AssetIDColumn": "SerialNumber",
+				"AppIDColumn":   "{{.AppID}}",
+				"Query":         "SELECT app_id AS AppID, app_name AS AppName FROM software WHERE serial = '{{AssetID}}' ORDER BY app_id",
+				"Mapping": map[string]interface{}{
+					"h_app_id":   "{{.AppID}}",
+					"h_app_name": "{{.AppName}}",
+				},
+			},
+		}))
+	importConf.KeysafeKeyID = 1
+
+	runImport()
+
+	if counterTotal(counterCreated) != 2 || counterTotal(counterSoftwareCreated) != 3 {
+		t.Fatalf("unexpected counters after first import: %+v", counterSnapshot().total)
+	}
+	desktop1 := m.FindAsset("h_serial_number", "SERIAL001")
+	if desktop1 == nil || desktop1["h_model"] != "OptiPlex 7010" || desktop1["h_owned_by_name"] != "John Smith" {
+		t.Fatalf("SERIAL001 not created as expected: %v", desktop1)
+	}
+	if sw := m.AssetSoftware(desktop1["h_pk_asset_id"]); !reflect.DeepEqual(sw, []string{"app1", "app2"}) {
+		t.Errorf("unexpected software against SERIAL001: %v", sw)
+	}
+
+	//Change the software inventory of one asset, and the model of the other
+	for _, stmt := range []string{
+		`DELETE FROM software WHERE serial = 'SERIAL001' AND app_id = 'app2'`,
+		`INSERT INTO software VALUES ('SERIAL001', 'app3', 'Mail')`,
+		`UPDATE devices SET model = 'OptiPlex 7020' WHERE serial = 'SERIAL002'`,
+	} {
+		if _, err = db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resetCounters()
+	runImport()
+
+	if counterTotal(counterCreated) != 0 || counterTotal(counterUpdated) != 1 || counterTotal(counterSoftwareCreated) != 1 || counterTotal(counterSoftwareRemoved) != 1 {
+		t.Fatalf("unexpected counters after second import: %+v", counterSnapshot().total)
+	}
+	if sw := m.AssetSoftware(desktop1["h_pk_asset_id"]); !reflect.DeepEqual(sw, []string{"app1", "app3"}) {
+		t.Errorf("unexpected software against SERIAL001: %v", sw)
+	}
+	if desktop2 := m.FindAsset("h_serial_number", "SERIAL002"); desktop2["h_model"] != "OptiPlex 7020" {
+		t.Errorf("SERIAL002 model not updated: %v", desktop2)
+	}
+}
+
+func TestImportSQLiteWatermark(t *testing.T) {
+	m := newImportTest(t)
+	dbPath := filepath.Join(t.TempDir(), "assets.db")
+	m.Keys["1"] = `{"database":"` + filepath.ToSlash(dbPath) + `"}`
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, stmt := range []string{
+		`CREATE TABLE devices (name TEXT, serial TEXT, owner TEXT, updated DATETIME)`,
+		`INSERT INTO devices VALUES ('Desktop001', 'SERIAL001', 'jsmith', '2026-03-01 09:30:00')`,
+		`INSERT INTO devices VALUES ('Desktop002', 'SERIAL002', 'bjones', '2026-03-04 10:00:00')`,
+	} {
+		if _, err = db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	loadTestConfig(t, m,
+		map[string]interface{}{
+			"Source":       "sqlite",
+			"KeysafeKeyID": 1,
+			"Database":     map[string]interface{}{"Query": "SELECT name AS Name, serial AS SerialNumber, owner AS Owner, updated AS Updated FROM devices"},
+		},
+		desktopAssetType(map[string]interface{}{
+			"Query":           "{{if not .Full}}WHERE updated > '{{.Watermark}}'{{end}} ORDER BY serial",
+			"WatermarkColumn": "Updated",
+		}))
+	importConf.KeysafeKeyID = 1
+
+	runImport()
+
+	//The native DATETIME is stored in a format the database can compare against
+	if counterTotal(counterCreated) != 2 || importState.Watermarks["Desktop"] != "2026-03-04 10:00:00.000" {
+		t.Fatalf("expected 2 assets created and the watermark 2026-03-04 10:00:00.000, got %d and %q", counterTotal(counterCreated), importState.Watermarks["Desktop"])
+	}
+
+	//A delta run only reads the records changed since the watermark
+	if _, err = db.Exec(`INSERT INTO devices VALUES ('Desktop003', 'SERIAL003', 'jsmith', '2026-03-05 08:15:00')`); err != nil {
+		t.Fatal(err)
+	}
+	resetRun()
+	if code := runImport(); code != exitSuccess {
+		t.Fatalf("delta run exited with %d", code)
+	}
+	if counterTotal(counterCreated) != 1 || counterTotal(counterUpdateSkipped) != 0 || importState.Watermarks["Desktop"] != "2026-03-05 08:15:00.000" {
+		t.Fatalf("expected the delta run to create only SERIAL003 and move the watermark, got %+v and %q", counterSnapshot().total, importState.Watermarks["Desktop"])
+	}
+
+	//-full reads every record, and keeps the watermark
+	resetRun()
+	configFullImport = true
+	runImport()
+	if counterTotal(counterUpdateSkipped) != 3 || counterTotal(counterCreated) != 0 || importState.Watermarks["Desktop"] != "2026-03-05 08:15:00.000" {
+		t.Errorf("expected the full run to skip all 3 unchanged assets, got %+v and %q", counterSnapshot().total, importState.Watermarks["Desktop"])
+	}
+}
+
+func TestMockXMLEncoding(t *testing.T) {
+	var buf bytes.Buffer
+	writeMockXML(&buf, "params", map[string]interface{}{
+		"rowData": map[string]interface{}{"row": []map[string]interface{}{{"a": "1"}, {"a": "<2>"}}},
+	})
+	expected := "<params><rowData><row><a>1</a></row><row><a>&lt;2&gt;</a></row></rowData></params>"
+	if buf.String() != expected {
+		t.Errorf("got %s, expected %s", buf.String(), expected)
+	}
+}
+
+func TestImportAutoCreate(t *testing.T) {
+	const autoCreateCSV = `Name,SerialNumber,Model,Owner,Site,Company,Department
+Desktop001,SERIAL001,Inspiron 3891,jsmith,New Site,Globex,IT
+Desktop002,SERIAL002,Inspiron 3891,bjones,new site,Globex,IT
+Desktop003,SERIAL003,Inspiron 3891,bjones,Head Office,Acme,Finance
+`
+	for _, dryRun := range []bool{false, true} {
+		m := newImportTest(t)
+		os.WriteFile("desktops.csv", []byte(autoCreateCSV), 0644)
+		loadTestConfig(t, m,
+			map[string]interface{}{"Source": "csv", "CSV": map[string]interface{}{"CommaCharacter": ","}},
+			desktopAssetType(map[string]interface{}{"CSVFile": "desktops.csv"}))
+		importConf.AssetGenericFieldMapping["h_department_name"] = "{{.Department}}"
+		importConf.AutoCreate = autoCreateConfStruct{Sites: true, Companies: true, Departments: true, ParentCompany: true}
+		configDryRun = dryRun
+		configReportFile = "report.json"
+		configMaxRoutines = 3
+
+		runImport()
+
+		content, _ := os.ReadFile("report.json")
+		var report reportStruct
+		if err := json.Unmarshal(content, &report); err != nil {
+			t.Fatal(err)
+		}
+		created := map[string]autoCreatedStruct{}
+		for _, v := range report.AutoCreated {
+			//Workers run concurrently, so either spelling of New Site may be the one created
+			created[v.Type+":"+strings.ToLower(v.Name)] = v
+		}
+		if len(report.AutoCreated) != 4 {
+			t.Errorf("dry run %v: expected 4 records created, got %+v", dryRun, report.AutoCreated)
+		}
+		for _, key := range []string{"site:new site", "company:globex", "department:it", "department:finance"} {
+			if _, ok := created[key]; !ok {
+				t.Errorf("dry run %v: %s not created, got %+v", dryRun, key, report.AutoCreated)
+			}
+		}
+		if created["department:it"].Parent != "Globex" || created["department:finance"].Parent != "Acme" {
+			t.Errorf("dry run %v: unexpected department parents: %+v", dryRun, report.AutoCreated)
+		}
+
+		if dryRun {
+			if m.CallCount("admin::groupCreate") != 0 || len(m.Sites) != 2 {
+				t.Errorf("dry run created records: %d groupCreate calls, %d sites", m.CallCount("admin::groupCreate"), len(m.Sites))
+			}
+			continue
+		}
+		if len(m.Sites) != 3 || m.CallCount("admin::groupCreate") != 3 {
+			t.Fatalf("expected 1 site and 3 groups created, got sites %v and %d groupCreate calls", m.Sites, m.CallCount("admin::groupCreate"))
+		}
+		newSiteID := m.Sites[2]["h_id"]
+		for _, serial := range []string{"SERIAL001", "SERIAL002"} {
+			asset := m.FindAsset("h_serial_number", serial)
+			if asset["h_site_id"] != newSiteID || asset["h_company_id"] != "globex" || asset["h_department_id"] != "it" {
+				t.Errorf("%s not linked to the created site and groups: %v", serial, asset)
+			}
+		}
+		for _, group := range m.Groups {
+			if group["id"] == "it" && group["parent"] != "globex" {
+				t.Errorf("department IT created under %q, expected globex", group["parent"])
+			}
+		}
+
+		//A CSV report holds the created records in their own file
+		configReportFile = "report.csv"
+		if err := writeReport(); err != nil {
+			t.Fatal(err)
+		}
+		file, err := os.Open("report_autocreated.csv")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows, err := csv.NewReader(file).ReadAll()
+		file.Close()
+		if err != nil || len(rows) != 5 || rows[0][0] != "Type" {
+			t.Errorf("expected a header and 4 created records in the CSV report, got %v (%v)", rows, err)
+		}
+	}
+}
+
+func TestImportAutoCreateDepartmentWithoutCompany(t *testing.T) {
+	const autoCreateCSV = `Name,SerialNumber,Model,Owner,Site,Company,Department
+Desktop001,SERIAL001,Inspiron 3891,jsmith,Head Office,Globex,IT
+`
+	m := newImportTest(t)
+	os.WriteFile("desktops.csv", []byte(autoCreateCSV), 0644)
+	loadTestConfig(t, m,
+		map[string]interface{}{"Source": "csv", "CSV": map[string]interface{}{"CommaCharacter": ","}},
+		desktopAssetType(map[string]interface{}{"CSVFile": "desktops.csv"}))
+	importConf.AssetGenericFieldMapping["h_department_name"] = "{{.Department}}"
+	importConf.AutoCreate = autoCreateConfStruct{Departments: true, ParentCompany: true}
+
+	runImport()
+
+	//The missing company is not created, as Companies is not enabled, so the department has no parent
+	if m.CallCount("admin::groupCreate") != 1 {
+		t.Fatalf("expected only the department to be created, got %d groupCreate calls", m.CallCount("admin::groupCreate"))
+	}
+	for _, group := range m.Groups {
+		if group["id"] == "globex" {
+			t.Errorf("company Globex created with AutoCreate Companies disabled")
+		}
+		if group["id"] == "it" && group["parent"] != "" {
+			t.Errorf("department IT created under %q, expected no parent", group["parent"])
+		}
+	}
+	if asset := m.FindAsset("h_serial_number", "SERIAL001"); asset == nil || asset["h_department_id"] != "it" {
+		t.Errorf("asset not linked to the created department: %v", asset)
+	}
+}
+
+func TestImportUnresolvedReferences(t *testing.T) {
+	const unresolvedCSV = `Name,SerialNumber,Model,Owner,Site,Company
+Desktop001,SERIAL001,Inspiron 3891,ACME\nobody,Head Office,Acme
+Desktop002,SERIAL002,Inspiron 3891,acme\NOBODY,Remote Office,Acme
+Desktop003,SERIAL003,Inspiron 3891,jsmith,Remote Office,Initech
+`
+	m := newImportTest(t)
+	os.WriteFile("desktops.csv", []byte(unresolvedCSV), 0644)
+	loadTestConfig(t, m,
+		map[string]interface{}{"Source": "csv", "CSV": map[string]interface{}{"CommaCharacter": ","}},
+		desktopAssetType(map[string]interface{}{"CSVFile": "desktops.csv"}))
+	configReportFile = "report.json"
+
+	runImport()
+
+	content, _ := os.ReadFile("report.json")
+	var report reportStruct
+	if err := json.Unmarshal(content, &report); err != nil {
+		t.Fatal(err)
+	}
+	expected := []unresolvedStruct{
+		{Field: "h_company_name", Value: "Initech", Count: 1, AssetTypes: []string{"Desktop"}, SampleAssets: []string{"SERIAL003"}},
+		{Field: "h_owned_by", Value: `ACME\nobody`, Count: 2, AssetTypes: []string{"Desktop"}},
+		{Field: "h_site", Value: "Remote Office", Count: 2, AssetTypes: []string{"Desktop"}},
+	}
+	if len(report.Unresolved) != len(expected) {
+		t.Fatalf("expected %d unresolved references, got %+v", len(expected), report.Unresolved)
+	}
+	for i, want := range expected {
+		got := report.Unresolved[i]
+		if got.Field != want.Field || !strings.EqualFold(got.Value, want.Value) || got.Count != want.Count || !reflect.DeepEqual(got.AssetTypes, want.AssetTypes) {
+			t.Errorf("unresolved reference %d = %+v, want %+v", i, got, want)
+		}
+		if want.SampleAssets != nil && !reflect.DeepEqual(got.SampleAssets, want.SampleAssets) {
+			t.Errorf("unresolved reference %d samples = %v, want %v", i, got.SampleAssets, want.SampleAssets)
+		}
+		if len(got.SampleAssets) != got.Count {
+			t.Errorf("unresolved reference %d has %d samples, want %d", i, len(got.SampleAssets), got.Count)
+		}
+	}
+
+	//A second run skips the unchanged assets, and still finds their references, written to their own CSV file
+	resetRun()
+	configReportFile = "report.csv"
+	runImport()
+	if counterTotal(counterUpdateSkipped) != 3 {
+		t.Fatalf("expected the 3 unchanged assets to be skipped, got %+v", counterSnapshot().total)
+	}
+	file, err := os.Open("report_unresolved.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(expected)+1 || rows[0][0] != "Field" {
+		t.Fatalf("expected a header and %d unresolved references, got %v", len(expected), rows)
+	}
+	for i, want := range expected {
+		if row := rows[i+1]; row[0] != want.Field || !strings.EqualFold(row[1], want.Value) || row[2] != strconv.Itoa(want.Count) {
+			t.Errorf("unresolved reference row %d = %v, want %+v", i, row, want)
+		}
+	}
+	if _, err := os.Stat("report_autocreated.csv"); !os.IsNotExist(err) {
+		t.Errorf("expected no auto created report without AutoCreate, got %v", err)
+	}
+}
+
+func TestImportCancelled(t *testing.T) {
+	const cancelCSV = testCSV + "Desktop003,SERIAL003,Inspiron 3891,jsmith,Head Office,Acme\n"
+	m := newImportTest(t)
+	os.WriteFile("desktops.csv", []byte(cancelCSV), 0644)
+	loadTestConfig(t, m,
+		map[string]interface{}{"Source": "csv", "CSV": map[string]interface{}{"CommaCharacter": ","}},
+		desktopAssetType(map[string]interface{}{"CSVFile": "desktops.csv"}))
+	configMaxRoutines = 1
+	//Interrupt the import while the first asset is being created
+	var once sync.Once
+	m.OnCall = func(method string) {
+		if method == "data::entityAddRecord" {
+			once.Do(cancelImport)
+		}
+	}
+
+	runImport()
+
+	if counterTotal(counterCreated) != 1 {
+		t.Fatalf("expected only the asset in progress to be created, got %d", counterTotal(counterCreated))
+	}
+	if _, err := os.Stat("conf.journal.jsonl"); err != nil {
+		t.Fatalf("journal not kept after cancellation: %v", err)
+	}
+
+	//The remaining assets are created when resumed
+	importCtx, cancelImport = context.WithCancel(context.Background())
+	resetCounters()
+	configResume = true
+	runImport()
+	if counterTotal(counterCreated) != 2 || counterTotal(counterAssetsResumed) != 1 {
+		t.Errorf("expected 2 created and 1 resumed, got %d created and %d resumed", counterTotal(counterCreated), counterTotal(counterAssetsResumed))
+	}
+	if len(m.Assets) != 3 {
+		t.Errorf("expected 3 assets in Hornbill, got %d", len(m.Assets))
+	}
+}
+
+func TestImportCancelledAbandoned(t *testing.T) {
+	m := newImportTest(t)
+	os.WriteFile("desktops.csv", []byte(testCSV), 0644)
+	loadTestConfig(t, m,
+		map[string]interface{}{"Source": "csv", "CSV": map[string]interface{}{"CommaCharacter": ","}},
+		desktopAssetType(map[string]interface{}{"CSVFile": "desktops.csv"}))
+	configMaxRoutines = 1
+	configReportFile = "report.json"
+	cancelWaitTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { cancelWaitTimeout = 60 * time.Second })
+	//Interrupt the import, and hold the first create until the import has stopped waiting for it
+	release := make(chan struct{})
+	var once sync.Once
+	m.OnCall = func(method string) {
+		if method == "data::entityAddRecord" {
+			once.Do(func() {
+				cancelImport()
+				<-release
+			})
+		}
+	}
+
+	runImport()
+
+	content, err := os.ReadFile("report.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report reportStruct
+	if err := json.Unmarshal(content, &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Assets) != 1 || report.Assets[0].SourceID != "SERIAL001" || report.Assets[0].Action != assetOutcomeAbandoned {
+		t.Fatalf("expected SERIAL001 to be reported as abandoned, got %+v", report.Assets)
+	}
+
+	//The abandoned worker's result is dropped once it completes
+	close(release)
+	worker.Wait()
+	mutexReport.Lock()
+	reported := len(reportAssets)
+	mutexReport.Unlock()
+	if reported != 1 {
+		t.Errorf("expected the abandoned asset to be reported once, got %d report entries", reported)
+	}
+	journal, err := os.ReadFile("conf.journal.jsonl")
+	if err != nil {
+		t.Fatalf("journal not kept after cancellation: %v", err)
+	}
+	if len(journal) != 0 {
+		t.Errorf("expected no journal entries for the abandoned asset, got %s", journal)
+	}
+}
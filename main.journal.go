@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outcomes recorded against each processed asset, in the journal and the run report
+const (
+	assetOutcomeCreated = "created"
+	assetOutcomeUpdated = "updated"
+	assetOutcomeSkipped = "skipped"
+	assetOutcomeFailed  = "failed"
+	//Only reported, for assets still in progress when a cancelled import stopped waiting for them
+	assetOutcomeAbandoned = "abandoned"
+)
+
+// journalEntryStruct -- One line of the journal, written as each asset finishes processing
+type journalEntryStruct struct {
+	Time            string `json:"Time"`
+	AssetType       string `json:"AssetType"`
+	Snapshot        string `json:"Snapshot"`
+	AssetID         string `json:"AssetID"`
+	Outcome         string `json:"Outcome"`
+	HornbillAssetID string `json:"HornbillAssetID"`
+}
+
+var (
+	journalFile      *os.File
+	journalCompleted = make(map[string]journalEntryStruct)
+	mutexJournal     = &sync.Mutex{}
+)
+
+// getJournalFilePath -- returns the path of the journal, defaulting to one named after the config file
+func getJournalFilePath() string {
+	if importConf.JournalFile != "" {
+		return importConf.JournalFile
+	}
+	cwd, _ := os.Getwd()
+	return cwd + "/" + strings.TrimSuffix(configFileName, ".json") + ".journal.jsonl"
+}
+
+func journalKey(assetType, snapshot, assetID string) string {
+	return assetType + "\x00" + snapshot + "\x00" + assetID
+}
+
+// openJournal -- opens the journal for writing. When resuming, the completed assets from the existing
+// journal are loaded and new entries are appended, otherwise the journal is started afresh.
+// The journal is not used in dry run mode
+func openJournal() error {
+	if configDryRun {
+		return nil
+	}
+	journalPath := getJournalFilePath()
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if configResume {
+		err := loadJournal(journalPath)
+		if err != nil {
+			return err
+		}
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	var err error
+	journalFile, err = os.OpenFile(journalPath, flags, 0644)
+	if err != nil {
+		return errors.New("Unable to open journal " + journalPath + ": " + err.Error())
+	}
+	return nil
+}
+
+// loadJournal -- reads the completed (non-failed) assets from an existing journal
+func loadJournal(journalPath string) error {
+	file, err := os.Open(journalPath)
+	if os.IsNotExist(err) {
+		logger(3, "No journal found to resume from, all assets will be processed", true, true)
+		return nil
+	}
+	if err != nil {
+		return errors.New("Unable to read journal " + journalPath + ": " + err.Error())
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry journalEntryStruct
+		//A partially written final line is expected if the process died mid-write, so is ignored
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil {
+			continue
+		}
+		key := journalKey(entry.AssetType, entry.Snapshot, entry.AssetID)
+		if entry.Outcome == assetOutcomeFailed {
+			delete(journalCompleted, key)
+			continue
+		}
+		journalCompleted[key] = entry
+	}
+	if err = scanner.Err(); err != nil {
+		return errors.New("Unable to read journal " + journalPath + ": " + err.Error())
+	}
+	logger(3, "Resuming from journal "+journalPath+" with "+fmt.Sprintf("%d", len(journalCompleted))+" completed assets", true, true)
+	return nil
+}
+
+// closeJournal -- closes the journal. Once every asset type has been processed the journal is no longer
+// needed, so it is removed so that a later -resume can't skip assets from a completed run
+func closeJournal(completed bool) {
+	mutexJournal.Lock()
+	defer mutexJournal.Unlock()
+	if journalFile == nil {
+		return
+	}
+	journalFile.Close()
+	if completed {
+		err := os.Remove(journalFile.Name())
+		if err != nil {
+			logger(5, "Unable to remove journal: "+err.Error(), true, true)
+		}
+	}
+	journalFile = nil
+}
+
+// journalCompletedAsset -- returns the journal entry for an asset already completed against the same source record hash
+func journalCompletedAsset(assetType, snapshot, assetID string) (journalEntryStruct, bool) {
+	mutexJournal.Lock()
+	defer mutexJournal.Unlock()
+	entry, ok := journalCompleted[journalKey(assetType, snapshot, assetID)]
+	return entry, ok
+}
+
+// writeJournal -- records the outcome of an asset, unbuffered so the entry survives the process dying. Once the
+// journal is closed, entries are dropped, such as those of workers abandoned when the import was cancelled
+func writeJournal(assetType, snapshot, assetID, outcome, hornbillAssetID string) {
+	mutexJournal.Lock()
+	defer mutexJournal.Unlock()
+	if journalFile == nil {
+		return
+	}
+	entry := journalEntryStruct{
+		Time:            time.Now().Format(time.RFC3339),
+		AssetType:       assetType,
+		Snapshot:        snapshot,
+		AssetID:         assetID,
+		Outcome:         outcome,
+		HornbillAssetID: hornbillAssetID,
+	}
+	jsonBytes, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	_, err = journalFile.Write(append(jsonBytes, '\n'))
+	if err != nil {
+		logger(4, "Unable to write to journal: "+err.Error(), false, true)
+	}
+}
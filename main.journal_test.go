@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestImportResume(t *testing.T) {
+	const resumeCSV = testCSV + "Desktop003,SERIAL003,Inspiron 3891,jsmith,Head Office,Acme\n" +
+		"Desktop004,SERIAL004,Inspiron 3891,bjones,Branch Office,Acme\n"
+	m := newImportTest(t)
+	os.WriteFile("desktops.csv", []byte(resumeCSV), 0644)
+	loadTestConfig(t, m,
+		map[string]interface{}{"Source": "csv", "CSV": map[string]interface{}{"CommaCharacter": ","}},
+		desktopAssetType(map[string]interface{}{"CSVFile": "desktops.csv"}))
+	configMaxRoutines = 1
+	//The second create fails, and the import is interrupted while the third asset is being created
+	creates := 0
+	m.OnCall = func(method string) {
+		if method != "data::entityAddRecord" {
+			return
+		}
+		creates++
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		switch creates {
+		case 2:
+			m.Failures[method] = "Unable to create the record"
+		case 3:
+			delete(m.Failures, method)
+			cancelImport()
+		}
+	}
+
+	runImport()
+
+	if counterTotal(counterCreated) != 2 || counterTotal(counterCreateFailed) != 1 {
+		t.Fatalf("expected 2 assets created and 1 failed, got %+v", counterSnapshot().total)
+	}
+	file, err := os.OpenFile("conf.journal.jsonl", os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("journal not kept after cancellation: %v", err)
+	}
+	//A partial line, as left when the process dies mid-write, for an asset that was never completed
+	file.WriteString(`{"Time":"2026-01-01T00:00:00Z","AssetType":"Desktop","Snapshot":"`)
+	file.Close()
+
+	//Resuming skips the completed assets, and processes the failed and unprocessed assets
+	importCtx, cancelImport = context.WithCancel(context.Background())
+	resetRun()
+	configResume = true
+	before := m.CallCount("data::entityAddRecord")
+	runImport()
+
+	if counterTotal(counterAssetsResumed) != 2 || counterTotal(counterCreated) != 2 || counterTotal(counterCreateFailed) != 0 {
+		t.Errorf("expected 2 assets resumed and 2 created, got %+v", counterSnapshot().total)
+	}
+	if calls := m.CallCount("data::entityAddRecord") - before; calls != 2 {
+		t.Errorf("expected only the failed and unprocessed assets to be created, got %d creates", calls)
+	}
+	for _, serial := range []string{"SERIAL001", "SERIAL002", "SERIAL003", "SERIAL004"} {
+		if m.FindAsset("h_serial_number", serial) == nil {
+			t.Errorf("asset %s not in Hornbill", serial)
+		}
+	}
+	if len(m.Assets) != 4 {
+		t.Errorf("expected 4 assets in Hornbill, got %d", len(m.Assets))
+	}
+	if _, err := os.Stat("conf.journal.jsonl"); !os.IsNotExist(err) {
+		t.Errorf("expected the journal to be removed once the run completed, got %v", err)
+	}
+}
+
+func TestLoadJournal(t *testing.T) {
+	newImportTest(t)
+	lines := []journalEntryStruct{
+		{AssetType: "Desktop", Snapshot: "a", AssetID: "SERIAL001", Outcome: assetOutcomeCreated},
+		{AssetType: "Desktop", Snapshot: "b", AssetID: "SERIAL002", Outcome: assetOutcomeUpdated},
+		{AssetType: "Desktop", Snapshot: "b", AssetID: "SERIAL002", Outcome: assetOutcomeFailed},
+		{AssetType: "Desktop", Snapshot: "c", AssetID: "SERIAL003", Outcome: assetOutcomeFailed},
+		{AssetType: "Desktop", Snapshot: "d", AssetID: "SERIAL004", Outcome: assetOutcomeSkipped},
+	}
+	file, err := os.Create("resume.journal.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := bufio.NewWriter(file)
+	for _, line := range lines {
+		jsonBytes, _ := json.Marshal(line)
+		w.Write(append(jsonBytes, '\n'))
+	}
+	w.WriteString(`{"AssetType":"Desktop","Snapshot":"e","AssetID":"SERIAL005","Outc`)
+	w.Flush()
+	file.Close()
+
+	if err := loadJournal("resume.journal.jsonl"); err != nil {
+		t.Fatal(err)
+	}
+	//A later failure replaces an earlier completion, and the truncated last line is ignored
+	for _, tt := range []struct {
+		snapshot, assetID string
+		completed         bool
+	}{
+		{"a", "SERIAL001", true},
+		{"b", "SERIAL002", false},
+		{"c", "SERIAL003", false},
+		{"d", "SERIAL004", true},
+		{"e", "SERIAL005", false},
+		{"changed", "SERIAL001", false},
+	} {
+		if _, ok := journalCompletedAsset("Desktop", tt.snapshot, tt.assetID); ok != tt.completed {
+			t.Errorf("%s with snapshot %s completed = %v, want %v", tt.assetID, tt.snapshot, ok, tt.completed)
+		}
+	}
+}
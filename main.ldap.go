@@ -2,6 +2,7 @@ package main
 
 import (
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"strconv"
 
@@ -9,36 +10,67 @@ import (
 	"github.com/mavricknz/ldap"
 )
 
-func connectLDAP() *ldap.LDAPConnection {
+// ldapSource -- Source implementation for LDAP directories
+type ldapSource struct {
+	conf sourceConfStruct
+	key  keyDataStruct
+}
+
+func init() {
+	registerSource(func(conf sourceConfStruct, key keyDataStruct) Source {
+		return &ldapSource{conf: conf, key: key}
+	}, "ldap")
+}
+
+// ValidateConfig -- Checks the Keysafe key holds the LDAP server details
+func (s *ldapSource) ValidateConfig() error {
+	if s.key.Host == "" {
+		return errors.New("no LDAP host defined in the Keysafe key")
+	}
+	return nil
+}
+
+// GetAssets -- Runs the asset type query against the asset type LDAPDSN
+func (s *ldapSource) GetAssets(assetType assetTypesStruct) (map[string]map[string]interface{}, error) {
+	return collectAssets(s, assetType)
+}
+
+// StreamAssets -- Runs the asset type query against the asset type LDAPDSN, emitting each entry returned
+func (s *ldapSource) StreamAssets(assetType assetTypesStruct, emit assetEmitter) error {
+	return s.queryLDAP(assetType, emit)
+}
+
+func (s *ldapSource) connectLDAP() *ldap.LDAPConnection {
 
 	TLSconfig := &tls.Config{
-		ServerName:         key.Host,
-		InsecureSkipVerify: importConf.SourceConfig.LDAP.Server.InsecureSkipVerify,
+		ServerName:         s.key.Host,
+		InsecureSkipVerify: s.conf.LDAP.Server.InsecureSkipVerify,
 	}
 	//-- Based on Connection Type Normal | TLS | SSL
-	if importConf.SourceConfig.LDAP.Server.Debug {
-		logger(3, "Attempting Connection to LDAP... \nServer: "+key.Host+"\nPort: "+fmt.Sprintf("%d", key.Port)+"\nType: "+importConf.SourceConfig.LDAP.Server.ConnectionType+"\nSkip Verify: "+fmt.Sprintf("%t", importConf.SourceConfig.LDAP.Server.InsecureSkipVerify)+"\nDebug: "+fmt.Sprintf("%t", importConf.SourceConfig.LDAP.Server.Debug), true, true)
+	//-- The connection details are kept out of the instance log
+	if s.conf.LDAP.Server.Debug {
+		logger(3, "Attempting Connection to LDAP... \nServer: "+s.key.Host+"\nPort: "+fmt.Sprintf("%d", s.key.Port)+"\nType: "+s.conf.LDAP.Server.ConnectionType+"\nSkip Verify: "+fmt.Sprintf("%t", s.conf.LDAP.Server.InsecureSkipVerify)+"\nDebug: "+fmt.Sprintf("%t", s.conf.LDAP.Server.Debug), true, false)
 	}
 
-	t := importConf.SourceConfig.LDAP.Server.ConnectionType
+	t := s.conf.LDAP.Server.ConnectionType
 	switch t {
 	case "":
 		//-- Normal
 		logger(3, "Creating LDAP Connection", false, true)
-		l := ldap.NewLDAPConnection(key.Host, key.Port)
-		l.Debug = importConf.SourceConfig.LDAP.Server.Debug
+		l := ldap.NewLDAPConnection(s.key.Host, s.key.Port)
+		l.Debug = s.conf.LDAP.Server.Debug
 		return l
 	case "TLS":
 		//-- TLS
 		logger(3, "Creating LDAP Connection (TLS)", false, true)
-		l := ldap.NewLDAPTLSConnection(key.Host, key.Port, TLSconfig)
-		l.Debug = importConf.SourceConfig.LDAP.Server.Debug
+		l := ldap.NewLDAPTLSConnection(s.key.Host, s.key.Port, TLSconfig)
+		l.Debug = s.conf.LDAP.Server.Debug
 		return l
 	case "SSL":
 		//-- SSL
 		logger(3, "Creating LDAP Connection (SSL)", false, true)
-		l := ldap.NewLDAPSSLConnection(key.Host, key.Port, TLSconfig)
-		l.Debug = importConf.SourceConfig.LDAP.Server.Debug
+		l := ldap.NewLDAPSSLConnection(s.key.Host, s.key.Port, TLSconfig)
+		l.Debug = s.conf.LDAP.Server.Debug
 		return l
 	}
 
@@ -46,70 +78,71 @@ func connectLDAP() *ldap.LDAPConnection {
 }
 
 // -- Query LDAP
-func queryLDAP(assetType assetTypesStruct) (map[string]map[string]interface{}, bool) {
+func (s *ldapSource) queryLDAP(assetType assetTypesStruct, emit assetEmitter) error {
 
 	logger(3, "LDAP DSN: "+assetType.LDAPDSN, true, true)
 	logger(3, "LDAP Query For Assets: "+assetType.Query, true, true)
-	ldapAssets := make(map[string]map[string]interface{})
 	//-- Create LDAP Connection
-	l := connectLDAP()
+	l := s.connectLDAP()
+	if l == nil {
+		return errors.New("Unsupported LDAP ConnectionType: " + s.conf.LDAP.Server.ConnectionType)
+	}
 	err := l.Connect()
 	if err != nil {
-		logger(4, "Connecting Error: "+err.Error(), true, true)
-		return ldapAssets, false
+		return errors.New("Connecting Error: " + err.Error())
 	}
 	defer l.Close()
 
 	//-- Bind
-	err = l.Bind(key.Username, key.Password)
+	err = l.Bind(s.key.Username, s.key.Password)
 	if err != nil {
-		logger(4, "Bind Error: "+err.Error(), true, true)
-		return ldapAssets, false
+		return errors.New("Bind Error: " + err.Error())
 	}
-	if importConf.SourceConfig.LDAP.Server.Debug {
-		logger(3, "LDAP Search Query \n"+fmt.Sprintf("%+v", importConf.SourceConfig.LDAP.Query)+" ----", false, true)
+	if s.conf.LDAP.Server.Debug {
+		logger(3, "LDAP Search Query \n"+fmt.Sprintf("%+v", s.conf.LDAP.Query)+" ----", false, true)
 	}
 	//-- Build Search Request
 	searchRequest := ldap.NewSearchRequest(
 		assetType.LDAPDSN,
-		importConf.SourceConfig.LDAP.Query.Scope,
-		importConf.SourceConfig.LDAP.Query.DerefAliases,
-		importConf.SourceConfig.LDAP.Query.SizeLimit,
-		importConf.SourceConfig.LDAP.Query.TimeLimit,
-		importConf.SourceConfig.LDAP.Query.TypesOnly,
+		s.conf.LDAP.Query.Scope,
+		s.conf.LDAP.Query.DerefAliases,
+		s.conf.LDAP.Query.SizeLimit,
+		s.conf.LDAP.Query.TimeLimit,
+		s.conf.LDAP.Query.TypesOnly,
 		assetType.Query,
-		importConf.SourceConfig.LDAP.Query.Attributes,
+		s.conf.LDAP.Query.Attributes,
 		nil)
 
 	//-- Search Request with 1000 limit pagaing
 	results, err := l.SearchWithPaging(searchRequest, 1000)
 	if err != nil {
-		logger(4, "Search Error: "+err.Error(), true, true)
-		return ldapAssets, false
+		return errors.New("Search Error: " + err.Error())
 	}
 
 	logger(3, "LDAP Results: "+fmt.Sprintf("%d", len(results.Entries))+"\n", true, true)
 	//-- Catch zero results
 	if len(results.Entries) == 0 {
-		logger(4, "No Assets Found ", true, true)
-		return ldapAssets, false
+		return errors.New("No Assets Found ")
 	}
 
 	for _, asset := range results.Entries {
 		assetIdentifier := asset.GetAttributeValue(assetType.AssetIdentifier.SourceColumn)
-		ldapAssets[assetIdentifier] = make(map[string]interface{})
-		for _, v := range importConf.SourceConfig.LDAP.Query.Attributes {
+		assetRecord := make(map[string]interface{})
+		for _, v := range s.conf.LDAP.Query.Attributes {
 			if v == "objectSid" {
 				sid := objectsid.Decode([]byte(asset.GetAttributeValue(v)))
-				ldapAssets[assetIdentifier][v] = sid.String()
+				assetRecord[v] = sid.String()
 			} else if v == "objectGUID" {
-				ldapAssets[assetIdentifier][v] = convertOctetStringToGuid(asset.GetAttributeValue(v))
+				assetRecord[v] = convertOctetStringToGuid(asset.GetAttributeValue(v))
 			} else {
-				ldapAssets[assetIdentifier][v] = asset.GetAttributeValue(v)
+				assetRecord[v] = asset.GetAttributeValue(v)
 			}
 		}
+		if !emit(assetIdentifier, assetRecord) {
+			return nil
+		}
 	}
-	return ldapAssets, true
+	return nil
 }
 
 func convertOctetStringToGuid(octetString string) string {
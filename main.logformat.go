@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+)
+
+// Log file formats, set with the -logformat flag
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// Separates the lines logged to a per-asset buffer by loggerGen. Lines can hold newlines, such as XMLMC payloads,
+// so are not split on them
+const logBufferSeparator = "\n\r"
+
+// logEntryStruct -- A line of the log file in the JSON format. The asset fields are set for the lines logged while
+// processing an asset
+type logEntryStruct struct {
+	Timestamp       string `json:"Timestamp"`
+	Level           string `json:"Level"`
+	Message         string `json:"Message"`
+	AssetType       string `json:"AssetType,omitempty"`
+	SourceID        string `json:"SourceID,omitempty"`
+	HornbillAssetID string `json:"HornbillAssetID,omitempty"`
+	Operation       string `json:"Operation,omitempty"`
+}
+
+// logLevels -- The prefix of each logger level in the text format, and its name in the JSON format
+var logLevels = map[int]struct{ prefix, name string }{
+	1: {"[DEBUG] ", "debug"},
+	2: {"[MESSAGE] ", "info"},
+	3: {"", "info"},
+	4: {"[ERROR] ", "error"},
+	5: {"[WARNING] ", "warning"},
+}
+
+// checkLogFormat -- returns true when the -logformat flag is supported
+func checkLogFormat() bool {
+	return configLogFormat == logFormatText || configLogFormat == logFormatJSON
+}
+
+// logLinePrefix -- returns the text format prefix of a logger level
+func logLinePrefix(t int) string {
+	return logLevels[t].prefix
+}
+
+// parseLogLine -- returns the logger level and message of a text format line, such as those written by loggerGen
+func parseLogLine(line string) (int, string) {
+	for t, level := range logLevels {
+		if level.prefix != "" && strings.HasPrefix(line, level.prefix) {
+			return t, strings.TrimPrefix(line, level.prefix)
+		}
+	}
+	return 3, line
+}
+
+// writeLogJSON -- writes a log entry as a line of JSON, with the fields of the asset being processed when given
+func writeLogJSON(w io.Writer, t int, s string, asset *reportAssetStruct) error {
+	if t == 0 {
+		t, s = parseLogLine(s)
+	}
+	entry := logEntryStruct{
+		Timestamp: time.Now().Format("2006-01-02T15:04:05.000Z07:00"),
+		Level:     logLevels[t].name,
+		Message:   s,
+	}
+	if entry.Level == "" {
+		entry.Level = logLevels[3].name
+	}
+	if asset != nil {
+		entry.AssetType = asset.AssetType
+		entry.SourceID = asset.SourceID
+		entry.HornbillAssetID = asset.HornbillAssetID
+		entry.Operation = asset.Action
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(line, '\n'))
+	return err
+}
+
+// loggerWriteAssetBuffer -- writes the lines logged to the buffer of an asset, with the outcome of the asset
+func loggerWriteAssetBuffer(s string, asset reportAssetStruct) {
+	for _, line := range strings.Split(s, logBufferSeparator) {
+		if line != "" {
+			writeLog(0, line, false, false, &asset)
+		}
+	}
+}
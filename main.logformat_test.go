@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestImportJSONLog(t *testing.T) {
+	m := newImportTest(t)
+	configLogFormat = logFormatJSON
+	existingID := m.AddAsset(map[string]string{"h_class": "computer", "h_type": "1", "h_name": "Old Name", "h_serial_number": "SERIAL002"})
+	m.Failures["data::entityUpdateRecord"] = "Record is locked"
+	os.WriteFile("desktops.csv", []byte(testCSV), 0644)
+	loadTestConfig(t, m,
+		map[string]interface{}{"Source": "csv", "CSV": map[string]interface{}{"CommaCharacter": ","}},
+		desktopAssetType(map[string]interface{}{"CSVFile": "desktops.csv"}))
+
+	runImport()
+
+	file, err := os.Open("log/" + currentLogFileName())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var created, failed, summary bool
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry logEntryStruct
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("log line is not JSON: %v\n%s", err, scanner.Text())
+		}
+		if entry.Timestamp == "" || entry.Level == "" {
+			t.Errorf("log line missing timestamp or level: %s", scanner.Text())
+		}
+		switch {
+		case entry.SourceID == "SERIAL001" && entry.Operation == assetOutcomeCreated && entry.AssetType == "Desktop" && entry.HornbillAssetID != "":
+			created = true
+		case entry.SourceID == "SERIAL002" && entry.Level == "error":
+			failed = entry.Operation == assetOutcomeFailed && entry.HornbillAssetID == existingID && entry.Message != ""
+		case entry.Message == "Created: 1":
+			summary = entry.Level == "info" && entry.AssetType == ""
+		}
+	}
+	if !created || !failed || !summary {
+		t.Errorf("expected created asset (%v), failed asset (%v) and summary (%v) log entries", created, failed, summary)
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prefix of every metric name
+const metricsNamespace = "hornbill_asset_import_"
+
+// Buckets of the Hornbill call latency histogram, in seconds
+var metricsLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// metricFamilyStruct -- A metric name, with its HELP and TYPE, and a series for each set of label values
+type metricFamilyStruct struct {
+	help   string
+	kind   string
+	series map[string]*metricSeriesStruct
+}
+
+// metricSeriesStruct -- The value of a counter or gauge, or the buckets of a histogram, for one set of label values
+type metricSeriesStruct struct {
+	value   float64
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+var (
+	metricFamilies = map[string]*metricFamilyStruct{
+		"assets_total":                       {help: "Source assets processed, by AssetType and action.", kind: "counter"},
+		"extended_record_updates_total":      {help: "Extended asset record updates that were not needed or failed, by AssetType and result.", kind: "counter"},
+		"software_inventory_unchanged_total": {help: "Matched assets whose software inventory needed no changes, by AssetType.", kind: "counter"},
+		"assets_resumed_total":               {help: "Source assets skipped on resume, as they were completed by the interrupted import, by AssetType.", kind: "counter"},
+		"duplicate_records_total":            {help: "Source records sharing the asset identifier of another record, by AssetType.", kind: "counter"},
+		"software_records_total":             {help: "Software inventory records added and removed, by AssetType and action.", kind: "counter"},
+		"supplier_associations_total":        {help: "Asset supplier and supplier contract associations, by AssetType and result.", kind: "counter"},
+		"orphans_total":                      {help: "Orphaned Hornbill assets, by AssetType and action.", kind: "counter"},
+		"asset_type_failures_total":          {help: "Imports of an AssetType that failed, as it could not be read from its source or prepared for import.", kind: "counter"},
+		"xmlmc_retries_total":                {help: "XMLMC calls retried.", kind: "counter"},
+		"http_retries_total":                 {help: "Source HTTP requests retried.", kind: "counter"},
+		"hornbill_call_duration_seconds":     {help: "Latency of each Hornbill XMLMC call attempt, by service and method.", kind: "histogram"},
+		"source_query_duration_seconds":      {help: "Time taken to read every record from the source in the last import of the AssetType.", kind: "gauge"},
+		"source_records":                     {help: "Records read from the source in the last import of the AssetType.", kind: "gauge"},
+		"hornbill_assets_cached":             {help: "Hornbill asset records cached in the last import of the AssetType.", kind: "gauge"},
+		"cache_records":                      {help: "Hornbill records held in the user, site, group and application caches.", kind: "gauge"},
+		"last_run_timestamp_seconds":         {help: "Unix time the last import finished.", kind: "gauge"},
+		"last_run_duration_seconds":          {help: "Time taken by the last import.", kind: "gauge"},
+		"last_run_exit_code":                 {help: "Exit code for the outcome of the last import.", kind: "gauge"},
+	}
+	mutexMetrics = &sync.Mutex{}
+)
+
+// metricSeries -- returns the series of a metric for the label name and value pairs, creating it if needed.
+// Must be called with mutexMetrics held
+func metricSeries(name string, labels []string) (*metricFamilyStruct, *metricSeriesStruct) {
+	family, ok := metricFamilies[name]
+	if !ok {
+		panic("metricSeries: metric not defined: " + name)
+	}
+	if family.series == nil {
+		family.series = make(map[string]*metricSeriesStruct)
+	}
+	var key []string
+	for i := 0; i+1 < len(labels); i += 2 {
+		key = append(key, labels[i]+"=\""+metricsEscape(labels[i+1])+"\"")
+	}
+	labelKey := strings.Join(key, ",")
+	series, ok := family.series[labelKey]
+	if !ok {
+		series = &metricSeriesStruct{}
+		if family.kind == "histogram" {
+			series.buckets = make([]uint64, len(metricsLatencyBuckets))
+		}
+		family.series[labelKey] = series
+	}
+	return family, series
+}
+
+// metricsAdd -- adds to a counter, labelled with name and value pairs
+func metricsAdd(name string, value float64, labels ...string) {
+	if value == 0 {
+		return
+	}
+	mutexMetrics.Lock()
+	defer mutexMetrics.Unlock()
+	_, series := metricSeries(name, labels)
+	series.value += value
+}
+
+// metricsSet -- sets a gauge, labelled with name and value pairs
+func metricsSet(name string, value float64, labels ...string) {
+	mutexMetrics.Lock()
+	defer mutexMetrics.Unlock()
+	_, series := metricSeries(name, labels)
+	series.value = value
+}
+
+// metricsObserve -- records a value in a histogram, labelled with name and value pairs
+func metricsObserve(name string, value float64, labels ...string) {
+	mutexMetrics.Lock()
+	defer mutexMetrics.Unlock()
+	_, series := metricSeries(name, labels)
+	for i, bound := range metricsLatencyBuckets {
+		if value <= bound {
+			series.buckets[i]++
+		}
+	}
+	series.sum += value
+	series.count++
+}
+
+func metricsEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func metricsFormatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// metricsLabels -- joins the label key of a series with an extra label, for histogram buckets
+func metricsLabels(labelKey, extra string) string {
+	if labelKey == "" {
+		labelKey = extra
+	} else if extra != "" {
+		labelKey += "," + extra
+	}
+	if labelKey == "" {
+		return ""
+	}
+	return "{" + labelKey + "}"
+}
+
+// writeMetrics -- outputs every metric in the Prometheus text exposition format
+func writeMetrics(buf *bytes.Buffer) {
+	mutexMetrics.Lock()
+	defer mutexMetrics.Unlock()
+	var names []string
+	for name, family := range metricFamilies {
+		if len(family.series) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		family := metricFamilies[name]
+		fullName := metricsNamespace + name
+		buf.WriteString("# HELP " + fullName + " " + family.help + "\n")
+		buf.WriteString("# TYPE " + fullName + " " + family.kind + "\n")
+		var labelKeys []string
+		for labelKey := range family.series {
+			labelKeys = append(labelKeys, labelKey)
+		}
+		sort.Strings(labelKeys)
+		for _, labelKey := range labelKeys {
+			series := family.series[labelKey]
+			if family.kind != "histogram" {
+				buf.WriteString(fullName + metricsLabels(labelKey, "") + " " + metricsFormatFloat(series.value) + "\n")
+				continue
+			}
+			for i, bound := range metricsLatencyBuckets {
+				buf.WriteString(fullName + "_bucket" + metricsLabels(labelKey, `le="`+metricsFormatFloat(bound)+`"`) + " " + strconv.FormatUint(series.buckets[i], 10) + "\n")
+			}
+			buf.WriteString(fullName + "_bucket" + metricsLabels(labelKey, `le="+Inf"`) + " " + strconv.FormatUint(series.count, 10) + "\n")
+			buf.WriteString(fullName + "_sum" + metricsLabels(labelKey, "") + " " + metricsFormatFloat(series.sum) + "\n")
+			buf.WriteString(fullName + "_count" + metricsLabels(labelKey, "") + " " + strconv.FormatUint(series.count, 10) + "\n")
+		}
+	}
+}
+
+// metricsHandler -- serves the metrics to Prometheus, for daemon mode
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	writeMetrics(&buf)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// writeMetricsTextFile -- writes the metrics for the node_exporter textfile collector. The file is written alongside
+// then renamed into place, so the collector never reads a partly written file
+func writeMetricsTextFile() error {
+	if importConf.Metrics.TextFile == "" {
+		return nil
+	}
+	var buf bytes.Buffer
+	writeMetrics(&buf)
+	textFile := importConf.Metrics.TextFile
+	tmpFile, err := os.CreateTemp(filepath.Dir(textFile), "."+filepath.Base(textFile)+".*")
+	if err != nil {
+		return errors.New("Unable to write metrics text file: " + err.Error())
+	}
+	defer os.Remove(tmpFile.Name())
+	_, err = tmpFile.Write(buf.Bytes())
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpFile.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), textFile)
+	}
+	if err != nil {
+		return errors.New("Unable to write metrics text file: " + err.Error())
+	}
+	logger(3, "Metrics written to "+textFile, false, true)
+	return nil
+}
+
+// counterMetrics -- The metric, and its label name and value pairs, that each counter is added to. The asset_type
+// label is added for each AssetType
+var counterMetrics = map[counterName][]string{
+	counterCreated:                            {"assets_total", "action", "created"},
+	counterCreateSkipped:                      {"assets_total", "action", "create_skipped"},
+	counterCreateFailed:                       {"assets_total", "action", "create_failed"},
+	counterUpdated:                            {"assets_total", "action", "updated"},
+	counterUpdateSkipped:                      {"assets_total", "action", "update_skipped"},
+	counterUpdateFailed:                       {"assets_total", "action", "update_failed"},
+	counterUpdateRelatedSkipped:               {"extended_record_updates_total", "result", "skipped"},
+	counterUpdateRelatedFailed:                {"extended_record_updates_total", "result", "failed"},
+	counterSoftwareSkipped:                    {"software_inventory_unchanged_total"},
+	counterAssetsResumed:                      {"assets_resumed_total"},
+	counterDuplicatesFound:                    {"duplicate_records_total"},
+	counterSoftwareCreated:                    {"software_records_total", "action", "created"},
+	counterSoftwareCreateFailed:               {"software_records_total", "action", "create_failed"},
+	counterSoftwareRemoved:                    {"software_records_total", "action", "removed"},
+	counterSoftwareRemoveFailed:               {"software_records_total", "action", "remove_failed"},
+	counterSuppliersAssociatedSuccess:         {"supplier_associations_total", "association", "supplier", "result", "success"},
+	counterSuppliersAssociatedFailed:          {"supplier_associations_total", "association", "supplier", "result", "failed"},
+	counterSuppliersAssociatedSkipped:         {"supplier_associations_total", "association", "supplier", "result", "skipped"},
+	counterSupplierContractsAssociatedSuccess: {"supplier_associations_total", "association", "contract", "result", "success"},
+	counterSupplierContractsAssociatedFailed:  {"supplier_associations_total", "association", "contract", "result", "failed"},
+	counterSupplierContractsAssociatedSkipped: {"supplier_associations_total", "association", "contract", "result", "skipped"},
+	counterOrphansFound:                       {"orphans_total", "action", "found"},
+	counterOrphansActioned:                    {"orphans_total", "action", "actioned"},
+	counterOrphansFailed:                      {"orphans_total", "action", "failed"},
+	counterOrphansSkipped:                     {"orphans_total", "action", "skipped"},
+	counterAssetTypesFailed:                   {"asset_type_failures_total"},
+}
+
+// recordRunMetrics -- records the outcome of an import, adding its counters to the metrics, and the cache sizes
+func recordRunMetrics(exitCode int) {
+	snapshot := counterSnapshot()
+	for _, assetType := range snapshot.assetTypes {
+		for counter, metric := range counterMetrics {
+			labels := append([]string{"asset_type", assetType}, metric[1:]...)
+			metricsAdd(metric[0], float64(snapshot.byType[assetType][counter]), labels...)
+		}
+	}
+	metricsAdd("xmlmc_retries_total", float64(snapshot.total[counterXMLMCRetries]))
+	metricsAdd("http_retries_total", float64(snapshot.total[counterHTTPRetries]))
+	metricsSet("cache_records", float64(Customers.len()), "cache", "users")
+	metricsSet("cache_records", float64(Sites.len()), "cache", "sites")
+	metricsSet("cache_records", float64(Groups.len()), "cache", "groups")
+	metricsSet("cache_records", float64(len(HInstalledApplications)), "cache", "applications")
+	metricsSet("last_run_timestamp_seconds", float64(time.Now().Unix()))
+	metricsSet("last_run_duration_seconds", time.Since(startTime).Seconds())
+	metricsSet("last_run_exit_code", float64(exitCode))
+}
+
+// checkMetricsConfig -- Validates the Metrics configuration
+func checkMetricsConfig() error {
+	if importConf.Metrics.TextFile == "" {
+		return nil
+	}
+	if info, err := os.Stat(filepath.Dir(importConf.Metrics.TextFile)); err != nil || !info.IsDir() {
+		return errors.New("Metrics TextFile folder does not exist: " + fmt.Sprintf("%q", filepath.Dir(importConf.Metrics.TextFile)))
+	}
+	return nil
+}
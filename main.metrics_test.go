@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	newImportTest(t)
+	metricsAdd("assets_total", 2, "asset_type", "Desktop", "action", "created")
+	metricsAdd("assets_total", 1, "asset_type", "Desktop", "action", "created")
+	metricsAdd("assets_total", 0, "asset_type", "Desktop", "action", "update_failed")
+	metricsSet("source_records", 5, "asset_type", `Say "Hi"\`)
+	metricsObserve("hornbill_call_duration_seconds", 0.2, "service", "data", "method", "entityAddRecord")
+	metricsObserve("hornbill_call_duration_seconds", 60, "service", "data", "method", "entityAddRecord")
+
+	var buf bytes.Buffer
+	writeMetrics(&buf)
+	output := buf.String()
+	for _, want := range []string{
+		"# TYPE hornbill_asset_import_assets_total counter\n",
+		`hornbill_asset_import_assets_total{asset_type="Desktop",action="created"} 3` + "\n",
+		`hornbill_asset_import_source_records{asset_type="Say \"Hi\"\\"} 5` + "\n",
+		"# TYPE hornbill_asset_import_hornbill_call_duration_seconds histogram\n",
+		`hornbill_asset_import_hornbill_call_duration_seconds_bucket{service="data",method="entityAddRecord",le="0.1"} 0` + "\n",
+		`hornbill_asset_import_hornbill_call_duration_seconds_bucket{service="data",method="entityAddRecord",le="0.25"} 1` + "\n",
+		`hornbill_asset_import_hornbill_call_duration_seconds_bucket{service="data",method="entityAddRecord",le="+Inf"} 2` + "\n",
+		`hornbill_asset_import_hornbill_call_duration_seconds_sum{service="data",method="entityAddRecord"} 60.2` + "\n",
+		`hornbill_asset_import_hornbill_call_duration_seconds_count{service="data",method="entityAddRecord"} 2` + "\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("metrics missing %q, got:\n%s", want, output)
+		}
+	}
+	//Counters only appear once they have a value
+	if strings.Contains(output, "update_failed") || strings.Contains(output, "orphans_total") {
+		t.Errorf("unexpected zero value series in metrics:\n%s", output)
+	}
+
+	rec := httptest.NewRecorder()
+	metricsHandler(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") || rec.Body.String() != output {
+		t.Errorf("unexpected /metrics response %q:\n%s", rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+}
+
+func TestImportMetricsTextFile(t *testing.T) {
+	m := newImportTest(t)
+	m.AddAsset(map[string]string{"h_class": "computer", "h_type": "1", "h_name": "Old Name", "h_serial_number": "SERIAL002"})
+	os.WriteFile("desktops.csv", []byte(testCSV), 0644)
+	loadTestConfig(t, m,
+		map[string]interface{}{"Source": "csv", "CSV": map[string]interface{}{"CommaCharacter": ","}},
+		desktopAssetType(map[string]interface{}{"CSVFile": "desktops.csv"}))
+	importConf.Metrics.TextFile = "asset_import.prom"
+
+	recordRunMetrics(runImport())
+	if err := writeMetricsTextFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile("asset_import.prom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`hornbill_asset_import_assets_total{asset_type="Desktop",action="created"} 1`,
+		`hornbill_asset_import_assets_total{asset_type="Desktop",action="updated"} 1`,
+		`hornbill_asset_import_source_records{asset_type="Desktop"} 2`,
+		`hornbill_asset_import_hornbill_assets_cached{asset_type="Desktop"} 1`,
+		`hornbill_asset_import_hornbill_call_duration_seconds_count{service="data",method="entityAddRecord"} 1`,
+		`hornbill_asset_import_cache_records{cache="users"} 2`,
+		`hornbill_asset_import_last_run_exit_code 0`,
+	} {
+		if !strings.Contains(string(content), want+"\n") {
+			t.Errorf("metrics text file missing %q, got:\n%s", want, content)
+		}
+	}
+	//CSV has no software inventory, so its assets are not counted as software inventory unchanged
+	if strings.Contains(string(content), "software_inventory") {
+		t.Errorf("unexpected software inventory series for a CSV import, got:\n%s", content)
+	}
+	if files, _ := os.ReadDir("."); len(files) > 0 {
+		for _, file := range files {
+			if strings.HasPrefix(file.Name(), ".asset_import.prom") {
+				t.Errorf("temporary metrics file left behind: %s", file.Name())
+			}
+		}
+	}
+}
@@ -0,0 +1,586 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// mockNode -- generic XML element, used to read the params of an XMLMC methodCall
+type mockNode struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Content  string     `xml:",chardata"`
+	Children []mockNode `xml:",any"`
+}
+
+func (n *mockNode) attr(name string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func (n *mockNode) child(name string) *mockNode {
+	if n == nil {
+		return nil
+	}
+	for i := range n.Children {
+		if n.Children[i].XMLName.Local == name {
+			return &n.Children[i]
+		}
+	}
+	return nil
+}
+
+func (n *mockNode) value(name string) string {
+	if c := n.child(name); c != nil {
+		return c.Content
+	}
+	return ""
+}
+
+func (n *mockNode) values(name string) (values []string) {
+	if n == nil {
+		return
+	}
+	for _, c := range n.Children {
+		if c.XMLName.Local == name {
+			values = append(values, c.Content)
+		}
+	}
+	return
+}
+
+// record -- returns the columns of an entity record. Columns sent with nil="true" are returned with a nil value
+func (n *mockNode) record() map[string]*string {
+	cols := make(map[string]*string)
+	if n == nil {
+		return cols
+	}
+	for _, c := range n.Children {
+		if c.attr("nil") == "true" {
+			cols[c.XMLName.Local] = nil
+			continue
+		}
+		value := c.Content
+		cols[c.XMLName.Local] = &value
+	}
+	return cols
+}
+
+// mockAssetType -- an asset type held by the mock instance
+type mockAssetType struct {
+	Class  string
+	TypeID int
+}
+
+// mockHornbill -- An in-process fake Hornbill instance, implementing the XMLMC calls made by the import.
+// Responses are returned as JSON or XML, depending on whether the caller asked for JSON
+type mockHornbill struct {
+	server *httptest.Server
+	mutex  sync.Mutex
+	nextID int
+
+	AssetTypes map[string]mockAssetType
+	Assets     map[string]map[string]string
+	Software   map[string]map[string]string
+	InPolicy   map[string]string
+	Users      []map[string]string
+	Sites      []map[string]string
+	Groups     []map[string]string
+	Apps       []string
+	Keys       map[string]string
+
+	// Calls counts the requests per service::method
+	Calls map[string]int
+	// Updated holds the columns sent by entityUpdateRecord, per h_pk_asset_id
+	Updated map[string][]string
+	// Failures holds an error to return for a service::method
+	Failures map[string]string
+	// HTTPErrors holds HTTP status codes to return, one per request, before a service::method is handled
+	HTTPErrors map[string][]int
+	// RateLimits holds the number of requests to a service::method to fail with a rate limit error before it is handled
+	RateLimits map[string]int
+	// HangUps holds the number of requests to a service::method to handle, then close the connection without a response
+	HangUps map[string]int
+	// Counts overrides the count returned by a count query, per queryName
+	Counts map[string]int
+	// OnCall is called with the service::method of each request, before it is handled
+	OnCall func(method string)
+}
+
+// newMockHornbill -- starts a mock instance, which is stopped when the test completes
+func newMockHornbill(t *testing.T) *mockHornbill {
+	m := &mockHornbill{
+		nextID:     1,
+		AssetTypes: make(map[string]mockAssetType),
+		Assets:     make(map[string]map[string]string),
+		Software:   make(map[string]map[string]string),
+		InPolicy:   make(map[string]string),
+		Keys:       make(map[string]string),
+		Calls:      make(map[string]int),
+		Updated:    make(map[string][]string),
+		Failures:   make(map[string]string),
+		HTTPErrors: make(map[string][]int),
+		RateLimits: make(map[string]int),
+		HangUps:    make(map[string]int),
+		Counts:     make(map[string]int),
+	}
+	m.server = httptest.NewServer(http.HandlerFunc(m.handle))
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+// URL -- the XMLMC endpoint, for use as the InstanceId
+func (m *mockHornbill) URL() string {
+	return m.server.URL + "/xmlmc/"
+}
+
+// AddAsset -- adds an asset record to the instance, returning its h_pk_asset_id
+func (m *mockHornbill) AddAsset(cols map[string]string) string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	id := m.newID()
+	asset := map[string]string{"h_pk_asset_id": id}
+	for k, v := range cols {
+		asset[k] = v
+	}
+	m.Assets[id] = asset
+	return id
+}
+
+// FindAsset -- returns the first asset with the column set to value
+func (m *mockHornbill) FindAsset(column, value string) map[string]string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, id := range m.sortedIDs(m.Assets) {
+		if m.Assets[id][column] == value {
+			return m.Assets[id]
+		}
+	}
+	return nil
+}
+
+// AssetSoftware -- returns the h_app_id of each software record held against an asset
+func (m *mockHornbill) AssetSoftware(assetID string) (appIDs []string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, sw := range m.Software {
+		if sw["h_fk_asset_id"] == assetID {
+			appIDs = append(appIDs, sw["h_app_id"])
+		}
+	}
+	sort.Strings(appIDs)
+	return
+}
+
+// CallCount -- returns the number of requests made to a service::method
+func (m *mockHornbill) CallCount(method string) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.Calls[method]
+}
+
+func (m *mockHornbill) newID() string {
+	id := strconv.Itoa(m.nextID)
+	m.nextID++
+	return id
+}
+
+func (m *mockHornbill) sortedIDs(records map[string]map[string]string) []string {
+	ids := make([]string, 0, len(records))
+	for id := range records {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, _ := strconv.Atoi(ids[i])
+		b, _ := strconv.Atoi(ids[j])
+		return a < b
+	})
+	return ids
+}
+
+func (m *mockHornbill) handle(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var call mockNode
+	if err := xml.Unmarshal(body, &call); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	method := call.attr("service") + "::" + call.attr("method")
+	params := call.child("params")
+	if params == nil {
+		params = &mockNode{}
+	}
+
+	if m.OnCall != nil {
+		m.OnCall(method)
+	}
+	m.mutex.Lock()
+	m.Calls[method]++
+	if codes := m.HTTPErrors[method]; len(codes) > 0 {
+		m.HTTPErrors[method] = codes[1:]
+		m.mutex.Unlock()
+		http.Error(w, http.StatusText(codes[0]), codes[0])
+		return
+	}
+	var (
+		result map[string]interface{}
+		err    error
+	)
+	if failure, ok := m.Failures[method]; ok {
+		err = errors.New(failure)
+	} else if m.RateLimits[method] > 0 {
+		m.RateLimits[method]--
+		err = errors.New("The API rate limit has been exceeded, please try again later")
+	} else {
+		result, err = m.dispatch(method, params)
+	}
+	hangUp := m.HangUps[method] > 0
+	if hangUp {
+		m.HangUps[method]--
+	}
+	m.mutex.Unlock()
+	if hangUp {
+		if conn, _, hijackErr := w.(http.Hijacker).Hijack(); hijackErr == nil {
+			conn.Close()
+		}
+		return
+	}
+
+	if r.Header.Get("Accept") == "text/json" {
+		w.Header().Set("Content-Type", "application/json")
+		response := map[string]interface{}{"@status": err == nil}
+		if err != nil {
+			response["state"] = map[string]interface{}{"code": "0200", "error": err.Error()}
+		} else if result != nil {
+			response["params"] = result
+		}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="utf-8" ?>`)
+	if err != nil {
+		buf.WriteString(`<methodCallResult status="fail"><state><code>0200</code><error>`)
+		xml.EscapeText(&buf, []byte(err.Error()))
+		buf.WriteString(`</error></state></methodCallResult>`)
+	} else {
+		buf.WriteString(`<methodCallResult status="ok">`)
+		writeMockXML(&buf, "params", result)
+		buf.WriteString(`</methodCallResult>`)
+	}
+	w.Header().Set("Content-Type", "text/xml")
+	w.Write(buf.Bytes())
+}
+
+// writeMockXML -- encodes a params value as XMLMC response elements. Slices become repeated elements
+func writeMockXML(buf *bytes.Buffer, name string, v interface{}) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		buf.WriteString("<" + name + ">")
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			writeMockXML(buf, k, value[k])
+		}
+		buf.WriteString("</" + name + ">")
+	case []map[string]interface{}:
+		for _, item := range value {
+			writeMockXML(buf, name, item)
+		}
+	case nil:
+		buf.WriteString("<" + name + "/>")
+	default:
+		buf.WriteString("<" + name + ">")
+		xml.EscapeText(buf, []byte(fmt.Sprintf("%v", value)))
+		buf.WriteString("</" + name + ">")
+	}
+}
+
+func (m *mockHornbill) dispatch(method string, params *mockNode) (map[string]interface{}, error) {
+	switch method {
+	case "data::queryExec":
+		return m.queryExec(params)
+	case "data::entityBrowseRecords2":
+		return m.entityBrowseRecords(params)
+	case "data::entityAddRecord":
+		return m.entityAddRecord(params)
+	case "data::entityUpdateRecord":
+		return m.entityUpdateRecord(params)
+	case "data::entityDeleteRecord":
+		return m.entityDeleteRecord(params)
+	case "admin::groupGetList2":
+		return m.groupGetList(params)
+	case "admin::groupCreate":
+		for _, group := range m.Groups {
+			if group["id"] == params.value("id") {
+				return nil, errors.New("A group with the ID " + params.value("id") + " already exists")
+			}
+		}
+		m.Groups = append(m.Groups, map[string]string{"id": params.value("id"), "name": params.value("name"), "type": params.value("type"), "parent": params.value("parent")})
+		return nil, nil
+	case "admin::keysafeGetKey":
+		data, ok := m.Keys[params.value("keyId")]
+		if !ok {
+			return nil, errors.New("The specified key does not exist")
+		}
+		return map[string]interface{}{"data": data, "title": "Key " + params.value("keyId"), "type": "mock"}, nil
+	case "apps/com.hornbill.core::getSitesList":
+		return m.getSitesList(params)
+	case "session::getApplicationList":
+		var apps []map[string]interface{}
+		for _, app := range m.Apps {
+			apps = append(apps, map[string]interface{}{"name": app})
+		}
+		return map[string]interface{}{"application": apps}, nil
+	case "system::logMessage":
+		return nil, nil
+	case "apps/com.hornbill.suppliermanager/SupplierAssets::addSupplierAsset":
+		return map[string]interface{}{"outcome": "success", "supplierAssetId": m.newID()}, nil
+	case "apps/com.hornbill.suppliermanager/SupplierContractAssets::addSupplierContractAsset":
+		return map[string]interface{}{"outcome": "success", "supplierContractAssetId": m.newID()}, nil
+	}
+	return nil, errors.New("mock: unsupported method " + method)
+}
+
+// page -- applies rowstart and limit to a list of rows
+func page(rows []map[string]interface{}, rowStart, limit string) []map[string]interface{} {
+	start, _ := strconv.Atoi(rowStart)
+	if start > len(rows) {
+		start = len(rows)
+	}
+	rows = rows[start:]
+	if max, err := strconv.Atoi(limit); err == nil && max < len(rows) {
+		rows = rows[:max]
+	}
+	return rows
+}
+
+func rowData(rows []map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"rowData": map[string]interface{}{"row": rows}}
+}
+
+func countData(count int) map[string]interface{} {
+	return rowData([]map[string]interface{}{{"count": strconv.Itoa(count)}})
+}
+
+func toRow(record map[string]string) map[string]interface{} {
+	row := make(map[string]interface{}, len(record))
+	for k, v := range record {
+		row[k] = v
+	}
+	return row
+}
+
+func (m *mockHornbill) queryExec(params *mockNode) (map[string]interface{}, error) {
+	queryParams := params.child("queryParams")
+	queryOptions := params.child("queryOptions")
+	if count, ok := m.Counts[params.value("queryName")]; ok && (queryOptions.value("queryType") == "count" || queryParams.value("getCount") == "true") {
+		return countData(count), nil
+	}
+	var rows []map[string]interface{}
+	switch params.value("queryName") {
+	case "getAssetsListForImport":
+		typeID := queryParams.value("typeId")
+		for _, id := range m.sortedIDs(m.Assets) {
+			asset := m.Assets[id]
+			if asset["h_class"] != queryParams.value("classId") || (typeID != "" && asset["h_type"] != typeID) {
+				continue
+			}
+			rows = append(rows, toRow(asset))
+		}
+		if queryOptions.value("queryType") == "count" {
+			return countData(len(rows)), nil
+		}
+	case "getUserAccountsList":
+		for _, user := range m.Users {
+			rows = append(rows, toRow(user))
+		}
+		if queryParams.value("getCount") == "true" {
+			return countData(len(rows)), nil
+		}
+	case "Asset.getInstalledSoftware":
+		for _, id := range m.sortedIDs(m.Software) {
+			if m.Software[id]["h_fk_asset_id"] == queryParams.value("assetId") {
+				rows = append(rows, toRow(m.Software[id]))
+			}
+		}
+		if queryOptions.value("resultType") == "count" {
+			return countData(len(rows)), nil
+		}
+	default:
+		return nil, errors.New("mock: unsupported query " + params.value("queryName"))
+	}
+	return rowData(page(rows, queryParams.value("rowstart"), queryParams.value("limit"))), nil
+}
+
+func (m *mockHornbill) entityBrowseRecords(params *mockNode) (map[string]interface{}, error) {
+	if params.value("entity") != "AssetsTypes" {
+		return nil, errors.New("mock: unsupported entity " + params.value("entity"))
+	}
+	name := params.child("searchFilter").value("value")
+	assetType, ok := m.AssetTypes[name]
+	if !ok {
+		return map[string]interface{}{"rowData": nil}, nil
+	}
+	return rowData([]map[string]interface{}{{"h_name": name, "h_class": assetType.Class, "h_pk_type_id": assetType.TypeID}}), nil
+}
+
+// applyColumns -- sets the columns of a record, returning the number of values changed
+func applyColumns(record map[string]string, cols map[string]*string) (changed int) {
+	for k, v := range cols {
+		current, exists := record[k]
+		if v == nil {
+			if exists {
+				delete(record, k)
+				changed++
+			}
+			continue
+		}
+		if !exists || current != *v {
+			record[k] = *v
+			changed++
+		}
+	}
+	return
+}
+
+func (m *mockHornbill) entityAddRecord(params *mockNode) (map[string]interface{}, error) {
+	primary := params.child("primaryEntityData").child("record").record()
+	switch params.value("entity") {
+	case "Asset":
+		id := m.newID()
+		asset := map[string]string{"h_pk_asset_id": id}
+		applyColumns(asset, primary)
+		applyColumns(asset, params.child("relatedEntityData").child("record").record())
+		m.Assets[id] = asset
+		return map[string]interface{}{"primaryEntityData": map[string]interface{}{"record": map[string]interface{}{"h_pk_asset_id": id}}}, nil
+	case "AssetsInstalledSoftware":
+		id := m.newID()
+		software := map[string]string{"h_pk_id": id}
+		applyColumns(software, primary)
+		m.Software[id] = software
+		return map[string]interface{}{"primaryEntityData": map[string]interface{}{"record": map[string]interface{}{"h_pk_id": id}}}, nil
+	case "Site":
+		id := m.newID()
+		site := map[string]string{"h_id": id}
+		applyColumns(site, primary)
+		m.Sites = append(m.Sites, site)
+		return map[string]interface{}{"primaryEntityData": map[string]interface{}{"record": map[string]interface{}{"h_id": id, "h_site_name": site["h_site_name"]}}}, nil
+	case "ConfigurationItemsInPolicy":
+		id := m.newID()
+		if entityID := primary["h_entity_id"]; entityID != nil {
+			m.InPolicy[id] = *entityID
+		}
+		return map[string]interface{}{"primaryEntityData": map[string]interface{}{"record": map[string]interface{}{"h_pk_confiteminpolicyid": id}}}, nil
+	}
+	return nil, errors.New("mock: unsupported entity " + params.value("entity"))
+}
+
+func (m *mockHornbill) entityUpdateRecord(params *mockNode) (map[string]interface{}, error) {
+	entity := params.value("entity")
+	if entity != "Asset" && !strings.HasPrefix(entity, "Assets") {
+		return nil, errors.New("mock: unsupported entity " + entity)
+	}
+	primary := params.child("primaryEntityData").child("record").record()
+	idValue := primary["h_pk_asset_id"]
+	if idValue == nil {
+		return nil, errors.New("The primary key value was not specified")
+	}
+	asset, ok := m.Assets[*idValue]
+	if !ok {
+		return nil, errors.New("The specified record does not exist: " + *idValue)
+	}
+	related := params.child("relatedEntityData").child("record").record()
+	for _, cols := range []map[string]*string{primary, related} {
+		for k := range cols {
+			if k != "h_pk_asset_id" {
+				m.Updated[*idValue] = append(m.Updated[*idValue], k)
+			}
+		}
+	}
+	changed := applyColumns(asset, primary)
+	changed += applyColumns(asset, related)
+	if changed == 0 {
+		return nil, errors.New("There are no values to update")
+	}
+	return map[string]interface{}{"primaryEntityData": map[string]interface{}{"record": map[string]interface{}{"h_pk_asset_id": *idValue}}}, nil
+}
+
+func (m *mockHornbill) entityDeleteRecord(params *mockNode) (map[string]interface{}, error) {
+	key := params.value("keyValue")
+	var records map[string]map[string]string
+	switch params.value("entity") {
+	case "AssetsInstalledSoftware":
+		records = m.Software
+	case "ConfigurationItemsInPolicy":
+		if _, ok := m.InPolicy[key]; !ok {
+			return nil, errors.New("The specified record does not exist: " + key)
+		}
+		delete(m.InPolicy, key)
+		return nil, nil
+	default:
+		return nil, errors.New("mock: unsupported entity " + params.value("entity"))
+	}
+	if _, ok := records[key]; !ok {
+		return nil, errors.New("The specified record does not exist: " + key)
+	}
+	delete(records, key)
+	return nil, nil
+}
+
+func (m *mockHornbill) groupGetList(params *mockNode) (map[string]interface{}, error) {
+	types := params.values("type")
+	var groups []map[string]interface{}
+	for _, group := range m.Groups {
+		for _, groupType := range types {
+			if group["type"] == groupType {
+				groups = append(groups, toRow(group))
+				break
+			}
+		}
+	}
+	pageInfo := params.child("pageInfo")
+	pageIndex, _ := strconv.Atoi(pageInfo.value("pageIndex"))
+	pageSize, _ := strconv.Atoi(pageInfo.value("pageSize"))
+	if pageIndex < 1 || pageSize < 1 {
+		return nil, errors.New("mock: invalid pageInfo")
+	}
+	maxPages := (len(groups) + pageSize - 1) / pageSize
+	groups = page(groups, strconv.Itoa((pageIndex-1)*pageSize), strconv.Itoa(pageSize))
+	return map[string]interface{}{"group": groups, "maxPages": maxPages}, nil
+}
+
+func (m *mockHornbill) getSitesList(params *mockNode) (map[string]interface{}, error) {
+	var sites []map[string]interface{}
+	for _, site := range m.Sites {
+		sites = append(sites, toRow(site))
+	}
+	pageRows := page(sites, params.value("rowstart"), params.value("limit"))
+	//Hornbill returns a single row as an object rather than an array
+	var siteJSON []byte
+	if len(sites) == 1 {
+		siteJSON, _ = json.Marshal(map[string]interface{}{"row": pageRows[0]})
+	} else {
+		siteJSON, _ = json.Marshal(map[string]interface{}{"row": pageRows})
+	}
+	return map[string]interface{}{"sites": string(siteJSON), "count": len(sites)}, nil
+}
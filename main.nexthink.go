@@ -14,32 +14,80 @@ import (
 	"time"
 )
 
-func getAssetsFromNexthink(assetType assetTypesStruct) (map[string]map[string]interface{}, error) {
+// nexthinkSource -- Source implementation for the Nexthink query API
+type nexthinkSource struct {
+	conf sourceConfStruct
+	key  keyDataStruct
+}
+
+func init() {
+	registerSource(func(conf sourceConfStruct, key keyDataStruct) Source {
+		return &nexthinkSource{conf: conf, key: key}
+	}, "nexthink")
+}
+
+// ValidateConfig -- Checks the Keysafe key holds the Nexthink server details
+func (s *nexthinkSource) ValidateConfig() error {
+	if s.key.Server == "" {
+		return errors.New("no Nexthink server defined in the Keysafe key")
+	}
+	return nil
+}
+
+// GetAssets -- Runs the asset type NXQL query
+func (s *nexthinkSource) GetAssets(assetType assetTypesStruct) (map[string]map[string]interface{}, error) {
+	return collectAssets(s, assetType)
+}
+
+// StreamAssets -- Runs the asset type NXQL query, emitting each device returned
+func (s *nexthinkSource) StreamAssets(assetType assetTypesStruct, emit assetEmitter) error {
+	return s.getAssetsFromNexthink(assetType, emit)
+}
+
+// GetSoftwareInventory -- Runs the asset type software inventory NXQL query for a single device
+func (s *nexthinkSource) GetSoftwareInventory(assetRecord map[string]interface{}, assetType assetTypesStruct, buffer *bytes.Buffer) (map[string]map[string]interface{}, string, error) {
+	if assetType.SoftwareInventory.Query == "" || assetType.SoftwareInventory.AssetIDColumn == "" {
+		return make(map[string]map[string]interface{}), "", nil
+	}
+	swAssetID := iToS(assetRecord["id"])
+	if regexTemplate.MatchString(assetType.SoftwareInventory.AssetIDColumn) {
+		swAssetID = getSoftwareAssetID(assetRecord, assetType)
+	}
+	if swAssetID == "" {
+		return make(map[string]map[string]interface{}), "", errors.New("unable to read software inventory records from source db, asset ID not found in db record")
+	}
+	softwareRecords, softwareRecordsHash, err := s.queryNexthinkSoftwareInventoryRecords(swAssetID, assetType, buffer)
+	if err != nil {
+		err = errors.New("Unable to read software inventory records from Nexthink:" + err.Error())
+	}
+	return softwareRecords, softwareRecordsHash, err
+}
+
+func (s *nexthinkSource) getAssetsFromNexthink(assetType assetTypesStruct, emit assetEmitter) error {
 	//Initialise Asset Map
 	var arrAssetMaps []map[string]interface{}
-	returnMap := make(map[string]map[string]interface{})
 	logger(3, " ", false, false)
 	logger(3, "[NEXTHINK] Running Nexthink query for "+assetType.AssetType+" assets. Please wait...", true, true)
 
-	strUrl := key.Server + "/query?"
+	strUrl := s.key.Server + "/query?"
 	if assetType.NexthinkPlatform != "" {
 		strUrl += "platform=" + assetType.NexthinkPlatform + "&"
 	}
 	strUrl += "query=" + url.QueryEscape(assetType.Query)
 	strUrl += "&format=json"
-	req, err := http.NewRequest("GET", strUrl, nil)
+	req, err := http.NewRequestWithContext(importCtx, "GET", strUrl, nil)
 
 	if err != nil {
-		return returnMap, err
+		return err
 	}
-	auth := base64.StdEncoding.EncodeToString([]byte(key.Username + ":" + key.Password))
+	auth := base64.StdEncoding.EncodeToString([]byte(s.key.Username + ":" + s.key.Password))
 	req.Header.Set("Authorization", "Basic "+auth)
 	req.Header.Set("User-Agent", appName+"/"+version)
 
 	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyFromEnvironment}}
-	resp, err := client.Do(req)
+	resp, err := doRequestRetry(client, req, nil)
 	if err != nil {
-		return returnMap, err
+		return err
 	}
 	defer resp.Body.Close()
 
@@ -49,39 +97,42 @@ func getAssetsFromNexthink(assetType assetTypesStruct) (map[string]map[string]in
 		err = errors.New(errorString)
 		//Drain the body so we can reuse the connection
 		io.Copy(io.Discard, resp.Body)
-		return returnMap, err
+		return err
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return returnMap, errors.New("cant read the body of the response: " + err.Error())
+		return errors.New("cant read the body of the response: " + err.Error())
 	}
 	if err := json.Unmarshal([]byte(body), &arrAssetMaps); err != nil {
 		log.Fatal(err)
 	}
 	for _, v := range arrAssetMaps {
 		assetIdentifier := fmt.Sprintf("%s", v[assetType.AssetIdentifier.SourceColumn])
-		returnMap[assetIdentifier] = make(map[string]interface{})
+		assetRecord := make(map[string]interface{})
 		for field, value := range v {
 			switch actualVal := value.(type) {
 			case []interface{}:
-				returnMap[assetIdentifier][field] = actualVal[len(actualVal)-1]
+				assetRecord[field] = actualVal[len(actualVal)-1]
 			case float64:
 				if field == "system_drive_capacity" || field == "total_ram" {
-					returnMap[assetIdentifier][field] = byteCountSI(actualVal)
+					assetRecord[field] = byteCountSI(actualVal)
 				} else {
-					returnMap[assetIdentifier][field] = actualVal
+					assetRecord[field] = actualVal
 				}
 			default:
 				if field == "last_logon_time" {
 					t, _ := time.Parse("2006-01-02T15:04:05", iToS(actualVal))
 					actualVal = t.Format("2006-01-02 15:04:05")
 				}
-				returnMap[assetIdentifier][field] = actualVal
+				assetRecord[field] = actualVal
 			}
 		}
+		if !emit(assetIdentifier, assetRecord) {
+			return nil
+		}
 	}
-	return returnMap, nil
+	return nil
 }
 
 func byteCountSI(b float64) string {
@@ -98,7 +149,7 @@ func byteCountSI(b float64) string {
 		float64(b)/float64(div), "kMGTPE"[exp])
 }
 
-func queryNexthinkSoftwareInventoryRecords(assetID string, assetType assetTypesStruct, buffer *bytes.Buffer) (map[string]map[string]interface{}, string, error) {
+func (s *nexthinkSource) queryNexthinkSoftwareInventoryRecords(assetID string, assetType assetTypesStruct, buffer *bytes.Buffer) (map[string]map[string]interface{}, string, error) {
 
 	var (
 		returnMap = make(map[string]map[string]interface{})
@@ -111,21 +162,21 @@ func queryNexthinkSoftwareInventoryRecords(assetID string, assetType assetTypesS
 	var arrSoftwareMaps []map[string]interface{}
 	buffer.WriteString(loggerGen(3, "[NEXTHINK] Running Nexthink query for software against "+assetID+" asset. Please wait..."))
 
-	strUrl := key.Server + "/query?"
+	strUrl := s.key.Server + "/query?"
 	strUrl += "query=" + url.QueryEscape(sqlAssetQuery)
 	strUrl += "&format=json"
 
-	req, err := http.NewRequest("GET", strUrl, nil)
+	req, err := http.NewRequestWithContext(importCtx, "GET", strUrl, nil)
 
 	if err != nil {
 		return returnMap, hash, err
 	}
-	auth := base64.StdEncoding.EncodeToString([]byte(key.Username + ":" + key.Password))
+	auth := base64.StdEncoding.EncodeToString([]byte(s.key.Username + ":" + s.key.Password))
 	req.Header.Set("Authorization", "Basic "+auth)
 	req.Header.Set("User-Agent", appName+"/"+version)
 
 	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyFromEnvironment}}
-	resp, err := client.Do(req)
+	resp, err := doRequestRetry(client, req, buffer)
 	if err != nil {
 		return returnMap, hash, err
 	}
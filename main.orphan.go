@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	apiLib "github.com/hornbill/goApiLib"
+)
+
+const (
+	orphanActionRetire = "retire"
+	orphanActionState  = "state"
+	orphanActionTag    = "tag"
+
+	// Default for MaxPercent, when not set in the configuration
+	orphanDefaultMaxPercent = 10
+)
+
+// checkOrphanConfig -- Validates the Orphans configuration of an asset type
+func checkOrphanConfig(assetType assetTypesStruct) error {
+	if assetType.Orphans == nil {
+		return nil
+	}
+	switch strings.ToLower(assetType.Orphans.Action) {
+	case orphanActionRetire:
+		//The Retired value of the operational state list differs between instances, so isn't assumed
+		if assetType.Orphans.OperationalState == "" {
+			return errors.New("Orphans Action Retire requires the OperationalState value of Retired assets on the instance")
+		}
+	case orphanActionState:
+		if assetType.Orphans.OperationalState == "" && assetType.Orphans.RecordState == "" {
+			return errors.New("Orphans Action State requires an OperationalState and/or RecordState")
+		}
+	case orphanActionTag:
+		if assetType.Orphans.TagColumn == "" {
+			return errors.New("Orphans Action Tag requires a TagColumn")
+		}
+	default:
+		return errors.New("unsupported Orphans Action: " + assetType.Orphans.Action)
+	}
+	if assetType.Orphans.MaxPercent < 0 || assetType.Orphans.MaxPercent > 100 {
+		return errors.New("Orphans MaxPercent must be between 0 and 100")
+	}
+	return nil
+}
+
+// orphanColumns -- returns the Hornbill asset columns, and their values, to set against orphaned assets
+func orphanColumns(orphans *orphanConfStruct) map[string]string {
+	cols := make(map[string]string)
+	switch strings.ToLower(orphans.Action) {
+	case orphanActionRetire:
+		cols["h_operational_state"] = orphans.OperationalState
+		if orphans.RecordState != "" {
+			cols["h_record_state"] = orphans.RecordState
+		}
+	case orphanActionState:
+		if orphans.OperationalState != "" {
+			cols["h_operational_state"] = orphans.OperationalState
+		}
+		if orphans.RecordState != "" {
+			cols["h_record_state"] = orphans.RecordState
+		}
+	case orphanActionTag:
+		cols[orphans.TagColumn] = orphans.TagValue
+	}
+	return cols
+}
+
+// processOrphans -- Compares the cached Hornbill asset records with the records returned by the source, and
+// actions the Hornbill assets that no longer appear in the source as per the asset type Orphans configuration
+func processOrphans(sourceIDs map[string]int, assetsCache map[string]map[string]interface{}, assetType assetTypesStruct) {
+	if assetType.Orphans == nil || len(assetsCache) == 0 {
+		return
+	}
+	cols := orphanColumns(assetType.Orphans)
+
+	var orphanIDs []string
+	for assetID, asset := range assetsCache {
+		if _, ok := sourceIDs[assetID]; ok {
+			continue
+		}
+		//Skip orphans that have already been actioned
+		actioned := true
+		for col, val := range cols {
+			if asset[col] == nil || iToS(asset[col]) != val {
+				actioned = false
+				break
+			}
+		}
+		if actioned {
+			continue
+		}
+		orphanIDs = append(orphanIDs, assetID)
+	}
+	addCounter(assetType.AssetType, counterOrphansFound, uint64(len(orphanIDs)))
+	if len(orphanIDs) == 0 {
+		logger(3, "No orphaned "+assetType.AssetType+" assets found", true, true)
+		return
+	}
+
+	maxPercent := assetType.Orphans.MaxPercent
+	if maxPercent == 0 {
+		maxPercent = orphanDefaultMaxPercent
+	}
+	orphanPercent := float64(len(orphanIDs)) / float64(len(assetsCache)) * 100
+	if orphanPercent > maxPercent {
+		logger(4, fmt.Sprintf("%d of %d %s assets in Hornbill (%.1f%%) no longer appear in the source, which exceeds the Orphans MaxPercent of %.1f%%. No orphaned assets have been actioned.", len(orphanIDs), len(assetsCache), assetType.AssetType, orphanPercent, maxPercent), true, true)
+		addCounter(assetType.AssetType, counterOrphansSkipped, uint64(len(orphanIDs)))
+		return
+	}
+
+	logger(3, "Processing "+strconv.Itoa(len(orphanIDs))+" orphaned "+assetType.AssetType+" assets...", true, true)
+	espXmlmc := apiLib.NewXmlmcInstance(importConf.InstanceID)
+	espXmlmc.SetAPIKey(importConf.APIKey)
+	var buffer bytes.Buffer
+	for _, assetID := range orphanIDs {
+		assetPK := iToS(assetsCache[assetID]["h_pk_asset_id"])
+		err := updateOrphan(assetPK, cols, espXmlmc, &buffer)
+		if err != nil {
+			incCounter(assetType.AssetType, counterOrphansFailed)
+			buffer.WriteString(loggerGen(4, "Unable to action orphaned asset ["+assetID+"]: "+err.Error()))
+		} else if configDryRun {
+			incCounter(assetType.AssetType, counterOrphansSkipped)
+		} else {
+			incCounter(assetType.AssetType, counterOrphansActioned)
+			buffer.WriteString(loggerGen(1, "Orphaned asset actioned: "+assetID+" ["+assetPK+"]"))
+		}
+		loggerWriteAssetBuffer(redactRecordValues(buffer.String(), assetsCache[assetID]), reportAssetStruct{AssetType: assetType.AssetType, SourceID: assetID, HornbillAssetID: assetPK, Action: "orphan"})
+		buffer.Reset()
+	}
+}
+
+// updateOrphan -- Sets the orphan columns against a Hornbill asset record
+func updateOrphan(assetPK string, cols map[string]string, espXmlmc *apiLib.XmlmcInstStruct, buffer *bytes.Buffer) error {
+	espXmlmc.SetParam("application", appServiceManager)
+	espXmlmc.SetParam("entity", "Asset")
+	espXmlmc.OpenElement("primaryEntityData")
+	espXmlmc.OpenElement("record")
+	espXmlmc.SetParam("h_pk_asset_id", assetPK)
+	for col, val := range cols {
+		espXmlmc.SetParam(col, val)
+	}
+	espXmlmc.SetParam("h_last_updated", time.Now().Format("2006-01-02 15:04:05"))
+	espXmlmc.SetParam("h_last_updated_by", "Import - Orphan")
+	espXmlmc.CloseElement("record")
+	espXmlmc.CloseElement("primaryEntityData")
+	XMLSTRING := espXmlmc.GetParam()
+
+	if configDryRun {
+		buffer.WriteString(loggerGen(1, "Orphaned Asset Update XML: "+XMLSTRING))
+		espXmlmc.ClearParam()
+		return nil
+	}
+	debugLog(buffer, "Orphaned Asset Update XML:", XMLSTRING)
+	XMLUpdate, xmlmcErr := invokeRetry(espXmlmc, "data", "entityUpdateRecord", buffer)
+	if xmlmcErr != nil {
+		buffer.WriteString(loggerGen(1, "API Call XML: "+XMLSTRING))
+		return errors.New("API Call failed when updating orphaned asset: " + xmlmcErr.Error())
+	}
+	var xmlRespon xmlmcResponse
+	err := xml.Unmarshal([]byte(XMLUpdate), &xmlRespon)
+	if err != nil {
+		buffer.WriteString(loggerGen(1, "API Call XML: "+XMLSTRING))
+		return errors.New("Unable to read response from Hornbill instance when updating orphaned asset: " + err.Error())
+	}
+	if xmlRespon.MethodResult != "ok" && xmlRespon.State.Error != "There are no values to update" {
+		buffer.WriteString(loggerGen(1, "API Call XML: "+XMLSTRING))
+		return errors.New("Unable to update orphaned asset: " + xmlRespon.State.Error)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// addOrphanTestAssets -- adds the two assets of testCSV, and two assets missing from it, to the mock instance. Returns
+// the h_pk_asset_id of the missing assets
+func addOrphanTestAssets(m *mockHornbill) []string {
+	m.AddAsset(map[string]string{"h_class": "computer", "h_type": "1", "h_name": "Desktop001", "h_serial_number": "SERIAL001"})
+	m.AddAsset(map[string]string{"h_class": "computer", "h_type": "1", "h_name": "Desktop002", "h_serial_number": "SERIAL002"})
+	return []string{
+		m.AddAsset(map[string]string{"h_class": "computer", "h_type": "1", "h_name": "Gone001", "h_serial_number": "SERIAL998"}),
+		m.AddAsset(map[string]string{"h_class": "computer", "h_type": "1", "h_name": "Gone002", "h_serial_number": "SERIAL999"}),
+	}
+}
+
+func TestImportOrphansMaxPercent(t *testing.T) {
+	m := newImportTest(t)
+	orphanIDs := addOrphanTestAssets(m)
+	os.WriteFile("desktops.csv", []byte(testCSV), 0644)
+	loadTestConfig(t, m,
+		map[string]interface{}{"Source": "csv", "CSV": map[string]interface{}{"CommaCharacter": ","}},
+		desktopAssetType(map[string]interface{}{
+			"CSVFile": "desktops.csv",
+			"Orphans": map[string]interface{}{"Action": "retire", "OperationalState": "2", "MaxPercent": 25},
+		}))
+
+	runImport()
+
+	//Half of the Hornbill assets are missing from the source, over the 25% allowed, so none are touched
+	if counterTotal(counterOrphansFound) != 2 || counterTotal(counterOrphansSkipped) != 2 || counterTotal(counterOrphansActioned) != 0 {
+		t.Errorf("expected 2 orphans found and skipped, got %+v", counterSnapshot().total)
+	}
+	for _, id := range orphanIDs {
+		if cols := m.Updated[id]; len(cols) > 0 {
+			t.Errorf("orphan %s updated over the MaxPercent threshold: %v", id, cols)
+		}
+		if state := m.Assets[id]["h_operational_state"]; state != "" {
+			t.Errorf("orphan %s h_operational_state = %q, want it untouched", id, state)
+		}
+	}
+}
+
+func TestImportOrphansActionedOnce(t *testing.T) {
+	m := newImportTest(t)
+	orphanIDs := addOrphanTestAssets(m)
+	os.WriteFile("desktops.csv", []byte(testCSV), 0644)
+	loadTestConfig(t, m,
+		map[string]interface{}{"Source": "csv", "CSV": map[string]interface{}{"CommaCharacter": ","}},
+		desktopAssetType(map[string]interface{}{
+			"CSVFile": "desktops.csv",
+			"Orphans": map[string]interface{}{"Action": "retire", "OperationalState": "2", "RecordState": "1", "MaxPercent": 50},
+		}))
+
+	runImport()
+
+	if counterTotal(counterOrphansFound) != 2 || counterTotal(counterOrphansActioned) != 2 {
+		t.Fatalf("expected 2 orphans found and actioned, got %+v", counterSnapshot().total)
+	}
+	for _, id := range orphanIDs {
+		if asset := m.Assets[id]; asset["h_operational_state"] != "2" || asset["h_record_state"] != "1" {
+			t.Errorf("orphan %s not retired: %v", id, asset)
+		}
+	}
+
+	//The orphans already hold the retired state, so a second run finds nothing to action
+	resetRun()
+	updates := m.CallCount("data::entityUpdateRecord")
+	runImport()
+
+	if counterTotal(counterOrphansFound) != 0 || counterTotal(counterOrphansActioned) != 0 {
+		t.Errorf("expected no orphans actioned again, got %+v", counterSnapshot().total)
+	}
+	if calls := m.CallCount("data::entityUpdateRecord"); calls != updates {
+		t.Errorf("expected no further updates, got %d", calls-updates)
+	}
+}
+
+func TestCheckOrphanConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		orphans orphanConfStruct
+		valid   bool
+	}{
+		{"retire", orphanConfStruct{Action: "Retire", OperationalState: "2"}, true},
+		{"retire without an operational state", orphanConfStruct{Action: "Retire", RecordState: "1"}, false},
+		{"state", orphanConfStruct{Action: "State", RecordState: "1"}, true},
+		{"state without a state", orphanConfStruct{Action: "State"}, false},
+		{"tag without a column", orphanConfStruct{Action: "Tag"}, false},
+		{"unknown action", orphanConfStruct{Action: "Delete"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orphans := tt.orphans
+			err := checkOrphanConfig(assetTypesStruct{AssetType: "Desktop", Orphans: &orphans})
+			if (err == nil) != tt.valid {
+				t.Errorf("checkOrphanConfig() error = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// sourceAssetStruct -- A single source record passing through the asset pipeline, with its 1-based position in the source
+type sourceAssetStruct struct {
+	ID       string
+	Record   map[string]interface{}
+	Position int
+}
+
+// processedAssetsStruct -- The source records seen by processAssets, for the watermark and orphan processing.
+// sourceIDs holds the position of the record processed for each asset ID
+type processedAssetsStruct struct {
+	sourceIDs map[string]int
+	watermark string
+}
+
+// assetStreamStruct -- The source records for an asset type, streaming from a producer goroutine reading the source
+// to the workers in processAssets. The channel is buffered to keep the workers busy, while only holding a handful
+// of records in memory at once
+type assetStreamStruct struct {
+	records chan sourceAssetStruct
+	errc    chan error
+	stop    context.CancelFunc
+	peeked  *sourceAssetStruct
+}
+
+// streamAssets -- starts reading the source records for an asset type. Sources that don't implement StreamingSource
+// are read in full with GetAssets, then emitted from the returned map
+func streamAssets(src Source, assetType assetTypesStruct) *assetStreamStruct {
+	ctx, stop := context.WithCancel(importCtx)
+	stream := &assetStreamStruct{
+		records: make(chan sourceAssetStruct, configMaxRoutines*2),
+		errc:    make(chan error, 1),
+		stop:    stop,
+	}
+	position := 0
+	emit := func(assetID string, record map[string]interface{}) bool {
+		position++
+		select {
+		case stream.records <- sourceAssetStruct{ID: assetID, Record: record, Position: position}:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	go func() {
+		var err error
+		start := time.Now()
+		if streaming, ok := src.(StreamingSource); ok {
+			err = streaming.StreamAssets(assetType, emit)
+		} else {
+			var arrAssets map[string]map[string]interface{}
+			arrAssets, err = src.GetAssets(assetType)
+			for assetID, record := range arrAssets {
+				if !emit(assetID, record) {
+					break
+				}
+			}
+		}
+		metricsSet("source_query_duration_seconds", time.Since(start).Seconds(), "asset_type", assetType.AssetType)
+		metricsSet("source_records", float64(position), "asset_type", assetType.AssetType)
+		close(stream.records)
+		stream.errc <- err
+	}()
+	return stream
+}
+
+// bufferedStream -- returns a stream of records already read from the source, closing with the source error
+func bufferedStream(records []sourceAssetStruct, err error) *assetStreamStruct {
+	stream := &assetStreamStruct{
+		records: make(chan sourceAssetStruct, len(records)),
+		errc:    make(chan error, 1),
+		stop:    func() {},
+	}
+	for _, asset := range records {
+		stream.records <- asset
+	}
+	close(stream.records)
+	stream.errc <- err
+	return stream
+}
+
+// next -- returns the next source record, blocking until one is read. Returns false once the source is exhausted
+func (s *assetStreamStruct) next() (sourceAssetStruct, bool) {
+	if s.peeked != nil {
+		asset := *s.peeked
+		s.peeked = nil
+		return asset, true
+	}
+	asset, ok := <-s.records
+	return asset, ok
+}
+
+// empty -- returns true if the source returned no records, waiting for the first record to be read
+func (s *assetStreamStruct) empty() bool {
+	if s.peeked != nil {
+		return false
+	}
+	asset, ok := <-s.records
+	if !ok {
+		return true
+	}
+	s.peeked = &asset
+	return false
+}
+
+// close -- stops the producer if it is still reading the source, and returns the error the source stopped with.
+// Records not yet read are discarded
+func (s *assetStreamStruct) close() error {
+	s.stop()
+	for range s.records {
+	}
+	s.peeked = nil
+	return <-s.errc
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+)
+
+// testSource -- Source returning a fixed set of records, or endless records when streaming
+type testSource struct {
+	records map[string]map[string]interface{}
+	err     error
+}
+
+func (s *testSource) ValidateConfig() error { return nil }
+
+func (s *testSource) GetAssets(assetType assetTypesStruct) (map[string]map[string]interface{}, error) {
+	return s.records, s.err
+}
+
+func (s *testSource) GetSoftwareInventory(assetRecord map[string]interface{}, assetType assetTypesStruct, buffer *bytes.Buffer) (map[string]map[string]interface{}, string, error) {
+	return nil, "", nil
+}
+
+type testStreamingSource struct{ testSource }
+
+func (s *testStreamingSource) StreamAssets(assetType assetTypesStruct, emit assetEmitter) error {
+	for i := 0; ; i++ {
+		if !emit(strconv.Itoa(i), map[string]interface{}{"ID": i}) {
+			return s.err
+		}
+	}
+}
+
+func TestAssetStream(t *testing.T) {
+	newImportTest(t)
+
+	//Sources without StreamAssets are read in full, then emitted
+	src := &testSource{records: map[string]map[string]interface{}{"a": {}, "b": {}, "c": {}}}
+	stream := streamAssets(src, assetTypesStruct{})
+	if stream.empty() {
+		t.Fatal("expected records in the stream")
+	}
+	seen := make(map[string]bool)
+	for {
+		asset, ok := stream.next()
+		if !ok {
+			break
+		}
+		seen[asset.ID] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("expected 3 records from the stream, got %d", len(seen))
+	}
+	if err := stream.close(); err != nil {
+		t.Errorf("unexpected source error: %v", err)
+	}
+
+	stream = streamAssets(&testSource{err: errors.New("source down")}, assetTypesStruct{})
+	if !stream.empty() {
+		t.Error("expected an empty stream from a failed source")
+	}
+	if err := stream.close(); err == nil || err.Error() != "source down" {
+		t.Errorf("expected the source error, got %v", err)
+	}
+
+	//Closing the stream stops a source part way through
+	stream = streamAssets(&testStreamingSource{testSource{err: errors.New("stopped")}}, assetTypesStruct{})
+	if asset, ok := stream.next(); !ok || asset.ID != "0" {
+		t.Fatalf("expected the first streamed record, got %v", asset)
+	}
+	if err := stream.close(); err == nil || err.Error() != "stopped" {
+		t.Errorf("expected the source to stop when the stream is closed, got %v", err)
+	}
+}
+
+func TestImportStreamingCSV(t *testing.T) {
+	const streamCSV = `Name,SerialNumber,Model,Owner,Site,Company,Updated
+Desktop001,SERIAL001,Inspiron 3891,jsmith,Head Office,Acme,2026-01-02
+Desktop002,SERIAL002,Inspiron 3891,bjones,Branch Office,Acme,2026-03-04
+Duplicate001,SERIAL001,Inspiron 3891,bjones,Branch Office,Acme,2026-01-01
+`
+	m := newImportTest(t)
+	m.AddAsset(map[string]string{"h_class": "computer", "h_type": "1", "h_name": "Gone", "h_serial_number": "SERIAL999"})
+	os.WriteFile("desktops.csv", []byte(streamCSV), 0644)
+	loadTestConfig(t, m,
+		map[string]interface{}{"Source": "csv", "CSV": map[string]interface{}{"CommaCharacter": ","}},
+		desktopAssetType(map[string]interface{}{
+			"CSVFile":         "desktops.csv",
+			"WatermarkColumn": "Updated",
+			"Orphans":         map[string]interface{}{"Action": "tag", "TagColumn": "h_notes", "TagValue": "orphan", "MaxPercent": 100},
+		}))
+
+	runImport()
+
+	//The first record for an asset identifier is imported, and the duplicate skipped
+	if counterTotal(counterCreated) != 2 {
+		t.Errorf("expected 2 assets created, got %d", counterTotal(counterCreated))
+	}
+	if asset := m.FindAsset("h_serial_number", "SERIAL001"); asset == nil || asset["h_name"] != "Desktop001" {
+		t.Errorf("expected SERIAL001 created from the first source record, got %v", asset)
+	}
+	//Assets not read from the source are still found as orphans
+	if counterTotal(counterOrphansFound) != 1 || counterTotal(counterOrphansActioned) != 1 {
+		t.Errorf("expected 1 orphan found and actioned, got %d found and %d actioned", counterTotal(counterOrphansFound), counterTotal(counterOrphansActioned))
+	}
+	if watermark := importState.Watermarks["Desktop"]; watermark != "2026-03-04" {
+		t.Errorf("expected the watermark from the streamed records, got %q", watermark)
+	}
+}
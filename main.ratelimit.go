@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenBucketStruct -- token bucket allowing rate calls per second, with bursts of up to burst calls.
+// Tokens go negative as callers reserve calls ahead of the rate, so waiting callers are served in turn
+type tokenBucketStruct struct {
+	mutex  sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiterStruct -- the buckets shared by every XMLMC session: one for all calls, and one per limited service
+type rateLimiterStruct struct {
+	global   *tokenBucketStruct
+	services map[string]*tokenBucketStruct
+
+	mutex  sync.Mutex
+	waits  uint64
+	waited time.Duration
+}
+
+var apiLimiter *rateLimiterStruct
+
+// newTokenBucket -- returns a full bucket. A burst under 1 is the rate rounded up
+func newTokenBucket(rate float64, burst int) *tokenBucketStruct {
+	if burst < 1 {
+		burst = int(math.Ceil(rate))
+	}
+	return &tokenBucketStruct{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// reserve -- takes a token from the bucket, returning how long after now the call must wait for it
+func (b *tokenBucketStruct) reserve(now time.Time) time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if now.After(b.last) {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+	}
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// checkRateLimitConfig -- Validates the RateLimit configuration
+func checkRateLimitConfig() error {
+	if importConf.RateLimit.CallsPerSecond < 0 || importConf.RateLimit.Burst < 0 {
+		return errors.New("RateLimit CallsPerSecond and Burst must not be negative")
+	}
+	for service, rate := range importConf.RateLimit.Services {
+		if rate <= 0 {
+			return errors.New("RateLimit Services [" + service + "] must be greater than 0 calls per second")
+		}
+	}
+	return nil
+}
+
+// initRateLimiter -- sets up the rate limiter from the RateLimit configuration. Calls are not limited when neither
+// CallsPerSecond nor Services are set
+func initRateLimiter() {
+	apiLimiter = nil
+	conf := importConf.RateLimit
+	if conf.CallsPerSecond == 0 && len(conf.Services) == 0 {
+		return
+	}
+	apiLimiter = &rateLimiterStruct{services: make(map[string]*tokenBucketStruct)}
+	if conf.CallsPerSecond > 0 {
+		apiLimiter.global = newTokenBucket(conf.CallsPerSecond, conf.Burst)
+		logger(3, "Hornbill API calls limited to "+fmt.Sprintf("%v", conf.CallsPerSecond)+" per second", true, true)
+	}
+	for service, rate := range conf.Services {
+		apiLimiter.services[strings.ToLower(service)] = newTokenBucket(rate, 0)
+		logger(3, "Hornbill API calls to the "+service+" service limited to "+fmt.Sprintf("%v", rate)+" per second", true, true)
+	}
+}
+
+// waitRateLimit -- blocks until an XMLMC call to the service is allowed by the global and service limits. Returns
+// false if the import is cancelled while waiting, in which case the call should not be made
+func waitRateLimit(service string) bool {
+	if apiLimiter == nil {
+		return true
+	}
+	now := time.Now()
+	var wait time.Duration
+	if apiLimiter.global != nil {
+		wait = apiLimiter.global.reserve(now)
+	}
+	if bucket, ok := apiLimiter.services[strings.ToLower(service)]; ok {
+		if serviceWait := bucket.reserve(now); serviceWait > wait {
+			wait = serviceWait
+		}
+	}
+	if wait <= 0 {
+		return true
+	}
+	apiLimiter.mutex.Lock()
+	apiLimiter.waits++
+	apiLimiter.waited += wait
+	apiLimiter.mutex.Unlock()
+	return sleepContext(wait)
+}
+
+// logRateLimit -- outputs the number of calls delayed by the rate limiter, and the total time they waited
+func logRateLimit() {
+	if apiLimiter == nil {
+		return
+	}
+	apiLimiter.mutex.Lock()
+	waits, waited := apiLimiter.waits, apiLimiter.waited
+	apiLimiter.mutex.Unlock()
+	//Logged after unlocking, as logging to the instance is itself rate limited
+	logger(3, "XMLMC Calls Rate Limited: "+fmt.Sprintf("%d", waits)+" (waited "+waited.Round(time.Millisecond).String()+")", true, true)
+}
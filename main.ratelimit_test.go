@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	start := time.Now()
+	b := newTokenBucket(10, 2)
+	b.last = start
+
+	//The burst is available straight away, then calls are spaced at the rate
+	want := []time.Duration{0, 0, 100 * time.Millisecond, 200 * time.Millisecond}
+	for i, w := range want {
+		if got := b.reserve(start); got != w {
+			t.Errorf("call %d wait = %v, want %v", i+1, got, w)
+		}
+	}
+	//Half a second later, the reserved calls have been made and three more tokens have accrued
+	if got := b.reserve(start.Add(500 * time.Millisecond)); got != 0 {
+		t.Errorf("wait after refill = %v, want 0", got)
+	}
+	//Tokens never accrue beyond the burst
+	later := start.Add(time.Minute)
+	b.reserve(later)
+	b.reserve(later)
+	if got := b.reserve(later); got != 100*time.Millisecond {
+		t.Errorf("wait after idle = %v, want 100ms", got)
+	}
+
+	if b := newTokenBucket(2.5, 0); b.burst != 3 {
+		t.Errorf("default burst = %v, want 3", b.burst)
+	}
+}
+
+func TestImportRateLimit(t *testing.T) {
+	m := newImportTest(t)
+	os.WriteFile("desktops.csv", []byte(testCSV), 0644)
+	loadTestConfig(t, m,
+		map[string]interface{}{"Source": "csv", "CSV": map[string]interface{}{"CommaCharacter": ","}},
+		desktopAssetType(map[string]interface{}{"CSVFile": "desktops.csv"}))
+	importConf.RateLimit = rateLimitConfStruct{CallsPerSecond: 500, Burst: 1, Services: map[string]float64{"Data": 200}}
+	t.Cleanup(func() { apiLimiter = nil })
+
+	runImport()
+
+	if counterTotal(counterCreated) != 2 {
+		t.Errorf("expected 2 assets created, got %d", counterTotal(counterCreated))
+	}
+	if apiLimiter == nil || apiLimiter.services["data"] == nil {
+		t.Fatal("rate limiter not set up from the configuration")
+	}
+	if apiLimiter.waits == 0 {
+		t.Error("expected calls to wait for the rate limiter")
+	}
+
+	importConf.RateLimit = rateLimitConfStruct{Services: map[string]float64{"data": 0}}
+	if err := checkRateLimitConfig(); err == nil {
+		t.Error("expected an error for a service limit of 0")
+	}
+}
+
+func TestWaitRateLimitCancelled(t *testing.T) {
+	importCtx, cancelImport = context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		apiLimiter = nil
+		importCtx, cancelImport = context.WithCancel(context.Background())
+	})
+	//One call every 10 seconds, with the only token taken by the first call
+	apiLimiter = &rateLimiterStruct{global: newTokenBucket(0.1, 1), services: map[string]*tokenBucketStruct{}}
+	if !waitRateLimit("data") {
+		t.Fatal("expected the first call to be allowed")
+	}
+
+	time.AfterFunc(50*time.Millisecond, cancelImport)
+	start := time.Now()
+	if waitRateLimit("data") {
+		t.Error("expected the call waiting for the rate limit to be skipped once cancelled")
+	}
+	if waited := time.Since(start); waited > 5*time.Second {
+		t.Errorf("cancelled wait took %v", waited)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Supplier and contract association results recorded in the report
+const (
+	associationSuccess = "associated"
+	associationExists  = "exists"
+	associationFailed  = "failed"
+)
+
+// reportAssetStruct -- The outcome of processing a single source asset
+type reportAssetStruct struct {
+	AssetType        string `json:"AssetType"`
+	SourceID         string `json:"SourceID"`
+	HornbillAssetID  string `json:"HornbillAssetID"`
+	Action           string `json:"Action"`
+	Error            string `json:"Error,omitempty"`
+	SoftwareAdded    int    `json:"SoftwareAdded"`
+	SoftwareRemoved  int    `json:"SoftwareRemoved"`
+	Supplier         string `json:"Supplier,omitempty"`
+	SupplierContract string `json:"SupplierContract,omitempty"`
+}
+
+// reportStruct -- The run report written when the -report flag is supplied
+type reportStruct struct {
+	Version   string              `json:"Version"`
+	Config    string              `json:"Config"`
+	DryRun    bool                `json:"DryRun"`
+	StartTime string              `json:"StartTime"`
+	EndTime   string              `json:"EndTime"`
+	Assets    []reportAssetStruct `json:"Assets"`
+	//Sites and groups created by AutoCreate
+	AutoCreated []autoCreatedStruct `json:"AutoCreated,omitempty"`
+	//User, site and group values not found in Hornbill
+	Unresolved []unresolvedStruct `json:"Unresolved"`
+}
+
+var (
+	reportAssets []reportAssetStruct
+	// reportClosed is set once abandoned assets have been reported, so the late results of their workers are dropped
+	reportClosed bool
+	mutexReport  = &sync.Mutex{}
+)
+
+// addReportAsset -- records the outcome of an asset, when a report has been requested
+func addReportAsset(asset reportAssetStruct) {
+	if configReportFile == "" {
+		return
+	}
+	mutexReport.Lock()
+	if !reportClosed {
+		reportAssets = append(reportAssets, asset)
+	}
+	mutexReport.Unlock()
+}
+
+// closeReport -- stops further assets being added to the report
+func closeReport() {
+	mutexReport.Lock()
+	reportClosed = true
+	mutexReport.Unlock()
+}
+
+// bufferErrors -- returns the error messages written to an asset log buffer, for the report
+func bufferErrors(buffer string) string {
+	var errs []string
+	for _, line := range strings.Split(buffer, logBufferSeparator) {
+		if t, message := parseLogLine(line); t == 4 {
+			errs = append(errs, message)
+		}
+	}
+	return redactLog(strings.Join(errs, "; "))
+}
+
+// writeReport -- writes the run report, as CSV when the file has a .csv extension, otherwise as JSON
+func writeReport() error {
+	if configReportFile == "" {
+		return nil
+	}
+	mutexReport.Lock()
+	defer mutexReport.Unlock()
+
+	file, err := os.Create(configReportFile)
+	if err != nil {
+		return errors.New("Unable to create report " + configReportFile + ": " + err.Error())
+	}
+	defer file.Close()
+
+	if strings.EqualFold(filepath.Ext(configReportFile), ".csv") {
+		w := csv.NewWriter(file)
+		w.Write([]string{"AssetType", "SourceID", "HornbillAssetID", "Action", "Error", "SoftwareAdded", "SoftwareRemoved", "Supplier", "SupplierContract"})
+		for _, a := range reportAssets {
+			w.Write([]string{a.AssetType, a.SourceID, a.HornbillAssetID, a.Action, a.Error, strconv.Itoa(a.SoftwareAdded), strconv.Itoa(a.SoftwareRemoved), a.Supplier, a.SupplierContract})
+		}
+		w.Flush()
+		err = w.Error()
+		if err == nil {
+			err = writeReportSections()
+		}
+	} else {
+		report := reportStruct{
+			Version:   version,
+			Config:    configFileName,
+			DryRun:    configDryRun,
+			StartTime: startTime.Format(time.RFC3339),
+			EndTime:   time.Now().Format(time.RFC3339),
+			Assets:    reportAssets,
+		}
+		mutexAutoCreate.Lock()
+		report.AutoCreated = autoCreated
+		mutexAutoCreate.Unlock()
+		report.Unresolved = getUnresolved()
+		if report.Assets == nil {
+			report.Assets = []reportAssetStruct{}
+		}
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "    ")
+		err = encoder.Encode(report)
+	}
+	if err != nil {
+		return errors.New("Unable to write report " + configReportFile + ": " + err.Error())
+	}
+	logger(3, "Report written to: "+configReportFile, true, true)
+	return nil
+}
+
+// reportSectionStruct -- A section of a CSV report, written to its own file
+type reportSectionStruct struct {
+	name string
+	rows [][]string
+}
+
+// reportSectionFile -- returns the name of the file holding a section of a CSV report, alongside the report
+func reportSectionFile(section string) string {
+	ext := filepath.Ext(configReportFile)
+	return strings.TrimSuffix(configReportFile, ext) + "_" + section + ext
+}
+
+// writeReportSections -- writes the unresolved references and auto created records of a CSV report to their own
+// files, as they don't share the columns of the asset outcomes. Each is only written when it holds any records
+func writeReportSections() error {
+	var sections []reportSectionStruct
+	if unresolved := getUnresolved(); len(unresolved) > 0 {
+		rows := [][]string{{"Field", "Value", "Count", "AssetTypes", "SampleAssets"}}
+		for _, ref := range unresolved {
+			rows = append(rows, []string{ref.Field, ref.Value, strconv.Itoa(ref.Count), strings.Join(ref.AssetTypes, ";"), strings.Join(ref.SampleAssets, ";")})
+		}
+		sections = append(sections, reportSectionStruct{"unresolved", rows})
+	}
+	mutexAutoCreate.Lock()
+	if len(autoCreated) > 0 {
+		rows := [][]string{{"Type", "Name", "ID", "Parent", "Error"}}
+		for _, created := range autoCreated {
+			rows = append(rows, []string{created.Type, created.Name, created.ID, created.Parent, created.Error})
+		}
+		sections = append(sections, reportSectionStruct{"autocreated", rows})
+	}
+	mutexAutoCreate.Unlock()
+
+	for _, section := range sections {
+		fileName := reportSectionFile(section.name)
+		file, err := os.Create(fileName)
+		if err != nil {
+			return err
+		}
+		w := csv.NewWriter(file)
+		w.WriteAll(section.rows)
+		err = w.Error()
+		file.Close()
+		if err != nil {
+			return err
+		}
+		logger(3, "Report written to: "+fileName, true, true)
+	}
+	return nil
+}
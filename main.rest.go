@@ -0,0 +1,499 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Masterminds/sprig"
+)
+
+// restSource -- Source implementation for any REST API that returns asset records as JSON
+type restSource struct {
+	conf        sourceConfStruct
+	key         keyDataStruct
+	client      *http.Client
+	accessToken string
+	//When the access token is refreshed, shortly before it expires. Zero for a token with no expires_in
+	tokenRefresh time.Time
+}
+
+// The most time before an OAuth2 access token expires that it is refreshed, to allow for clock skew and the
+// time taken by the request. Shorter lived tokens are refreshed after nine tenths of their lifetime
+const restTokenRefreshMargin = time.Minute
+
+// restTemplateStruct -- data made available to the REST URL and Body templates
+type restTemplateStruct struct {
+	Endpoint  string
+	Query     string
+	AssetType string
+}
+
+// restOAuthTokenStruct -- response from an OAuth2 client credentials token request
+type restOAuthTokenStruct struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+var regexLinkNext = regexp.MustCompile(`<([^>]*)>\s*;[^,]*rel="?next"?`)
+
+func init() {
+	registerSource(func(conf sourceConfStruct, key keyDataStruct) Source {
+		return &restSource{conf: conf, key: key}
+	}, "rest")
+}
+
+// ValidateConfig -- Checks the REST URL, authentication and pagination settings, and that the Keysafe key
+// holds the credentials the authentication type needs
+func (s *restSource) ValidateConfig() error {
+	conf := &s.conf.REST
+	if conf.URL == "" {
+		return errors.New("no URL defined in SourceConfig.REST")
+	}
+	if _, err := template.New("URL").Funcs(TemplateFilters).Funcs(sprig.FuncMap()).Parse(conf.URL); err != nil {
+		return errors.New("unable to parse SourceConfig.REST.URL template: " + err.Error())
+	}
+	if conf.Body != "" {
+		if _, err := template.New("Body").Funcs(TemplateFilters).Funcs(sprig.FuncMap()).Parse(conf.Body); err != nil {
+			return errors.New("unable to parse SourceConfig.REST.Body template: " + err.Error())
+		}
+	}
+	if conf.Method == "" {
+		conf.Method = http.MethodGet
+	}
+	conf.Method = strings.ToUpper(conf.Method)
+
+	conf.Auth.Type = strings.ToLower(conf.Auth.Type)
+	switch conf.Auth.Type {
+	case "", "none":
+	case "basic":
+		if s.key.Username == "" {
+			return errors.New("basic authentication requires a username in the Keysafe key")
+		}
+	case "bearer":
+		if s.key.APIKey == "" {
+			return errors.New("bearer authentication requires an apikey in the Keysafe key")
+		}
+	case "apikey":
+		if s.key.APIKey == "" {
+			return errors.New("API key authentication requires an apikey in the Keysafe key")
+		}
+		if conf.Auth.HeaderName == "" {
+			conf.Auth.HeaderName = "X-API-Key"
+		}
+	case "oauth2":
+		if s.key.ClientID == "" || s.key.ClientSecret == "" {
+			return errors.New("OAuth2 authentication requires a client_id and client_secret in the Keysafe key")
+		}
+		if conf.Auth.TokenURL == "" {
+			return errors.New("OAuth2 authentication requires SourceConfig.REST.Auth.TokenURL")
+		}
+	default:
+		return errors.New("unsupported SourceConfig.REST.Auth.Type [" + conf.Auth.Type + "], supported types are: none, basic, bearer, apikey, oauth2")
+	}
+
+	conf.Pagination.Type = strings.ToLower(conf.Pagination.Type)
+	switch conf.Pagination.Type {
+	case "", "none", "nextlink":
+	case "page":
+		if conf.Pagination.PageParam == "" {
+			conf.Pagination.PageParam = "page"
+		}
+	case "offset":
+		if conf.Pagination.OffsetParam == "" {
+			conf.Pagination.OffsetParam = "offset"
+		}
+	case "cursor":
+		if conf.Pagination.CursorPath == "" || conf.Pagination.CursorParam == "" {
+			return errors.New("cursor pagination requires SourceConfig.REST.Pagination.CursorPath and CursorParam")
+		}
+	default:
+		return errors.New("unsupported SourceConfig.REST.Pagination.Type [" + conf.Pagination.Type + "], supported types are: none, nextlink, page, offset, cursor")
+	}
+	if conf.Pagination.PageSize > 0 && conf.Pagination.PageSizeParam == "" {
+		conf.Pagination.PageSizeParam = "limit"
+	}
+
+	if conf.Timeout == 0 {
+		conf.Timeout = 120
+	}
+	s.client = &http.Client{Transport: &http.Transport{Proxy: http.ProxyFromEnvironment}, Timeout: time.Duration(conf.Timeout) * time.Second}
+	return nil
+}
+
+// GetAssets -- Pages through the REST API, returning the records found at RecordsPath in each response
+func (s *restSource) GetAssets(assetType assetTypesStruct) (map[string]map[string]interface{}, error) {
+	return collectAssets(s, assetType)
+}
+
+// StreamAssets -- Pages through the REST API, emitting the records found at RecordsPath as each page is returned
+func (s *restSource) StreamAssets(assetType assetTypesStruct, emit assetEmitter) error {
+	assetCount := 0
+	logger(3, " ", false, false)
+	logger(3, "[REST] Running REST query for "+assetType.AssetType+" assets. Please wait...", true, true)
+
+	templateData := restTemplateStruct{Endpoint: s.key.Endpoint, Query: assetType.Query, AssetType: assetType.AssetType}
+	firstURL, err := s.executeTemplate(s.conf.REST.URL, templateData)
+	if err != nil {
+		return errors.New("unable to build REST URL: " + err.Error())
+	}
+	body, err := s.executeTemplate(s.conf.REST.Body, templateData)
+	if err != nil {
+		return errors.New("unable to build REST request body: " + err.Error())
+	}
+
+	pagination := s.conf.REST.Pagination
+	var (
+		pageURL  = firstURL
+		pageNum  = pagination.StartPage
+		offset   = 0
+		cursor   = ""
+		pageSeen = 0
+	)
+	if pagination.Type == "page" && pagination.StartPage == 0 {
+		pageNum = 1
+	}
+	for {
+		//Next links already hold the paging parameters, so they are only added to the first request
+		requestURL := pageURL
+		if pagination.Type != "nextlink" || pageSeen == 0 {
+			requestURL, err = s.pageURL(pageURL, pageNum, offset, cursor)
+			if err != nil {
+				return err
+			}
+		}
+		response, header, err := s.getPage(requestURL, body)
+		if err != nil {
+			return err
+		}
+		records, err := restRecords(response, s.conf.REST.RecordsPath)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			assetIdentifier := restAssetIdentifier(record, assetType)
+			if assetIdentifier == "" {
+				logger(4, "[REST] Unable to determine the asset identifier for a record, record skipped", false, true)
+				continue
+			}
+			assetCount++
+			if !emit(assetIdentifier, record) {
+				return nil
+			}
+		}
+		pageSeen++
+		if pagination.MaxPages > 0 && pageSeen >= pagination.MaxPages {
+			logger(3, "[REST] Maximum number of pages ("+strconv.Itoa(pagination.MaxPages)+") retrieved", false, true)
+			break
+		}
+
+		lastPage := len(records) == 0 || (pagination.PageSize > 0 && len(records) < pagination.PageSize)
+		switch pagination.Type {
+		case "page":
+			pageNum++
+		case "offset":
+			offset += len(records)
+		case "cursor":
+			cursor = iToS(restSelect(response, pagination.CursorPath))
+			lastPage = len(records) == 0 || cursor == ""
+		case "nextlink":
+			next := ""
+			if pagination.NextLinkPath != "" {
+				next = iToS(restSelect(response, pagination.NextLinkPath))
+			} else if match := regexLinkNext.FindStringSubmatch(header.Get("Link")); match != nil {
+				next = match[1]
+			}
+			if next != "" {
+				//Next links can be relative to the current page
+				if base, err := url.Parse(requestURL); err == nil {
+					if ref, err := url.Parse(next); err == nil {
+						next = base.ResolveReference(ref).String()
+					}
+				}
+			}
+			pageURL = next
+			lastPage = next == ""
+		default:
+			lastPage = true
+		}
+		if lastPage {
+			break
+		}
+	}
+	if assetCount == 0 {
+		logger(3, "No "+assetType.AssetType+" asset records returned from REST API - check your configuration!", true, true)
+	} else {
+		logger(2, "Total "+assetType.AssetType+" asset records returned from REST API: "+strconv.Itoa(assetCount), true, true)
+	}
+	return nil
+}
+
+// GetSoftwareInventory -- Returns the software records held in an array against the REST asset record
+func (s *restSource) GetSoftwareInventory(assetRecord map[string]interface{}, assetType assetTypesStruct, buffer *bytes.Buffer) (map[string]map[string]interface{}, string, error) {
+	parentObject := assetType.SoftwareInventory.ParentObject
+	if parentObject == "" {
+		return make(map[string]map[string]interface{}), "", nil
+	}
+	//Decoded JSON arrays hold interface{} values, so convert to the record slice getSoftwareRecordsFromParentObject expects
+	record := map[string]interface{}{}
+	if arr, ok := restSelect(assetRecord, parentObject).([]interface{}); ok {
+		var softwareRecords []map[string]interface{}
+		for _, v := range arr {
+			if sw, ok := v.(map[string]interface{}); ok {
+				softwareRecords = append(softwareRecords, sw)
+			}
+		}
+		record[parentObject] = softwareRecords
+	}
+	return getSoftwareRecordsFromParentObject(record, assetType, parentObject, buffer)
+}
+
+// executeTemplate -- renders a REST URL or Body template against the asset type
+func (s *restSource) executeTemplate(text string, data restTemplateStruct) (string, error) {
+	if text == "" {
+		return "", nil
+	}
+	t, err := template.New("REST").Funcs(TemplateFilters).Funcs(sprig.FuncMap()).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	buf := bytes.NewBufferString("")
+	err = t.Execute(buf, data)
+	return buf.String(), err
+}
+
+// pageURL -- adds the page size and the page number, offset or cursor parameters to the request URL
+func (s *restSource) pageURL(pageURL string, pageNum int, offset int, cursor string) (string, error) {
+	pagination := s.conf.REST.Pagination
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return "", errors.New("invalid REST URL [" + pageURL + "]: " + err.Error())
+	}
+	q := u.Query()
+	if pagination.PageSize > 0 {
+		q.Set(pagination.PageSizeParam, strconv.Itoa(pagination.PageSize))
+	}
+	switch pagination.Type {
+	case "page":
+		q.Set(pagination.PageParam, strconv.Itoa(pageNum))
+	case "offset":
+		q.Set(pagination.OffsetParam, strconv.Itoa(offset))
+	case "cursor":
+		if cursor != "" {
+			q.Set(pagination.CursorParam, cursor)
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// getPage -- requests a single page from the REST API and decodes the JSON response
+func (s *restSource) getPage(pageURL string, body string) (response interface{}, header http.Header, err error) {
+	logger(2, "Getting page of assets from REST API, URL: "+pageURL, false, true)
+	resp, err := s.requestPage(pageURL, body)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized && s.conf.REST.Auth.Type == "oauth2" {
+		//The access token may have been revoked, or expired early, so request a new one and retry the page once
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		logger(5, "[REST] OAuth2 access token rejected, requesting a new token", false, true)
+		s.accessToken = ""
+		resp, err = s.requestPage(pageURL, body)
+	}
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	header = resp.Header
+
+	//-- Check for HTTP Response
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err = fmt.Errorf("Invalid HTTP Response: %d", resp.StatusCode)
+		//Drain the body so we can reuse the connection
+		io.Copy(io.Discard, resp.Body)
+		return
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		return
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return response, header, errors.New("Failed to read the body of the response: " + err.Error())
+	}
+	//Decode numbers as json.Number so large numeric identifiers are not rendered in exponent form
+	decoder := json.NewDecoder(bytes.NewReader(respBody))
+	decoder.UseNumber()
+	if err = decoder.Decode(&response); err != nil {
+		return response, header, errors.New("Failed to unmarshal JSON response from REST API: " + err.Error())
+	}
+	return
+}
+
+// requestPage -- sends the request for a single page of the REST API, with the configured headers and authentication
+func (s *restSource) requestPage(pageURL string, body string) (*http.Response, error) {
+	var reqBody io.Reader
+	if body != "" {
+		reqBody = strings.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(importCtx, s.conf.REST.Method, pageURL, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", appName+"/"+version)
+	req.Header.Set("Accept", "application/json")
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range s.conf.REST.Headers {
+		req.Header.Set(k, v)
+	}
+	if err = s.setAuth(req); err != nil {
+		return nil, err
+	}
+	return doRequestRetry(s.client, req, nil)
+}
+
+// setAuth -- adds the configured authentication to a request, requesting an OAuth2 token first if needed
+func (s *restSource) setAuth(req *http.Request) error {
+	switch s.conf.REST.Auth.Type {
+	case "basic":
+		req.SetBasicAuth(s.key.Username, s.key.Password)
+	case "bearer":
+		req.Header.Set("Authorization", "Bearer "+s.key.APIKey)
+	case "apikey":
+		req.Header.Set(s.conf.REST.Auth.HeaderName, s.key.APIKey)
+	case "oauth2":
+		if s.accessToken == "" || (!s.tokenRefresh.IsZero() && !time.Now().Before(s.tokenRefresh)) {
+			if err := s.getAccessToken(); err != nil {
+				return err
+			}
+		}
+		req.Header.Set("Authorization", "Bearer "+s.accessToken)
+	}
+	return nil
+}
+
+// getAccessToken -- requests an access token using the OAuth2 client credentials grant, and records when to refresh
+// it from the expires_in of the response
+func (s *restSource) getAccessToken() error {
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", s.key.ClientID)
+	form.Set("client_secret", s.key.ClientSecret)
+	if s.conf.REST.Auth.Scope != "" {
+		form.Set("scope", s.conf.REST.Auth.Scope)
+	}
+	req, err := http.NewRequestWithContext(importCtx, http.MethodPost, s.conf.REST.Auth.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", appName+"/"+version)
+	//expires_in counts from when the token was issued, so measure from before the request
+	requested := time.Now()
+	resp, err := doRequestRetry(s.client, req, nil)
+	if err != nil {
+		return errors.New("OAuth2 token request failed: " + err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		io.Copy(io.Discard, resp.Body)
+		return fmt.Errorf("OAuth2 token request failed, Invalid HTTP Response: %d", resp.StatusCode)
+	}
+	var token restOAuthTokenStruct
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return errors.New("Failed to unmarshal OAuth2 token response: " + err.Error())
+	}
+	if token.AccessToken == "" {
+		return errors.New("OAuth2 token response contained no access_token")
+	}
+	s.accessToken = token.AccessToken
+	s.tokenRefresh = time.Time{}
+	if token.ExpiresIn > 0 {
+		lifetime := time.Duration(token.ExpiresIn) * time.Second
+		margin := lifetime / 10
+		if margin > restTokenRefreshMargin {
+			margin = restTokenRefreshMargin
+		}
+		s.tokenRefresh = requested.Add(lifetime - margin)
+	}
+	return nil
+}
+
+// restSelect -- returns the value at a dot separated path (e.g. $.data.items or results.0.devices) within
+// a decoded JSON document. Numeric path elements index into arrays, and an empty path or $ returns the document
+func restSelect(doc interface{}, path string) interface{} {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" {
+		return doc
+	}
+	current := doc
+	for _, element := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			current = node[element]
+		case []interface{}:
+			i, err := strconv.Atoi(element)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil
+			}
+			current = node[i]
+		default:
+			return nil
+		}
+	}
+	return current
+}
+
+// restRecords -- returns the asset records found at the records path of a REST response
+func restRecords(response interface{}, path string) ([]map[string]interface{}, error) {
+	var records []map[string]interface{}
+	if response == nil {
+		return records, nil
+	}
+	switch node := restSelect(response, path).(type) {
+	case nil:
+		return records, nil
+	case []interface{}:
+		for _, v := range node {
+			if record, ok := v.(map[string]interface{}); ok {
+				records = append(records, record)
+			}
+		}
+	case map[string]interface{}:
+		records = append(records, node)
+	default:
+		return records, errors.New("the REST RecordsPath [" + path + "] does not point to an array of records")
+	}
+	return records, nil
+}
+
+// AssetIdentifier -- Returns the asset identifier of a REST record, where the SourceColumn can be a dotted path
+func (s *restSource) AssetIdentifier(assetRecord map[string]interface{}, assetType assetTypesStruct) string {
+	return restAssetIdentifier(assetRecord, assetType)
+}
+
+// restAssetIdentifier -- returns the asset identifier for a REST record, using the SourceColumn template if there is one
+func restAssetIdentifier(record map[string]interface{}, assetType assetTypesStruct) string {
+	assetIDIdent := fmt.Sprintf("%v", assetType.AssetIdentifier.SourceColumn)
+	if !regexTemplate.MatchString(assetIDIdent) {
+		return iToS(restSelect(record, assetIDIdent))
+	}
+	t := template.New(assetIDIdent).Funcs(TemplateFilters).Funcs(sprig.FuncMap())
+	tmpl, err := t.Parse(assetIDIdent)
+	if err != nil {
+		return ""
+	}
+	buf := bytes.NewBufferString("")
+	tmpl.Execute(buf, record)
+	return buf.String()
+}
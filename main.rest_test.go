@@ -0,0 +1,294 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// restTestDevices -- the devices served by newRESTTestServer
+var restTestDevices = []map[string]interface{}{
+	{"id": 1001, "name": "Laptop001", "os": map[string]interface{}{"name": "Windows 11"}},
+	{"id": 1002, "name": "Laptop002", "os": map[string]interface{}{"name": "Windows 10"}},
+	{"id": 1003, "name": "Laptop003", "os": map[string]interface{}{"name": "macOS"}},
+	{"id": 12345678, "name": "Laptop004", "os": map[string]interface{}{"name": "Ubuntu"}},
+	{"id": 1005, "name": "Laptop005", "os": map[string]interface{}{"name": "Windows 11"}},
+}
+
+// newRESTTestServer -- serves restTestDevices two at a time, using the paging style given in the style query parameter.
+// Requests must carry the bearer token issued by the /token endpoint
+func newRESTTestServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("grant_type") != "client_credentials" || r.Form.Get("client_id") != "id" || r.Form.Get("client_secret") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "token123", "token_type": "Bearer", "expires_in": 3600})
+	})
+	mux.HandleFunc("/devices", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token123" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		q := r.URL.Query()
+		const size = 2
+		start := 0
+		switch q.Get("style") {
+		case "page":
+			page, _ := strconv.Atoi(q.Get("page"))
+			start = (page - 1) * size
+		case "offset":
+			start, _ = strconv.Atoi(q.Get("offset"))
+		case "cursor", "nextlink":
+			start, _ = strconv.Atoi(q.Get("from"))
+		}
+		end := start + size
+		if end > len(restTestDevices) {
+			end = len(restTestDevices)
+		}
+		if start > end {
+			start = end
+		}
+		response := map[string]interface{}{"data": map[string]interface{}{"items": restTestDevices[start:end]}}
+		if end < len(restTestDevices) {
+			switch q.Get("style") {
+			case "cursor":
+				response["next_cursor"] = strconv.Itoa(end)
+			case "nextlink":
+				response["next"] = "/devices?style=nextlink&from=" + strconv.Itoa(end)
+			}
+		}
+		json.NewEncoder(w).Encode(response)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRESTSourcePagination(t *testing.T) {
+	m := newImportTest(t)
+	//Only loaded to initialise the logging XMLMC session
+	loadTestConfig(t, m, map[string]interface{}{"Source": "csv"}, desktopAssetType(nil))
+	server := newRESTTestServer(t)
+
+	tests := []struct {
+		style      string
+		pagination func(conf *restConfStruct)
+	}{
+		{"page", func(conf *restConfStruct) { conf.Pagination.PageSize = 2 }},
+		{"offset", func(conf *restConfStruct) { conf.Pagination.PageSize = 2 }},
+		{"cursor", func(conf *restConfStruct) {
+			conf.Pagination.CursorPath = "$.next_cursor"
+			conf.Pagination.CursorParam = "from"
+		}},
+		{"nextlink", func(conf *restConfStruct) { conf.Pagination.NextLinkPath = "next" }},
+	}
+	for _, test := range tests {
+		t.Run(test.style, func(t *testing.T) {
+			conf := sourceConfStruct{Source: "rest"}
+			conf.REST.URL = "{{.Endpoint}}/devices?style=" + test.style
+			conf.REST.RecordsPath = "$.data.items"
+			conf.REST.Auth.Type = "oauth2"
+			conf.REST.Auth.TokenURL = server.URL + "/token"
+			conf.REST.Pagination.Type = test.style
+			test.pagination(&conf.REST)
+
+			src, err := newSource(conf, keyDataStruct{Endpoint: server.URL, ClientID: "id", ClientSecret: "secret"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := src.ValidateConfig(); err != nil {
+				t.Fatal(err)
+			}
+			assetType := assetTypesStruct{AssetType: "Laptop"}
+			assetType.AssetIdentifier.SourceColumn = "{{.name}}-{{.id}}"
+			records, err := src.GetAssets(assetType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != len(restTestDevices) {
+				t.Fatalf("got %d records, want %d", len(records), len(restTestDevices))
+			}
+			if record, ok := records["Laptop004-12345678"]; !ok {
+				t.Errorf("record Laptop004-12345678 not returned, got %v", records)
+			} else if osName := iToS(restSelect(record, "os.name")); osName != "Ubuntu" {
+				t.Errorf("os.name = %q, want Ubuntu", osName)
+			}
+		})
+	}
+}
+
+func TestRESTSourceTokenRefresh(t *testing.T) {
+	m := newImportTest(t)
+	//Only loaded to initialise the logging XMLMC session
+	loadTestConfig(t, m, map[string]interface{}{"Source": "csv"}, desktopAssetType(nil))
+
+	//Each token request issues a new token, and only the latest is accepted. revokeOn revokes the token in use
+	//when that page is first requested, and reject rejects every page
+	var (
+		mutex    sync.Mutex
+		issued   int
+		revoked  bool
+		revokeOn string
+		reject   bool
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		issued++
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "token" + strconv.Itoa(issued), "expires_in": 3600})
+	})
+	mux.HandleFunc("/devices", func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		page := r.URL.Query().Get("page")
+		if page == revokeOn && !revoked {
+			revoked = true
+			issued++
+		}
+		if reject || r.Header.Get("Authorization") != "Bearer token"+strconv.Itoa(issued) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		start, _ := strconv.Atoi(page)
+		start = (start - 1) * 2
+		end := start + 2
+		if end > len(restTestDevices) {
+			end = len(restTestDevices)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"items": restTestDevices[start:end]}})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	conf := sourceConfStruct{Source: "rest"}
+	conf.REST.URL = "{{.Endpoint}}/devices"
+	conf.REST.RecordsPath = "$.data.items"
+	conf.REST.Auth.Type = "oauth2"
+	conf.REST.Auth.TokenURL = server.URL + "/token"
+	conf.REST.Pagination.Type = "page"
+	conf.REST.Pagination.PageSize = 2
+	assetType := assetTypesStruct{AssetType: "Laptop"}
+	assetType.AssetIdentifier.SourceColumn = "{{.id}}"
+	newTestSource := func() *restSource {
+		src, err := newSource(conf, keyDataStruct{Endpoint: server.URL, ClientID: "id", ClientSecret: "secret"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := src.ValidateConfig(); err != nil {
+			t.Fatal(err)
+		}
+		return src.(*restSource)
+	}
+
+	//A token revoked part way through is replaced, and the rejected page retried
+	revokeOn = "2"
+	src := newTestSource()
+	start := time.Now()
+	records, err := src.GetAssets(assetType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(restTestDevices) || src.accessToken != "token3" {
+		t.Fatalf("got %d records with %s, want %d with token3", len(records), src.accessToken, len(restTestDevices))
+	}
+
+	//The token is refreshed a minute before it expires
+	if want := start.Add(3600*time.Second - restTokenRefreshMargin); src.tokenRefresh.Before(want) || src.tokenRefresh.After(time.Now().Add(3600*time.Second-restTokenRefreshMargin)) {
+		t.Errorf("token refreshed at %v, want about %v", src.tokenRefresh, want)
+	}
+	src.tokenRefresh = time.Now().Add(-time.Second)
+	req := httptest.NewRequest(http.MethodGet, server.URL+"/devices", nil)
+	if err := src.setAuth(req); err != nil {
+		t.Fatal(err)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer token4" {
+		t.Errorf("expired token not refreshed, Authorization = %q", got)
+	}
+
+	//A page rejected with a new token is only retried once
+	reject = true
+	src = newTestSource()
+	mutex.Lock()
+	before := issued
+	mutex.Unlock()
+	if _, err := src.GetAssets(assetType); err == nil {
+		t.Fatal("expected the rejected pages to fail the source")
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if issued-before != 2 {
+		t.Errorf("expected 2 token requests for the rejected page, got %d", issued-before)
+	}
+}
+
+func TestRESTSourceValidateConfig(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(conf *restConfStruct, key *keyDataStruct)
+		valid bool
+	}{
+		{"basic", func(conf *restConfStruct, key *keyDataStruct) { conf.Auth.Type = "Basic"; key.Username = "user" }, true},
+		{"basic without username", func(conf *restConfStruct, key *keyDataStruct) { conf.Auth.Type = "basic" }, false},
+		{"apikey", func(conf *restConfStruct, key *keyDataStruct) { conf.Auth.Type = "apikey"; key.APIKey = "key" }, true},
+		{"oauth2 without token url", func(conf *restConfStruct, key *keyDataStruct) {
+			conf.Auth.Type = "oauth2"
+			key.ClientID = "id"
+			key.ClientSecret = "secret"
+		}, false},
+		{"unknown auth", func(conf *restConfStruct, key *keyDataStruct) { conf.Auth.Type = "digest" }, false},
+		{"cursor without path", func(conf *restConfStruct, key *keyDataStruct) { conf.Pagination.Type = "cursor" }, false},
+		{"unknown pagination", func(conf *restConfStruct, key *keyDataStruct) { conf.Pagination.Type = "scroll" }, false},
+		{"bad url template", func(conf *restConfStruct, key *keyDataStruct) { conf.URL = "{{.Endpoint" }, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conf := sourceConfStruct{Source: "rest"}
+			conf.REST.URL = "{{.Endpoint}}/devices"
+			key := keyDataStruct{Endpoint: "https://example.com"}
+			test.setup(&conf.REST, &key)
+			src, _ := newSource(conf, key)
+			if err := src.ValidateConfig(); (err == nil) != test.valid {
+				t.Errorf("ValidateConfig() error = %v, want valid %v", err, test.valid)
+			}
+		})
+	}
+}
+
+func TestImportRESTDottedSourceColumn(t *testing.T) {
+	//The first policy is applied as the records stream, the last policy once every record is read
+	for _, policy := range []string{"First", "Last"} {
+		t.Run(policy, func(t *testing.T) {
+			m := newImportTest(t)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(map[string]interface{}{"items": []map[string]interface{}{
+					{"Name": "Desktop001", "Owner": "jsmith", "device": map[string]interface{}{"serial": "SERIAL001"}},
+					{"Name": "Desktop002", "Owner": "bjones", "device": map[string]interface{}{"serial": "SERIAL002"}},
+					{"Name": "Desktop003", "Owner": "jsmith", "device": map[string]interface{}{"serial": "SERIAL003"}},
+				}})
+			}))
+			t.Cleanup(server.Close)
+			assetType := desktopAssetType(map[string]interface{}{"Duplicates": map[string]interface{}{"Policy": policy}})
+			assetType["AssetIdentifier"].(map[string]interface{})["SourceColumn"] = "device.serial"
+			loadTestConfig(t, m, map[string]interface{}{"Source": "rest", "REST": map[string]interface{}{"URL": server.URL + "/devices", "RecordsPath": "$.items"}}, assetType)
+
+			runImport()
+
+			if counterTotal(counterCreated) != 3 || counterTotal(counterDuplicatesFound) != 0 {
+				t.Fatalf("expected 3 assets created and no duplicates, got %+v", counterSnapshot().total)
+			}
+			for _, name := range []string{"Desktop001", "Desktop002", "Desktop003"} {
+				if m.FindAsset("h_name", name) == nil {
+					t.Errorf("asset %s was not created", name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	apiLib "github.com/hornbill/goApiLib"
+)
+
+// Jitter applied to the Retry backoff: full waits a random time up to the backoff, equal waits at least half of it
+const (
+	retryJitterFull  = "full"
+	retryJitterEqual = "equal"
+	retryJitterNone  = "none"
+)
+
+// Retry defaults, used for any setting not supplied in the Retry configuration
+const (
+	retryDefaultMaxAttempts      = 4
+	retryDefaultInitialBackoffMs = 1000
+	retryDefaultMaxBackoffMs     = 30000
+	retryMaxRetryAfter           = 5 * time.Minute
+)
+
+// Hornbill error messages returned when an API call has been rate limited
+var regexRateLimit = regexp.MustCompile(`(?i)rate ?limit|too many requests|throttl`)
+
+// xmlmcParamNode -- an element of the params of an XMLMC request, used to set them again before a retry
+type xmlmcParamNode struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr       `xml:",any,attr"`
+	Content  string           `xml:",chardata"`
+	Children []xmlmcParamNode `xml:",any"`
+}
+
+// retryPolicy -- returns the Retry configuration, with defaults for any setting not supplied
+func retryPolicy() retryConfStruct {
+	policy := importConf.Retry
+	if policy.MaxAttempts == 0 {
+		policy.MaxAttempts = retryDefaultMaxAttempts
+	}
+	if policy.InitialBackoffMs == 0 {
+		policy.InitialBackoffMs = retryDefaultInitialBackoffMs
+	}
+	if policy.MaxBackoffMs == 0 {
+		policy.MaxBackoffMs = retryDefaultMaxBackoffMs
+	}
+	policy.Jitter = strings.ToLower(policy.Jitter)
+	if policy.Jitter == "" {
+		policy.Jitter = retryJitterFull
+	}
+	return policy
+}
+
+// checkRetryConfig -- Validates the Retry configuration
+func checkRetryConfig() error {
+	policy := retryPolicy()
+	if policy.MaxAttempts < 1 {
+		return errors.New("Retry MaxAttempts must be 1 or more, where 1 disables retries")
+	}
+	if policy.InitialBackoffMs < 1 || policy.MaxBackoffMs < 1 {
+		return errors.New("Retry InitialBackoffMs and MaxBackoffMs must be 1 or more")
+	}
+	if policy.InitialBackoffMs > policy.MaxBackoffMs {
+		return errors.New("Retry InitialBackoffMs must not be greater than MaxBackoffMs")
+	}
+	switch policy.Jitter {
+	case retryJitterFull, retryJitterEqual, retryJitterNone:
+	default:
+		return errors.New("Retry Jitter [" + importConf.Retry.Jitter + "] is not supported, use one of: " + retryJitterFull + ", " + retryJitterEqual + ", " + retryJitterNone)
+	}
+	return nil
+}
+
+// retryBackoff -- returns the time to wait before a retry, doubling from the initial backoff with each retry up to
+// the maximum backoff, with jitter applied so concurrent workers do not retry in step
+func retryBackoff(policy retryConfStruct, retry int) time.Duration {
+	backoff := time.Duration(policy.InitialBackoffMs) * time.Millisecond
+	maxBackoff := time.Duration(policy.MaxBackoffMs) * time.Millisecond
+	for i := 1; i < retry && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	switch policy.Jitter {
+	case retryJitterFull:
+		backoff = time.Duration(rand.Int63n(int64(backoff) + 1))
+	case retryJitterEqual:
+		half := backoff / 2
+		backoff = half + time.Duration(rand.Int63n(int64(backoff-half)+1))
+	}
+	return backoff
+}
+
+// retryAfter -- returns the wait requested by a Retry-After header, given in seconds or as an HTTP date
+func retryAfter(header http.Header) (wait time.Duration, found bool) {
+	value := strings.TrimSpace(header.Get("Retry-After"))
+	if value == "" {
+		return
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		wait, found = time.Duration(seconds)*time.Second, true
+	} else if date, err := http.ParseTime(value); err == nil {
+		wait, found = time.Until(date), true
+	} else {
+		return
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	if wait > retryMaxRetryAfter {
+		wait = retryMaxRetryAfter
+	}
+	return
+}
+
+// retryDelay -- returns the time to wait before a retry, being the backoff or, when longer, any Retry-After
+func retryDelay(policy retryConfStruct, retry int, header http.Header) time.Duration {
+	delay := retryBackoff(policy, retry)
+	if wait, found := retryAfter(header); found && wait > delay {
+		delay = wait
+	}
+	return delay
+}
+
+// retryableStatus -- returns true for HTTP responses that indicate the request may succeed if sent again
+func retryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// logRetry -- logs a retry to the asset buffer, or to the log file when there is no buffer, and counts it
+func logRetry(buffer *bytes.Buffer, message string, isXMLMC bool) {
+	if buffer != nil {
+		buffer.WriteString(loggerGen(5, message))
+	} else {
+		//Not sent to the instance log, which is itself an XMLMC call subject to the same failures
+		logger(5, message, false, false)
+	}
+	if isXMLMC {
+		incCounter("", counterXMLMCRetries)
+	} else {
+		incCounter("", counterHTTPRetries)
+	}
+}
+
+// xmlmcCreateMethods -- XMLMC methods that create records, so are not idempotent. A transport error or gateway
+// response can follow a create that succeeded, so these are only retried when the request was not processed
+var xmlmcCreateMethods = map[string]bool{
+	"entityAddRecord":          true,
+	"groupCreate":              true,
+	"addSupplierAsset":         true,
+	"addSupplierContractAsset": true,
+}
+
+// requestNotSent -- returns true for transport errors raised before the request was sent, such as failing to
+// connect or to look up the host
+func requestNotSent(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
+}
+
+// xmlmcRetryReason -- returns why a failed XMLMC call can be retried, or an empty string when it cannot
+func xmlmcRetryReason(espXmlmc *apiLib.XmlmcInstStruct, method string, response string, err error) string {
+	if err != nil {
+		var urlErr *url.Error
+		create := xmlmcCreateMethods[method]
+		switch {
+		case errors.As(err, &urlErr):
+			if !create || requestNotSent(err) {
+				return err.Error()
+			}
+		case strings.HasPrefix(err.Error(), "Invalid HTTP Response"):
+			//A 429 or 503 is returned before the call is processed, where a gateway error may follow a processed call
+			statusCode := espXmlmc.GetStatusCode()
+			if retryableStatus(statusCode) && (!create || statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable) {
+				return err.Error()
+			}
+		case strings.HasPrefix(err.Error(), "Cant read the body"):
+			if !create {
+				return err.Error()
+			}
+		}
+		return ""
+	}
+	//Only failed calls are decoded - the status is at the start of both XML and JSON responses
+	head := response
+	if len(head) > 256 {
+		head = head[:256]
+	}
+	var state stateStructXML
+	if strings.Contains(head, `status="fail"`) {
+		var xmlRespon struct {
+			State stateStructXML `xml:"state"`
+		}
+		if xml.Unmarshal([]byte(response), &xmlRespon) != nil {
+			return ""
+		}
+		state = xmlRespon.State
+	} else if strings.Contains(head, `"@status":false`) {
+		var jsonRespon struct {
+			State stateStructJSON `json:"state"`
+		}
+		if json.Unmarshal([]byte(response), &jsonRespon) != nil {
+			return ""
+		}
+		state.Error = jsonRespon.State.Error
+	}
+	if regexRateLimit.MatchString(state.Error) {
+		return state.Error
+	}
+	return ""
+}
+
+// setXMLMCParams -- sets the params of an XMLMC request from the XML returned by GetParam
+func setXMLMCParams(espXmlmc *apiLib.XmlmcInstStruct, params string) error {
+	var root xmlmcParamNode
+	if err := xml.Unmarshal([]byte(params), &root); err != nil {
+		return err
+	}
+	var setParams func(nodes []xmlmcParamNode)
+	setParams = func(nodes []xmlmcParamNode) {
+		for _, node := range nodes {
+			name := node.XMLName.Local
+			switch {
+			case len(node.Children) > 0:
+				espXmlmc.OpenElement(name)
+				setParams(node.Children)
+				espXmlmc.CloseElement(name)
+			case len(node.Attrs) > 0:
+				var attribs []apiLib.ParamAttribStruct
+				for _, attr := range node.Attrs {
+					attribs = append(attribs, apiLib.ParamAttribStruct{Name: attr.Name.Local, Value: attr.Value})
+				}
+				espXmlmc.SetParamAttr(name, node.Content, attribs)
+			default:
+				espXmlmc.SetParam(name, node.Content)
+			}
+		}
+	}
+	setParams(root.Children)
+	return nil
+}
+
+// invokeRetry -- makes an XMLMC call, retrying transport errors, HTTP 429, 502, 503 and 504 responses, and Hornbill
+// rate limit errors, as per the Retry configuration. Calls that create records are only retried when the request
+// was not processed. Retries are logged to the buffer, or to the log file when nil
+func invokeRetry(espXmlmc *apiLib.XmlmcInstStruct, service string, method string, buffer *bytes.Buffer) (string, error) {
+	policy := retryPolicy()
+	params := espXmlmc.GetParam()
+	for attempt := 1; ; attempt++ {
+		if !waitRateLimit(service) {
+			espXmlmc.ClearParam()
+			return "", errors.New("Import cancelled while waiting for the rate limit: " + service + "::" + method + " not called")
+		}
+		start := time.Now()
+		response, header, err := espXmlmc.InvokeGetResponse(service, method)
+		metricsObserve("hornbill_call_duration_seconds", time.Since(start).Seconds(), "service", service, "method", method)
+		reason := xmlmcRetryReason(espXmlmc, method, response, err)
+		//Once the import is cancelled, calls are not retried so assets in progress complete promptly
+		if reason == "" || attempt >= policy.MaxAttempts || importCancelled() {
+			//A failed call leaves the params in place, which would be sent with the next call on this instance
+			espXmlmc.ClearParam()
+			return response, err
+		}
+		delay := retryDelay(policy, attempt, header)
+		logRetry(buffer, "Retrying "+service+"::"+method+" in "+delay.Round(time.Millisecond).String()+
+			" (attempt "+strconv.Itoa(attempt+1)+" of "+strconv.Itoa(policy.MaxAttempts)+"): "+reason, true)
+		if !sleepContext(delay) {
+			espXmlmc.ClearParam()
+			return response, err
+		}
+		espXmlmc.ClearParam()
+		if err := setXMLMCParams(espXmlmc, params); err != nil {
+			return response, errors.New("Unable to set the params to retry " + service + "::" + method + ": " + err.Error())
+		}
+	}
+}
+
+// doRequestRetry -- sends an HTTP request to an asset source, retrying transport errors and HTTP 429, 502, 503 and
+// 504 responses as per the Retry configuration, waiting at least as long as any Retry-After header asks. The response
+// of the final attempt is returned. Retries are logged to the buffer, or to the log file when nil
+func doRequestRetry(client *http.Client, req *http.Request, buffer *bytes.Buffer) (*http.Response, error) {
+	policy := retryPolicy()
+	for attempt := 1; ; attempt++ {
+		resp, err := client.Do(req)
+		var (
+			reason string
+			header http.Header
+		)
+		if err != nil {
+			reason = err.Error()
+		} else if retryableStatus(resp.StatusCode) {
+			reason = "Invalid HTTP Response: " + strconv.Itoa(resp.StatusCode)
+			header = resp.Header
+		}
+		//A request body that cannot be read again cannot be retried
+		if reason == "" || attempt >= policy.MaxAttempts || (req.Body != nil && req.GetBody == nil) || importCancelled() {
+			return resp, err
+		}
+		if resp != nil {
+			//Drain the body so we can reuse the connection
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		delay := retryDelay(policy, attempt, header)
+		logRetry(buffer, "Retrying "+req.Method+" "+req.URL.Redacted()+" in "+delay.Round(time.Millisecond).String()+
+			" (attempt "+strconv.Itoa(attempt+1)+" of "+strconv.Itoa(policy.MaxAttempts)+"): "+reason, false)
+		if !sleepContext(delay) {
+			return nil, importCtx.Err()
+		}
+		retryReq := req.Clone(req.Context())
+		if req.GetBody != nil {
+			if retryReq.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		req = retryReq
+	}
+}
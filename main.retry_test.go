@@ -0,0 +1,211 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	apiLib "github.com/hornbill/goApiLib"
+)
+
+func TestImportRetry(t *testing.T) {
+	m := newImportTest(t)
+	os.WriteFile("desktops.csv", []byte(testCSV), 0644)
+	loadTestConfig(t, m,
+		map[string]interface{}{"Source": "csv", "CSV": map[string]interface{}{"CommaCharacter": ","}},
+		desktopAssetType(map[string]interface{}{"CSVFile": "desktops.csv"}))
+	importConf.Retry = retryConfStruct{InitialBackoffMs: 1, MaxBackoffMs: 5}
+	m.HTTPErrors["data::entityAddRecord"] = []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}
+	//The first query loads the users, through the shared JSON session
+	m.RateLimits["data::queryExec"] = 1
+
+	runImport()
+
+	for _, serial := range []string{"SERIAL001", "SERIAL002"} {
+		if asset := m.FindAsset("h_serial_number", serial); asset == nil {
+			t.Errorf("%s not created", serial)
+		}
+	}
+	if asset := m.FindAsset("h_serial_number", "SERIAL001"); asset["h_owned_by"] != "urn:sys:0:John Smith:jsmith" {
+		t.Errorf("owner not resolved after the rate limited user query was retried: %v", asset)
+	}
+	if counterTotal(counterXMLMCRetries) != 3 || counterTotal(counterCreateFailed) != 0 {
+		t.Errorf("expected 3 retries and no failures, got %d retries and %d failures", counterTotal(counterXMLMCRetries), counterTotal(counterCreateFailed))
+	}
+}
+
+func TestImportRetryExhausted(t *testing.T) {
+	m := newImportTest(t)
+	os.WriteFile("desktops.csv", []byte(testCSV), 0644)
+	loadTestConfig(t, m,
+		map[string]interface{}{"Source": "csv", "CSV": map[string]interface{}{"CommaCharacter": ","}},
+		desktopAssetType(map[string]interface{}{"CSVFile": "desktops.csv"}))
+	importConf.Retry = retryConfStruct{MaxAttempts: 2, InitialBackoffMs: 1, MaxBackoffMs: 5}
+	configMaxRoutines = 1
+	m.HTTPErrors["data::entityAddRecord"] = []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable}
+
+	runImport()
+
+	if counterTotal(counterCreateFailed) != 1 || counterTotal(counterCreated) != 1 || counterTotal(counterXMLMCRetries) != 1 {
+		t.Errorf("expected 1 create to fail after 1 retry, got %d created, %d failed, %d retries", counterTotal(counterCreated), counterTotal(counterCreateFailed), counterTotal(counterXMLMCRetries))
+	}
+}
+
+func TestImportRetryCreates(t *testing.T) {
+	m := newImportTest(t)
+	os.WriteFile("desktops.csv", []byte(testCSV), 0644)
+	loadTestConfig(t, m,
+		map[string]interface{}{"Source": "csv", "CSV": map[string]interface{}{"CommaCharacter": ","}},
+		desktopAssetType(map[string]interface{}{"CSVFile": "desktops.csv"}))
+	importConf.Retry = retryConfStruct{InitialBackoffMs: 1, MaxBackoffMs: 5}
+	configMaxRoutines = 1
+	//The connection is dropped after the first create and URN update are processed
+	m.HangUps["data::entityAddRecord"] = 1
+	m.HangUps["data::entityUpdateRecord"] = 1
+
+	runImport()
+
+	//The create is not retried, as it may have succeeded, where the update is
+	if len(m.Assets) != 2 || m.Calls["data::entityAddRecord"] != 2 {
+		t.Errorf("expected 2 assets from 2 creates, got %d assets from %d creates", len(m.Assets), m.Calls["data::entityAddRecord"])
+	}
+	if counterTotal(counterCreateFailed) != 1 || counterTotal(counterCreated) != 1 || counterTotal(counterXMLMCRetries) != 1 {
+		t.Errorf("expected 1 create to fail without a retry, and 1 update retry, got %d created, %d failed, %d retries", counterTotal(counterCreated), counterTotal(counterCreateFailed), counterTotal(counterXMLMCRetries))
+	}
+
+	//A create that could not connect was not sent, so is retried
+	espXmlmc := apiLib.NewXmlmcInstance("http://127.0.0.1:1/xmlmc/")
+	response, err := espXmlmc.Invoke("data", "entityAddRecord")
+	if err == nil || xmlmcRetryReason(espXmlmc, "entityAddRecord", response, err) == "" {
+		t.Errorf("expected a connection error to be retried, got %v", err)
+	}
+}
+
+func TestSetXMLMCParams(t *testing.T) {
+	espXmlmc := apiLib.NewXmlmcInstance("http://localhost/xmlmc/")
+	espXmlmc.SetParam("application", appServiceManager)
+	espXmlmc.OpenElement("primaryEntityData")
+	espXmlmc.OpenElement("record")
+	espXmlmc.SetParam("h_name", `Desktop <001> & "co"`)
+	espXmlmc.SetParam("h_description", "line one\nline two")
+	espXmlmc.SetParam("h_empty", "")
+	espXmlmc.SetParamAttr("h_site", "", []apiLib.ParamAttribStruct{{Name: "nil", Value: "true"}})
+	espXmlmc.CloseElement("record")
+	espXmlmc.CloseElement("primaryEntityData")
+	params := espXmlmc.GetParam()
+
+	espXmlmc.ClearParam()
+	if err := setXMLMCParams(espXmlmc, params); err != nil {
+		t.Fatal(err)
+	}
+	if espXmlmc.GetParam() != params {
+		t.Errorf("params not set again as sent:\n got %s\nwant %s", espXmlmc.GetParam(), params)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := retryConfStruct{MaxAttempts: 5, InitialBackoffMs: 100, MaxBackoffMs: 500, Jitter: retryJitterNone}
+	want := []time.Duration{100, 200, 400, 500, 500}
+	for i, w := range want {
+		if got := retryBackoff(policy, i+1); got != w*time.Millisecond {
+			t.Errorf("retry %d backoff = %v, want %v", i+1, got, w*time.Millisecond)
+		}
+	}
+	policy.Jitter = retryJitterEqual
+	for i := 0; i < 50; i++ {
+		if got := retryBackoff(policy, 2); got < 100*time.Millisecond || got > 200*time.Millisecond {
+			t.Fatalf("equal jitter backoff %v outside 100ms-200ms", got)
+		}
+	}
+
+	header := http.Header{}
+	header.Set("Retry-After", "2")
+	if got := retryDelay(policy, 1, header); got != 2*time.Second {
+		t.Errorf("Retry-After 2 delay = %v, want 2s", got)
+	}
+	header.Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	if got, _ := retryAfter(header); got != retryMaxRetryAfter {
+		t.Errorf("Retry-After an hour away = %v, want the %v limit", got, retryMaxRetryAfter)
+	}
+
+	importConf = importConfStruct{Retry: retryConfStruct{Jitter: "Equal"}}
+	t.Cleanup(func() { importConf = importConfStruct{} })
+	if err := checkRetryConfig(); err != nil {
+		t.Error(err)
+	}
+	for _, conf := range []retryConfStruct{{MaxAttempts: -1}, {InitialBackoffMs: 2000, MaxBackoffMs: 1000}, {Jitter: "random"}} {
+		importConf.Retry = conf
+		if err := checkRetryConfig(); err == nil {
+			t.Errorf("expected an error for %+v", conf)
+		}
+	}
+}
+
+func TestDoRequestRetry(t *testing.T) {
+	//Retries are written to the log file, in the working directory
+	cwd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(cwd) })
+	importConf = importConfStruct{Retry: retryConfStruct{InitialBackoffMs: 1, MaxBackoffMs: 5}}
+	resetCounters()
+	t.Cleanup(func() { importConf = importConfStruct{} })
+	var (
+		mutex  sync.Mutex
+		bodies []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mutex.Lock()
+		bodies = append(bodies, string(body))
+		attempt := len(bodies)
+		mutex.Unlock()
+		switch attempt {
+		case 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.Write([]byte(`{"ok":true}`))
+		}
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("POST", server.URL, strings.NewReader(`{"query":"devices"}`))
+	resp, err := doRequestRetry(server.Client(), req, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(bodies) != 3 {
+		t.Fatalf("got HTTP %d after %d requests, want 200 after 3", resp.StatusCode, len(bodies))
+	}
+	for _, body := range bodies {
+		if body != `{"query":"devices"}` {
+			t.Errorf("request body not sent again on retry, got %q", body)
+		}
+	}
+	if counterTotal(counterHTTPRetries) != 2 {
+		t.Errorf("httpRetries = %d, want 2", counterTotal(counterHTTPRetries))
+	}
+
+	//A 404 is not retried
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	defer notFound.Close()
+	req, _ = http.NewRequest("GET", notFound.URL, nil)
+	resp, err = doRequestRetry(notFound.Client(), req, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound || counterTotal(counterHTTPRetries) != 2 {
+		t.Errorf("got HTTP %d with %d retries, want 404 and no further retries", resp.StatusCode, counterTotal(counterHTTPRetries))
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule -- When the daemon runs the import
+type Schedule interface {
+	// Next returns the first run time after the given time, or the zero time if there is none
+	Next(after time.Time) time.Time
+	String() string
+}
+
+// intervalScheduleStruct -- Runs the import a fixed time after the previous run was due
+type intervalScheduleStruct struct {
+	interval time.Duration
+}
+
+func (s intervalScheduleStruct) Next(after time.Time) time.Time {
+	return after.Add(s.interval)
+}
+
+func (s intervalScheduleStruct) String() string {
+	return "every " + s.interval.String()
+}
+
+// cronScheduleStruct -- Runs the import at the times matching a five field cron expression, in local time.
+// Each field is held as a bit set of the values it matches
+type cronScheduleStruct struct {
+	expression string
+	minutes    uint64
+	hours      uint64
+	days       uint64
+	months     uint64
+	weekdays   uint64
+	// When either day field is *, both must match. Otherwise a day matching either field is run, as with cron
+	anyDay     bool
+	anyWeekday bool
+}
+
+// Shorthand cron expressions, and the five fields they stand for
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	cronMonthNames   = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	cronWeekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// cronSearchYears -- how far ahead Next looks for a matching time, as an expression such as 30 February never matches
+const cronSearchYears = 5
+
+// parseCron -- parses a cron expression of minute, hour, day of month, month and day of week fields, or a
+// shorthand such as @daily. Fields accept *, values, ranges (1-5), steps (*/15, 1-30/2), lists (1,15) and
+// three letter month and day names. Sunday is 0 or 7
+func parseCron(expression string) (*cronScheduleStruct, error) {
+	fieldsExpression := strings.TrimSpace(expression)
+	if descriptor, ok := cronDescriptors[strings.ToLower(fieldsExpression)]; ok {
+		fieldsExpression = descriptor
+	}
+	fields := strings.Fields(fieldsExpression)
+	if len(fields) != 5 {
+		return nil, errors.New("cron expression [" + expression + "] must have 5 fields: minute hour day-of-month month day-of-week")
+	}
+	schedule := &cronScheduleStruct{
+		expression: expression,
+		anyDay:     strings.HasPrefix(fields[2], "*"),
+		anyWeekday: strings.HasPrefix(fields[4], "*"),
+	}
+	var err error
+	for i, field := range []struct {
+		bits     *uint64
+		name     string
+		min, max int
+		names    []string
+	}{
+		{&schedule.minutes, "minute", 0, 59, nil},
+		{&schedule.hours, "hour", 0, 23, nil},
+		{&schedule.days, "day-of-month", 1, 31, nil},
+		{&schedule.months, "month", 1, 12, cronMonthNames},
+		{&schedule.weekdays, "day-of-week", 0, 7, cronWeekdayNames},
+	} {
+		*field.bits, err = parseCronField(fields[i], field.min, field.max, field.names)
+		if err != nil {
+			return nil, errors.New("cron expression [" + expression + "] " + field.name + " field: " + err.Error())
+		}
+	}
+	//Sunday can be given as 7
+	if schedule.weekdays&(1<<7) != 0 {
+		schedule.weekdays |= 1
+	}
+	if schedule.Next(time.Now()).IsZero() {
+		return nil, errors.New("cron expression [" + expression + "] does not match any time in the next " + strconv.Itoa(cronSearchYears) + " years")
+	}
+	return schedule, nil
+}
+
+// parseCronField -- returns the bit set of the values matched by a cron field. names, when given, are the names of
+// the values from min upwards
+func parseCronField(field string, min, max int, names []string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step < 1 {
+				return 0, errors.New("invalid step [" + stepPart + "]")
+			}
+		}
+		var start, end int
+		if rangePart == "*" {
+			start, end = min, max
+		} else {
+			startPart, endPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			start, err = cronFieldValue(startPart, min, max, names)
+			if err != nil {
+				return 0, err
+			}
+			end = start
+			if isRange {
+				end, err = cronFieldValue(endPart, min, max, names)
+				if err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				end = max
+			}
+			if end < start {
+				return 0, errors.New("invalid range [" + rangePart + "]")
+			}
+		}
+		for value := start; value <= end; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+// cronFieldValue -- returns the number for a value or name in a cron field, checking it is within min and max
+func cronFieldValue(value string, min, max int, names []string) (int, error) {
+	for i, name := range names {
+		if strings.EqualFold(value, name) {
+			return min + i, nil
+		}
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < min || number > max {
+		return 0, errors.New("value [" + value + "] must be between " + strconv.Itoa(min) + " and " + strconv.Itoa(max))
+	}
+	return number, nil
+}
+
+// Next -- returns the first minute after the given time that matches the expression, skipping a whole month,
+// day or hour at a time when it doesn't match
+func (s *cronScheduleStruct) Next(after time.Time) time.Time {
+	loc := after.Location()
+	t := time.Date(after.Year(), after.Month(), after.Day(), after.Hour(), after.Minute(), 0, 0, loc).Add(time.Minute)
+	limit := t.AddDate(cronSearchYears, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.months&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case s.hours&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case s.minutes&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (s *cronScheduleStruct) dayMatches(t time.Time) bool {
+	dayMatch := s.days&(1<<uint(t.Day())) != 0
+	weekdayMatch := s.weekdays&(1<<uint(t.Weekday())) != 0
+	if s.anyDay || s.anyWeekday {
+		return dayMatch && weekdayMatch
+	}
+	return dayMatch || weekdayMatch
+}
+
+func (s *cronScheduleStruct) String() string {
+	return "cron " + s.expression
+}
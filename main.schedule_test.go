@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronSchedule(t *testing.T) {
+	from := time.Date(2026, time.March, 4, 10, 17, 30, 0, time.UTC) // A Wednesday
+	tests := []struct {
+		expression string
+		want       time.Time
+	}{
+		{"* * * * *", time.Date(2026, time.March, 4, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, time.March, 4, 10, 30, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2026, time.March, 5, 2, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, time.March, 4, 11, 0, 0, 0, time.UTC)},
+		{"30 9-17/4 * * *", time.Date(2026, time.March, 4, 13, 30, 0, 0, time.UTC)},
+		{"0 6 * * sat,SUN", time.Date(2026, time.March, 7, 6, 0, 0, 0, time.UTC)},
+		{"0 6 * * 7", time.Date(2026, time.March, 8, 6, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan-mar *", time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		//Both day fields restricted, so either matches
+		{"0 0 15 * mon", time.Date(2026, time.March, 9, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		schedule, err := parseCron(tt.expression)
+		if err != nil {
+			t.Errorf("%q: %v", tt.expression, err)
+			continue
+		}
+		if got := schedule.Next(from); !got.Equal(tt.want) {
+			t.Errorf("%q: next run %v, want %v", tt.expression, got, tt.want)
+		}
+	}
+
+	for _, expression := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "0 0 0 * *", "* * * foo *", "5-1 * * * *", "*/0 * * * *", "0 0 30 2 *"} {
+		if _, err := parseCron(expression); err == nil {
+			t.Errorf("expected an error for cron expression %q", expression)
+		}
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"strconv"
-	"strings"
 
 	"github.com/cheggaaa/pb"
 )
@@ -13,13 +12,14 @@ import (
 func loadSites() {
 	pageSize := 100
 	rowStart := 0
+	Sites = newSiteCache()
 
 	hornbillImport.SetParam("rowstart", "0")
 	hornbillImport.SetParam("limit", "1")
 	hornbillImport.SetParam("orderByField", "h_site_name")
 	hornbillImport.SetParam("orderByWay", "ascending")
 
-	RespBody, xmlmcErr := hornbillImport.Invoke("apps/com.hornbill.core", "getSitesList")
+	RespBody, xmlmcErr := invokeRetry(hornbillImport, "apps/com.hornbill.core", "getSitesList", nil)
 	var JSONResp xmlmcSiteResponse
 	if xmlmcErr != nil {
 		logger(4, "Unable to Query Sites List "+xmlmcErr.Error(), false, true)
@@ -46,7 +46,7 @@ func loadSites() {
 		hornbillImport.SetParam("orderByField", "h_site_name")
 		hornbillImport.SetParam("orderByWay", "ascending")
 
-		RespBody, xmlmcErr = hornbillImport.Invoke("apps/com.hornbill.core", "getSitesList")
+		RespBody, xmlmcErr = invokeRetry(hornbillImport, "apps/com.hornbill.core", "getSitesList", nil)
 		if xmlmcErr != nil {
 			logger(4, "Unable to Query Sites List "+xmlmcErr.Error(), false, true)
 			return
@@ -76,8 +76,7 @@ func loadSites() {
 				var newSiteForCache siteListStruct
 				newSiteForCache.SiteID, _ = strconv.Atoi(JSONSites.Row[index].ID)
 				newSiteForCache.SiteName = JSONSites.Row[index].Name
-				name := []siteListStruct{newSiteForCache}
-				Sites = append(Sites, name...)
+				Sites.add(newSiteForCache)
 				loopCount++
 			}
 		} else {
@@ -90,15 +89,14 @@ func loadSites() {
 			var newSiteForCache siteListStruct
 			newSiteForCache.SiteID, _ = strconv.Atoi(JSONSites.Row.ID)
 			newSiteForCache.SiteName = JSONSites.Row.Name
-			name := []siteListStruct{newSiteForCache}
-			Sites = append(Sites, name...)
+			Sites.add(newSiteForCache)
 			loopCount++
 		}
 		bar.Add(loopCount)
 		rowStart += loopCount
 	}
 	bar.FinishPrint("Sites Loaded  \n")
-	logger(3, "Sites Loaded: "+strconv.Itoa(len(Sites)), false, true)
+	logger(3, "Sites Loaded: "+strconv.Itoa(Sites.len()), false, true)
 }
 
 func getSiteID(u map[string]interface{}, buffer *bytes.Buffer) (siteID int, siteName string) {
@@ -106,11 +104,10 @@ func getSiteID(u map[string]interface{}, buffer *bytes.Buffer) (siteID int, site
 	siteName = getFieldValue("h_site", siteNameMapping, u, buffer)
 	if siteName != "" && siteName != "__clear__" {
 		//-- Check if in Cache
-		for _, site := range Sites {
-			if strings.EqualFold(site.SiteName, siteName) {
-				siteID = site.SiteID
-				break
-			}
+		if site, found := Sites.get(siteName); found {
+			siteID = site.SiteID
+		} else if importConf.AutoCreate.Sites && siteName != "<nil>" {
+			siteID = autoCreateSiteRecord(siteName, buffer)
 		}
 	}
 	debugLog(buffer, "Site Mapping:", siteNameMapping, ":", siteName, ":", strconv.Itoa(siteID))
@@ -11,119 +11,96 @@ import (
 
 	"github.com/Masterminds/sprig"
 	apiLib "github.com/hornbill/goApiLib"
-	"github.com/jmoiron/sqlx"
 )
 
-func getSoftwareRecords(u map[string]interface{}, assetType assetTypesStruct, espXmlmc *apiLib.XmlmcInstStruct, db *sqlx.DB, buffer *bytes.Buffer) (map[string]map[string]interface{}, string, error) {
+// getSoftwareRecordsFromParentObject -- returns the software inventory records held in an array against the source asset record,
+// for sources that return software inventory alongside the asset (Certero, Workspace One)
+func getSoftwareRecordsFromParentObject(u map[string]interface{}, assetType assetTypesStruct, parentObject string, buffer *bytes.Buffer) (map[string]map[string]interface{}, string, error) {
 	var (
 		softwareRecords     = make(map[string]map[string]interface{})
 		softwareRecordsHash string
 		err                 error
+		recordMap           []map[string]interface{}
 	)
-	if configCSV {
-		return softwareRecords, softwareRecordsHash, err
-	}
-
-	if configCertero || configWorkspaceOne {
-		var recordMap []map[string]interface{}
-		if configWorkspaceOne {
-			assetType.SoftwareInventory.ParentObject = "InstalledSoftware"
-		}
-		if u[assetType.SoftwareInventory.ParentObject] != nil {
-			recordMap = u[assetType.SoftwareInventory.ParentObject].([]map[string]interface{})
-			recordsHash := Hash(recordMap)
-			softwareRecordsHash = fmt.Sprintf("%v", recordsHash)
-
-			//Now process return map
-			for _, v := range recordMap {
-				//Get the software ID for the current record
-				softwareIDIdent := fmt.Sprintf("%v", assetType.SoftwareInventory.AppIDColumn)
-				matched := regexTemplate.MatchString(softwareIDIdent)
-				if matched {
-					t := template.New(softwareIDIdent).Funcs(TemplateFilters).Funcs(sprig.FuncMap())
-					tmpl, _ := t.Parse(softwareIDIdent)
-					buf := bytes.NewBufferString("")
-					tmpl.Execute(buf, v)
-					softwareID := ""
-					if buf != nil {
-						// Convert install date
-						if v["InstallDate"] != nil {
-							layout := "2006-01-02T15:04:05"
-							installDate := v["InstallDate"].(string)[0:19]
-							t, err := time.Parse(layout, installDate)
-
-							if err == nil {
-								v["InstallDate"] = t.Format("2006-01-02 15:04:05")
-							} else {
-								buffer.WriteString(loggerGen(5, "Error parsing InstallDate:"+err.Error()))
-							}
+	if u[parentObject] != nil {
+		recordMap = u[parentObject].([]map[string]interface{})
+		recordsHash := Hash(recordMap)
+		softwareRecordsHash = fmt.Sprintf("%v", recordsHash)
+
+		//Now process return map
+		for _, v := range recordMap {
+			//Get the software ID for the current record
+			softwareIDIdent := fmt.Sprintf("%v", assetType.SoftwareInventory.AppIDColumn)
+			matched := regexTemplate.MatchString(softwareIDIdent)
+			if matched {
+				t := template.New(softwareIDIdent).Funcs(TemplateFilters).Funcs(sprig.FuncMap())
+				tmpl, _ := t.Parse(softwareIDIdent)
+				buf := bytes.NewBufferString("")
+				tmpl.Execute(buf, v)
+				softwareID := ""
+				if buf != nil {
+					// Convert install date
+					if v["InstallDate"] != nil {
+						layout := "2006-01-02T15:04:05"
+						installDate := v["InstallDate"].(string)[0:19]
+						t, err := time.Parse(layout, installDate)
+
+						if err == nil {
+							v["InstallDate"] = t.Format("2006-01-02 15:04:05")
+						} else {
+							buffer.WriteString(loggerGen(5, "Error parsing InstallDate:"+err.Error()))
 						}
-						softwareID = buf.String()
-						softwareRecords[softwareID] = v
-					} else {
-						buffer.WriteString(loggerGen(4, "unable to read software inventory record from Certero, software ID not found in record"))
 					}
+					softwareID = buf.String()
+					softwareRecords[softwareID] = v
 				} else {
-					err = errors.New("the AppIDColumn is not properly formatted, importing from Certero requires this to be a Go template")
-					return softwareRecords, softwareRecordsHash, err
-				}
-			}
-		}
-	} else if assetType.SoftwareInventory.Query != "" && assetType.SoftwareInventory.AssetIDColumn != "" {
-		swAssetID := ""
-		assetIDIdent := fmt.Sprintf("%v", assetType.SoftwareInventory.AssetIDColumn)
-		matched := regexTemplate.MatchString(assetIDIdent)
-		if matched {
-			//Get the asset ID for the current record - using Go templates
-			t := template.New(assetIDIdent).Funcs(TemplateFilters).Funcs(sprig.FuncMap())
-			tmpl, _ := t.Parse(assetIDIdent)
-			buf := bytes.NewBufferString("")
-			tmpl.Execute(buf, u)
-
-			if buf != nil {
-				swAssetID = buf.String()
-			}
-		} else if configNexthink {
-			swAssetID = iToS(u["id"])
-		} else {
-			if val, ok := u[assetType.SoftwareInventory.AssetIDColumn]; ok {
-				swAssetID = iToS(val)
-			}
-		}
-		if swAssetID != "" {
-			if configNexthink {
-				softwareRecords, softwareRecordsHash, err = queryNexthinkSoftwareInventoryRecords(swAssetID, assetType, buffer)
-				if err != nil {
-					err = errors.New("Unable to read software inventory records from Nexthink:" + err.Error())
+					buffer.WriteString(loggerGen(4, "unable to read software inventory record from Certero, software ID not found in record"))
 				}
 			} else {
-				softwareRecords, softwareRecordsHash, err = querySoftwareInventoryRecords(swAssetID, assetType, db, buffer)
-				if err != nil {
-					err = errors.New("Unable to read software inventory records from source DB:" + err.Error())
-				}
+				err = errors.New("the AppIDColumn is not properly formatted, importing from Certero requires this to be a Go template")
+				return softwareRecords, softwareRecordsHash, err
 			}
-		} else {
-			err = errors.New("unable to read software inventory records from source db, asset ID not found in db record")
 		}
 	}
 	return softwareRecords, softwareRecordsHash, err
 }
 
-func buildSoftwareInventory(softwareRecords map[string]map[string]interface{}, assetType assetTypesStruct, hbAssetID string, espXmlmc *apiLib.XmlmcInstStruct, buffer *bytes.Buffer) {
-	countSuccess := 0
+// getSoftwareAssetID -- returns the value from the source asset record used to query its software inventory
+func getSoftwareAssetID(u map[string]interface{}, assetType assetTypesStruct) (swAssetID string) {
+	assetIDIdent := fmt.Sprintf("%v", assetType.SoftwareInventory.AssetIDColumn)
+	matched := regexTemplate.MatchString(assetIDIdent)
+	if matched {
+		//Get the asset ID for the current record - using Go templates
+		t := template.New(assetIDIdent).Funcs(TemplateFilters).Funcs(sprig.FuncMap())
+		tmpl, _ := t.Parse(assetIDIdent)
+		buf := bytes.NewBufferString("")
+		tmpl.Execute(buf, u)
+
+		if buf != nil {
+			swAssetID = buf.String()
+		}
+	} else {
+		if val, ok := u[assetType.SoftwareInventory.AssetIDColumn]; ok {
+			swAssetID = iToS(val)
+		}
+	}
+	return
+}
+
+// buildSoftwareInventory -- adds the software inventory records to a Hornbill asset, returning the number added
+func buildSoftwareInventory(softwareRecords map[string]map[string]interface{}, assetType assetTypesStruct, hbAssetID string, espXmlmc *apiLib.XmlmcInstStruct, buffer *bytes.Buffer) (countSuccess int) {
 	buffer.WriteString(loggerGen(1, strconv.Itoa(len(softwareRecords))+" Software Inventory Records processing..."))
 	for k, v := range softwareRecords {
 		_, err := addSoftwareInventoryRecord(hbAssetID, v, assetType, espXmlmc, buffer)
 		if err != nil {
 			buffer.WriteString(loggerGen(4, "Error creating software record ["+k+"]:"+err.Error()))
-			mutexCounters.Lock()
-			counters.softwareCreateFailed++
-			mutexCounters.Unlock()
+			incCounter(assetType.AssetType, counterSoftwareCreateFailed)
 		} else {
 			countSuccess++
 		}
 	}
 	buffer.WriteString(loggerGen(1, strconv.Itoa(countSuccess)+" of "+strconv.Itoa(len(softwareRecords))+" added successfully"))
+	return
 }
 
 func addSoftwareInventoryRecord(fkAssetID string, softwareRecord map[string]interface{}, assetType assetTypesStruct, espXmlmc *apiLib.XmlmcInstStruct, buffer *bytes.Buffer) (pkid int, err error) {
@@ -154,7 +131,7 @@ func addSoftwareInventoryRecord(fkAssetID string, softwareRecord map[string]inte
 	espXmlmc.CloseElement("primaryEntityData")
 	XMLSTRING := espXmlmc.GetParam()
 	debugLog(buffer, "Software Record Create XML:", XMLSTRING)
-	XMLUpdate, xmlmcErr := espXmlmc.Invoke("data", "entityAddRecord")
+	XMLUpdate, xmlmcErr := invokeRetry(espXmlmc, "data", "entityAddRecord", buffer)
 	if xmlmcErr != nil {
 		buffer.WriteString(loggerGen(1, "API Call XML: "+XMLSTRING))
 		err = errors.New("API Call failed when creating software inventory record:" + xmlmcErr.Error())
@@ -176,19 +153,17 @@ func addSoftwareInventoryRecord(fkAssetID string, softwareRecord map[string]inte
 	}
 	pkid = xmlRespon.Params.HPKID
 	debugLog(buffer, "Software inventory record successfully created: "+strconv.Itoa(pkid)+" - "+packageName)
-	mutexCounters.Lock()
-	counters.softwareCreated++
-	mutexCounters.Unlock()
+	incCounter(assetType.AssetType, counterSoftwareCreated)
 	return
 }
 
-func deleteSoftwareInventoryRecord(pkid int, espXmlmc *apiLib.XmlmcInstStruct, buffer *bytes.Buffer) (err error) {
+func deleteSoftwareInventoryRecord(assetType assetTypesStruct, pkid int, espXmlmc *apiLib.XmlmcInstStruct, buffer *bytes.Buffer) (err error) {
 	espXmlmc.SetParam("application", "com.hornbill.servicemanager")
 	espXmlmc.SetParam("entity", "AssetsInstalledSoftware")
 	espXmlmc.SetParam("keyValue", strconv.Itoa(pkid))
 	XMLSTRING := espXmlmc.GetParam()
 	debugLog(buffer, "Software Record Delete XML:", XMLSTRING)
-	XMLUpdate, xmlmcErr := espXmlmc.Invoke("data", "entityDeleteRecord")
+	XMLUpdate, xmlmcErr := invokeRetry(espXmlmc, "data", "entityDeleteRecord", buffer)
 	if xmlmcErr != nil {
 		buffer.WriteString(loggerGen(1, "API Call XML: "+XMLSTRING))
 		err = errors.New("API Call failed when deleting software inventory record:" + xmlmcErr.Error())
@@ -209,9 +184,7 @@ func deleteSoftwareInventoryRecord(pkid int, espXmlmc *apiLib.XmlmcInstStruct, b
 		return
 	}
 	debugLog(buffer, "Software inventory record successfully deleted: "+strconv.Itoa(pkid))
-	mutexCounters.Lock()
-	counters.softwareRemoved++
-	mutexCounters.Unlock()
+	incCounter(assetType.AssetType, counterSoftwareRemoved)
 	return
 }
 
@@ -228,7 +201,7 @@ func getAssetSoftwareCount(assetID string, espXmlmc *apiLib.XmlmcInstStruct, buf
 	XMLSTRING := espXmlmc.GetParam()
 	debugLog(buffer, "Software Record Get XML:", XMLSTRING)
 
-	RespBody, err := espXmlmc.Invoke("data", "queryExec")
+	RespBody, err := invokeRetry(espXmlmc, "data", "queryExec", buffer)
 	var XMLResp xmlmcSoftwareRecordsResponse
 	if err != nil {
 		return
@@ -273,7 +246,7 @@ func getAssetSoftwareRecords(assetID string, assetCount uint64, espXmlmc *apiLib
 		XMLSTRING := espXmlmc.GetParam()
 		debugLog(buffer, "Software Record Get XML:", XMLSTRING)
 
-		RespBody, err = espXmlmc.Invoke("data", "queryExec")
+		RespBody, err = invokeRetry(espXmlmc, "data", "queryExec", buffer)
 		var JSONResp xmlmcSoftwareRecordsResponse
 		if err != nil {
 			err = errors.New("Error returning page of asset records: " + err.Error())
@@ -308,7 +281,7 @@ func getAssetSoftwareRecords(assetID string, assetCount uint64, espXmlmc *apiLib
 	return recordMap, err
 }
 
-func updateAssetSI(assetID string, softwareRecords map[string]map[string]interface{}, softwareRecordsHash string, assetType assetTypesStruct, espXmlmc *apiLib.XmlmcInstStruct, buffer *bytes.Buffer) (err error) {
+func updateAssetSI(assetID string, softwareRecords map[string]map[string]interface{}, softwareRecordsHash string, assetType assetTypesStruct, espXmlmc *apiLib.XmlmcInstStruct, buffer *bytes.Buffer) (softwareAdded, softwareRemoved int, err error) {
 	//Get SI records for asset
 	//Remove HB SI records that don't exist in DB SI source
 	//Add new HB SI records that exist in DB SI source but don't exist in HB SI records against the asset being processed
@@ -316,8 +289,6 @@ func updateAssetSI(assetID string, softwareRecords map[string]map[string]interfa
 		hbSIRecordCount        uint64
 		hbSICache              = make(map[string]softwareRecordDetailsStruct)
 		boolUpdateSoftwareHash = true
-		softwareAdded          int
-		softwareRemoved        int
 	)
 	buffer.WriteString(loggerGen(1, "Processing Software Inventory updates for asset: "+assetID))
 	//Process Software Inventory updates
@@ -343,11 +314,9 @@ func updateAssetSI(assetID string, softwareRecords map[string]map[string]interfa
 				}
 			}
 			if delRec {
-				err = deleteSoftwareInventoryRecord(cV.HPKID, espXmlmc, buffer)
+				err = deleteSoftwareInventoryRecord(assetType, cV.HPKID, espXmlmc, buffer)
 				if err != nil {
-					mutexCounters.Lock()
-					counters.softwareRemoveFailed++
-					mutexCounters.Unlock()
+					incCounter(assetType.AssetType, counterSoftwareRemoveFailed)
 					buffer.WriteString(loggerGen(4, "Error deleting software inventory record: "+err.Error()))
 					boolUpdateSoftwareHash = false
 				} else {
@@ -369,9 +338,7 @@ func updateAssetSI(assetID string, softwareRecords map[string]map[string]interfa
 				_, err := addSoftwareInventoryRecord(assetID, sV, assetType, espXmlmc, buffer)
 				if err != nil {
 					buffer.WriteString(loggerGen(4, "Error creating software record:"+err.Error()))
-					mutexCounters.Lock()
-					counters.softwareCreateFailed++
-					mutexCounters.Unlock()
+					incCounter(assetType.AssetType, counterSoftwareCreateFailed)
 					boolUpdateSoftwareHash = false
 				} else {
 					softwareAdded++
@@ -381,7 +348,7 @@ func updateAssetSI(assetID string, softwareRecords map[string]map[string]interfa
 		buffer.WriteString(loggerGen(1, strconv.Itoa(softwareAdded)+" software records successfully added"))
 		buffer.WriteString(loggerGen(1, strconv.Itoa(softwareRemoved)+" software records successfully removed"))
 	} else {
-		buildSoftwareInventory(softwareRecords, assetType, assetID, espXmlmc, buffer)
+		softwareAdded = buildSoftwareInventory(softwareRecords, assetType, assetID, espXmlmc, buffer)
 	}
 
 	if boolUpdateSoftwareHash {
@@ -402,7 +369,7 @@ func updateAssetSI(assetID string, softwareRecords map[string]map[string]interfa
 
 		XMLSTRING := espXmlmc.GetParam()
 		var XMLUpdate string
-		XMLUpdate, err = espXmlmc.Invoke("data", "entityUpdateRecord")
+		XMLUpdate, err = invokeRetry(espXmlmc, "data", "entityUpdateRecord", buffer)
 
 		if err != nil {
 			buffer.WriteString(loggerGen(3, "API Call failed when Updating Asset Software Inventory ID:"+err.Error()))
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Source -- An asset data source. Connectors register a constructor against one or more
+// source names with registerSource, and are selected by SourceConfig.Source, either globally
+// or per asset type
+type Source interface {
+	// ValidateConfig checks the source configuration before any asset types are processed
+	ValidateConfig() error
+	// GetAssets returns the source records for an asset type, keyed on the asset identifier
+	GetAssets(assetType assetTypesStruct) (map[string]map[string]interface{}, error)
+}
+
+// SoftwareInventorySource -- A Source that holds the software installed on its assets. Computer and mobile device
+// assets from sources that don't implement it, such as CSV, skip the software inventory checks
+type SoftwareInventorySource interface {
+	Source
+	// GetSoftwareInventory returns the software inventory records for a single source asset record, keyed
+	// on the software identifier, along with a hash of the records
+	GetSoftwareInventory(assetRecord map[string]interface{}, assetType assetTypesStruct, buffer *bytes.Buffer) (map[string]map[string]interface{}, string, error)
+}
+
+// assetEmitter -- passes a single source record, keyed on the asset identifier, to the asset pipeline.
+// Returns false once the pipeline has stopped reading, after which the source should stop and return
+type assetEmitter func(assetID string, record map[string]interface{}) bool
+
+// StreamingSource -- A Source that can emit its records as they are read, so the import does not need to hold
+// every source record in memory. Sources that don't implement it are read in full with GetAssets
+type StreamingSource interface {
+	Source
+	// StreamAssets emits the source records for an asset type as they are read
+	StreamAssets(assetType assetTypesStruct, emit assetEmitter) error
+}
+
+// AssetIdentifierSource -- A Source whose records are not flat maps of column values, such as REST records addressed
+// with dotted paths, so it works out the asset identifier of its own records
+type AssetIdentifierSource interface {
+	Source
+	// AssetIdentifier returns the asset identifier of a source record, from the AssetIdentifier SourceColumn
+	AssetIdentifier(assetRecord map[string]interface{}, assetType assetTypesStruct) string
+}
+
+// collectAssets -- reads every record from a StreamingSource in to a map keyed on the asset identifier,
+// for the GetAssets implementation of streaming sources
+func collectAssets(s StreamingSource, assetType assetTypesStruct) (map[string]map[string]interface{}, error) {
+	returnMap := make(map[string]map[string]interface{})
+	err := s.StreamAssets(assetType, func(assetID string, record map[string]interface{}) bool {
+		returnMap[assetID] = record
+		return true
+	})
+	return returnMap, err
+}
+
+// sourceConstructor -- returns a new instance of a Source, using the supplied source configuration and Keysafe key
+type sourceConstructor func(conf sourceConfStruct, key keyDataStruct) Source
+
+var (
+	sourceRegistry      = make(map[string]sourceConstructor)
+	mutexSourceRegistry = &sync.Mutex{}
+)
+
+// registerSource -- makes a Source available under the given (case-insensitive) names
+func registerSource(constructor sourceConstructor, names ...string) {
+	mutexSourceRegistry.Lock()
+	defer mutexSourceRegistry.Unlock()
+	for _, name := range names {
+		name = strings.ToLower(name)
+		if _, exists := sourceRegistry[name]; exists {
+			panic("registerSource: source already registered: " + name)
+		}
+		sourceRegistry[name] = constructor
+	}
+}
+
+// newSource -- returns a new instance of the Source registered against conf.Source
+func newSource(conf sourceConfStruct, key keyDataStruct) (Source, error) {
+	mutexSourceRegistry.Lock()
+	constructor, ok := sourceRegistry[strings.ToLower(conf.Source)]
+	mutexSourceRegistry.Unlock()
+	if !ok {
+		return nil, errors.New("unsupported source [" + conf.Source + "], supported sources are: " + strings.Join(registeredSources(), ", "))
+	}
+	return constructor(conf, key), nil
+}
+
+// loadSources -- returns a validated Source for each asset type, in the same order as importConf.AssetTypes.
+// Asset types with their own SourceConfig get their own Source, using their own Keysafe key if one is defined
+// or the global key if not. All other asset types share a single Source built from the global SourceConfig
+func loadSources(globalKey keyDataStruct) ([]Source, error) {
+	var (
+		sources      = make([]Source, len(importConf.AssetTypes))
+		globalSource Source
+		keyCache     = map[int]keyDataStruct{importConf.KeysafeKeyID: globalKey}
+	)
+	for i, assetType := range importConf.AssetTypes {
+		if assetType.SourceConfig == nil {
+			if globalSource == nil {
+				conf := importConf.SourceConfig
+				conf.KeysafeKeyID = importConf.KeysafeKeyID
+				src, err := newSource(conf, globalKey)
+				if err != nil {
+					return sources, err
+				}
+				err = src.ValidateConfig()
+				if err != nil {
+					return sources, err
+				}
+				globalSource = src
+			}
+			sources[i] = globalSource
+			continue
+		}
+
+		conf := *assetType.SourceConfig
+		if conf.KeysafeKeyID == 0 {
+			conf.KeysafeKeyID = importConf.KeysafeKeyID
+		}
+		assetTypeKey, ok := keyCache[conf.KeysafeKeyID]
+		if !ok {
+			var err error
+			assetTypeKey, err = getKeysafeKey(conf.KeysafeKeyID)
+			if err != nil {
+				return sources, errors.New(assetType.AssetType + ": " + err.Error())
+			}
+			keyCache[conf.KeysafeKeyID] = assetTypeKey
+		}
+		logger(3, "AssetType: "+assetType.AssetType+" using source: "+conf.Source, true, true)
+		src, err := newSource(conf, assetTypeKey)
+		if err != nil {
+			return sources, errors.New(assetType.AssetType + ": " + err.Error())
+		}
+		err = src.ValidateConfig()
+		if err != nil {
+			return sources, errors.New(assetType.AssetType + ": " + err.Error())
+		}
+		sources[i] = src
+	}
+	return sources, nil
+}
+
+// registeredSources -- returns a sorted list of the registered source names
+func registeredSources() []string {
+	mutexSourceRegistry.Lock()
+	defer mutexSourceRegistry.Unlock()
+	var names []string
+	for name := range sourceRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/Masterminds/sprig"
+)
+
+// importStateStruct -- Persisted between runs in the state file
+type importStateStruct struct {
+	Watermarks map[string]string `json:"Watermarks"`
+}
+
+// watermarkTemplateStruct -- Data available to an asset type Query when a WatermarkColumn is defined
+type watermarkTemplateStruct struct {
+	Watermark string
+	Full      bool
+}
+
+var (
+	importState = importStateStruct{Watermarks: make(map[string]string)}
+	mutexState  = &sync.Mutex{}
+)
+
+// getStateFilePath -- returns the path of the state file, defaulting to one named after the config file
+func getStateFilePath() string {
+	if importConf.StateFile != "" {
+		return importConf.StateFile
+	}
+	cwd, _ := os.Getwd()
+	return cwd + "/" + strings.TrimSuffix(configFileName, ".json") + ".state.json"
+}
+
+// loadState -- loads the state file, if one exists
+func loadState() error {
+	stateFile := getStateFilePath()
+	content, err := os.ReadFile(stateFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.New("Unable to read state file " + stateFile + ": " + err.Error())
+	}
+	mutexState.Lock()
+	defer mutexState.Unlock()
+	err = json.Unmarshal(content, &importState)
+	if err != nil {
+		return errors.New("Unable to decode state file " + stateFile + ": " + err.Error())
+	}
+	if importState.Watermarks == nil {
+		importState.Watermarks = make(map[string]string)
+	}
+	return nil
+}
+
+// saveState -- writes the state file, via a temporary file so an interrupted write can't corrupt it
+func saveState() error {
+	stateFile := getStateFilePath()
+	mutexState.Lock()
+	content, err := json.MarshalIndent(importState, "", "    ")
+	mutexState.Unlock()
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(stateFile+".tmp", content, 0644)
+	if err != nil {
+		return errors.New("Unable to write state file " + stateFile + ": " + err.Error())
+	}
+	err = os.Rename(stateFile+".tmp", stateFile)
+	if err != nil {
+		return errors.New("Unable to write state file " + stateFile + ": " + err.Error())
+	}
+	return nil
+}
+
+// getWatermark -- returns the stored high-water mark for an asset type, or an empty string if a full import is required
+func getWatermark(assetType assetTypesStruct) string {
+	if assetType.WatermarkColumn == "" || configFullImport {
+		return ""
+	}
+	mutexState.Lock()
+	defer mutexState.Unlock()
+	return importState.Watermarks[assetType.AssetType]
+}
+
+// applyWatermark -- executes the asset type Query as a Go template, exposing the stored watermark as {{.Watermark}}
+// and {{.Full}} when there is no stored watermark to filter on
+func applyWatermark(assetType assetTypesStruct) (string, error) {
+	if assetType.WatermarkColumn == "" {
+		return assetType.Query, nil
+	}
+	watermark := getWatermark(assetType)
+	t, err := template.New(assetType.AssetType).Funcs(TemplateFilters).Funcs(sprig.FuncMap()).Parse(assetType.Query)
+	if err != nil {
+		return "", errors.New("Unable to parse Query template: " + err.Error())
+	}
+	buf := bytes.NewBufferString("")
+	err = t.Execute(buf, watermarkTemplateStruct{Watermark: watermark, Full: watermark == ""})
+	if err != nil {
+		return "", errors.New("Unable to execute Query template: " + err.Error())
+	}
+	return buf.String(), nil
+}
+
+// watermarkTimeFormat -- The format of time.Time WatermarkColumn values, which the mssql and postgres drivers return
+// for native date and time columns. It sorts as a string, and SQL Server, PostgreSQL and MySQL parse it as a literal
+const watermarkTimeFormat = "2006-01-02 15:04:05.000"
+
+// watermarkValue -- returns a source WatermarkColumn value as it is compared and stored
+func watermarkValue(value interface{}) string {
+	if t, ok := value.(time.Time); ok {
+		return t.Format(watermarkTimeFormat)
+	}
+	return iToS(value)
+}
+
+// maxWatermark -- returns the higher of a source record WatermarkColumn value and the watermark so far. Values are
+// compared numerically when both are numbers, otherwise as strings, which suits ISO-style timestamps
+func maxWatermark(value, watermark string) string {
+	if value != "" && watermarkGreater(value, watermark) {
+		return value
+	}
+	return watermark
+}
+
+func watermarkGreater(a, b string) bool {
+	if b == "" {
+		return true
+	}
+	aNum, aErr := strconv.ParseFloat(a, 64)
+	bNum, bErr := strconv.ParseFloat(b, 64)
+	if aErr == nil && bErr == nil {
+		return aNum > bNum
+	}
+	return a > b
+}
+
+// setWatermark -- records the new high-water mark for an asset type, and persists the state file
+func setWatermark(assetType assetTypesStruct, watermark string) {
+	if watermark == "" || configDryRun {
+		return
+	}
+	mutexState.Lock()
+	importState.Watermarks[assetType.AssetType] = watermark
+	mutexState.Unlock()
+	err := saveState()
+	if err != nil {
+		logger(4, err.Error(), true, true)
+		return
+	}
+	logger(3, "Watermark for "+assetType.AssetType+" assets set to: "+watermark, true, true)
+}
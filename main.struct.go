@@ -23,41 +23,33 @@ var (
 	assets         = make(map[string]string)
 	AssetClass     string
 	AssetTypeID    int
-	counters       counterTypeStruct
+	counters       countersStruct
 	logFilePart    = 0
 	maxLogFileSize int64
 	pageSize       int
 	startTime      time.Time
 	StrAssetType   string
 
-	// DB variables
-	BaseSQLQuery string
-	connString   string
-	importConf   importConfStruct
-	key          keyDataStruct
-	StrSQLAppend string
+	// Config variables
+	importConf importConfStruct
 
 	// CLI argument variables
 	configDebug        bool
+	configDaemon       bool
 	configDryRun       bool
 	configFileName     string
 	configForceUpdates bool
+	configFullImport   bool
+	configLogFormat    string
 	configMaxRoutines  int
+	configReportFile   string
+	configResume       bool
 	configVersion      bool
 
-	// Import config flags
-	configCertero      bool
-	configCSV          bool
-	configDB           bool
-	configGoogle       bool
-	configLDAP         bool
-	configNexthink     bool
-	configWorkspaceOne bool
-
 	// Global caches
-	Sites                  []siteListStruct
-	Groups                 []groupListStruct
-	Customers              []customerListStruct
+	Sites                  *siteCacheStruct
+	Groups                 *groupCacheStruct
+	Customers              *userCacheStruct
 	HInstalledApplications = make(map[string]bool)
 
 	// Worker stuff
@@ -70,32 +62,14 @@ var (
 	// Shared Hornbill session for caching etc
 	hornbillImport *apiLib.XmlmcInstStruct
 
+	// Hornbill session for logging to the instance, as the source may log while the shared session is in use
+	espLoggerXmlmc *apiLib.XmlmcInstStruct
+	mutexEspLogger = &sync.Mutex{}
+
 	// Regex to check if a field contain Go templates
 	regexTemplate, _ = regexp.Compile("{{.{1,}}}")
 )
 
-type counterTypeStruct struct {
-	updated                            uint16
-	created                            uint16
-	updateSkipped                      uint16
-	updateRelatedFailed                uint16
-	updateRelatedSkipped               uint16
-	createSkipped                      uint16
-	updateFailed                       uint16
-	createFailed                       uint16
-	softwareCreated                    uint32
-	softwareRemoved                    uint32
-	softwareSkipped                    uint32
-	softwareCreateFailed               uint32
-	softwareRemoveFailed               uint32
-	suppliersAssociatedSuccess         uint16
-	suppliersAssociatedFailed          uint16
-	suppliersAssociatedSkipped         uint16
-	supplierContractsAssociatedSuccess uint16
-	supplierContractsAssociatedFailed  uint16
-	supplierContractsAssociatedSkipped uint16
-}
-
 // -- Cache Structs
 type siteListStruct struct {
 	SiteName string
@@ -120,22 +94,104 @@ type importConfStruct struct {
 	KeysafeKeyID             int    `json:"KeysafeKeyID"`
 	AssetGenericFieldMapping map[string]interface{}
 	AssetTypeFieldMapping    map[string]interface{}
-	AssetTypes               []assetTypesStruct `json:"AssetTypes"`
-	HornbillUserIDColumn     string             `json:"HornbillUserIDColumn"`
-	LogSizeBytes             int64              `json:"LogSizeBytes"`
-	SourceConfig             struct {
-		CSV      csvConfStruct     `json:"CSV"`
-		Database dbConfStruct      `json:"Database"`
-		LDAP     ldapConfStruct    `json:"LDAP"`
-		Google   googleConfStruct  `json:"Google"`
-		Certero  certeroConfStruct `json:"Certero"`
-		Source   string            `json:"Source"`
-	} `json:"SourceConfig"`
+	AssetTypes               []assetTypesStruct   `json:"AssetTypes"`
+	HornbillUserIDColumn     string               `json:"HornbillUserIDColumn"`
+	HornbillUserMatch        []userMatchStruct    `json:"HornbillUserMatch"`
+	AutoCreate               autoCreateConfStruct `json:"AutoCreate"`
+	Retry                    retryConfStruct      `json:"Retry"`
+	RateLimit                rateLimitConfStruct  `json:"RateLimit"`
+	ExitCodes                exitCodesConfStruct  `json:"ExitCodes"`
+	Metrics                  metricsConfStruct    `json:"Metrics"`
+	Daemon                   daemonConfStruct     `json:"Daemon"`
+	Redact                   redactConfStruct     `json:"Redact"`
+	LogSizeBytes             int64                `json:"LogSizeBytes"`
+	StateFile                string               `json:"StateFile"`
+	JournalFile              string               `json:"JournalFile"`
+	SourceConfig             sourceConfStruct     `json:"SourceConfig"`
+}
+type autoCreateConfStruct struct {
+	Sites         bool `json:"Sites"`
+	Companies     bool `json:"Companies"`
+	Departments   bool `json:"Departments"`
+	ParentCompany bool `json:"ParentCompany"`
+}
+type retryConfStruct struct {
+	MaxAttempts      int    `json:"MaxAttempts"`
+	InitialBackoffMs int    `json:"InitialBackoffMs"`
+	MaxBackoffMs     int    `json:"MaxBackoffMs"`
+	Jitter           string `json:"Jitter"`
+}
+type rateLimitConfStruct struct {
+	CallsPerSecond float64            `json:"CallsPerSecond"`
+	Burst          int                `json:"Burst"`
+	Services       map[string]float64 `json:"Services"`
+}
+type exitCodesConfStruct struct {
+	SourceFailure  int                `json:"SourceFailure"`
+	PartialFailure int                `json:"PartialFailure"`
+	TotalFailure   int                `json:"TotalFailure"`
+	Thresholds     map[string]float64 `json:"Thresholds"`
+}
+type metricsConfStruct struct {
+	TextFile string `json:"TextFile"`
+}
+type daemonConfStruct struct {
+	Cron            string `json:"Cron"`
+	IntervalMinutes int    `json:"IntervalMinutes"`
+	RunOnStart      bool   `json:"RunOnStart"`
+	CacheTTLMinutes int    `json:"CacheTTLMinutes"`
+	StatusAddress   string `json:"StatusAddress"`
+	HistorySize     int    `json:"HistorySize"`
+}
+type redactConfStruct struct {
+	Columns         []string `json:"Columns"`
+	Patterns        []string `json:"Patterns"`
+	MaxPayloadBytes int      `json:"MaxPayloadBytes"`
+}
+type userMatchStruct struct {
+	Column    string   `json:"Column"`
+	Normalise []string `json:"Normalise"`
+}
+type sourceConfStruct struct {
+	CSV          csvConfStruct     `json:"CSV"`
+	Database     dbConfStruct      `json:"Database"`
+	LDAP         ldapConfStruct    `json:"LDAP"`
+	Google       googleConfStruct  `json:"Google"`
+	Certero      certeroConfStruct `json:"Certero"`
+	REST         restConfStruct    `json:"REST"`
+	Source       string            `json:"Source"`
+	KeysafeKeyID int               `json:"KeysafeKeyID"`
 }
 type certeroConfStruct struct {
 	Expand   string `json:"Expand"`
 	PageSize int    `json:"PageSize"`
 }
+type restConfStruct struct {
+	URL         string            `json:"URL"`
+	Method      string            `json:"Method"`
+	Body        string            `json:"Body"`
+	Headers     map[string]string `json:"Headers"`
+	RecordsPath string            `json:"RecordsPath"`
+	Timeout     int               `json:"Timeout"`
+	Auth        struct {
+		Type       string `json:"Type"`
+		HeaderName string `json:"HeaderName"`
+		TokenURL   string `json:"TokenURL"`
+		Scope      string `json:"Scope"`
+	} `json:"Auth"`
+	Pagination struct {
+		Type          string `json:"Type"`
+		NextLinkPath  string `json:"NextLinkPath"`
+		PageParam     string `json:"PageParam"`
+		StartPage     int    `json:"StartPage"`
+		PageSizeParam string `json:"PageSizeParam"`
+		PageSize      int    `json:"PageSize"`
+		OffsetParam   string `json:"OffsetParam"`
+		CursorPath    string `json:"CursorPath"`
+		CursorParam   string `json:"CursorParam"`
+		MaxPages      int    `json:"MaxPages"`
+	} `json:"Pagination"`
+}
 type csvConfStruct struct {
 	CarriageReturnRemoval bool   `json:"CarriageReturnRemoval"`
 	CommaCharacter        string `json:"CommaCharacter"`
@@ -146,6 +202,8 @@ type dbConfStruct struct {
 	Authentication string `json:"Authentication"`
 	Encrypt        bool   `json:"Encrypt"`
 	Query          string `json:"Query"`
+	SSLMode        string `json:"SSLMode"`
+	SearchPath     string `json:"SearchPath"`
 }
 type googleConfStruct struct {
 	Customer    string `json:"Customer"`
@@ -181,10 +239,26 @@ type assetTypesStruct struct {
 	PreserveSubState         bool                    `json:"PreserveSubState"`
 	Query                    string                  `json:"Query"`
 	SoftwareInventory        softwareInventoryStruct `json:"SoftwareInventory"`
+	SourceConfig             *sourceConfStruct       `json:"SourceConfig"`
+	Orphans                  *orphanConfStruct       `json:"Orphans"`
+	WatermarkColumn          string                  `json:"WatermarkColumn"`
+	Duplicates               *duplicatesConfStruct   `json:"Duplicates"`
 	Class                    string                  `json:"Class"`
 	TypeID                   int                     `json:"TypeID"`
 	Filters                  filtersStruct           `json:"Filters"`
 }
+type duplicatesConfStruct struct {
+	Policy string `json:"Policy"`
+	Column string `json:"Column"`
+}
+type orphanConfStruct struct {
+	Action           string  `json:"Action"`
+	OperationalState string  `json:"OperationalState"`
+	RecordState      string  `json:"RecordState"`
+	TagColumn        string  `json:"TagColumn"`
+	TagValue         string  `json:"TagValue"`
+	MaxPercent       float64 `json:"MaxPercent"`
+}
 type filtersStruct struct {
 	User                    string `json:"User"`
 	Model_Identifier        string `json:"ModelIdentifier"`
@@ -209,7 +283,6 @@ type softwareInventoryStruct struct {
 	ParentObject  string
 }
 type keyDataStruct struct {
-	AccessToken  string
 	APIEndpoint  string `json:"api_endpoint"`
 	APIKeyName   string `json:"apikeyname"`
 	APIKey       string `json:"apikey"`
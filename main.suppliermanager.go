@@ -9,14 +9,15 @@ import (
 	apiLib "github.com/hornbill/goApiLib"
 )
 
-func addSupplierToAsset(assetID, supplierID string, espXmlmc *apiLib.XmlmcInstStruct, buffer *bytes.Buffer) (err error) {
+// addSupplierToAsset -- associates a supplier with an asset. exists is returned true if the association was already in place
+func addSupplierToAsset(assetType assetTypesStruct, assetID, supplierID string, espXmlmc *apiLib.XmlmcInstStruct, buffer *bytes.Buffer) (exists bool, err error) {
 	espXmlmc.SetParam("supplierId", supplierID)
 	espXmlmc.SetParam("assetId", assetID)
 	XMLSTRING := espXmlmc.GetParam()
 	debugLog(buffer, "Add Supplier to Asset Create XML:", XMLSTRING)
 	if !configDryRun {
 
-		XMLUpdate, xmlmcErr := espXmlmc.Invoke("apps/com.hornbill.suppliermanager/SupplierAssets", "addSupplierAsset")
+		XMLUpdate, xmlmcErr := invokeRetry(espXmlmc, "apps/com.hornbill.suppliermanager/SupplierAssets", "addSupplierAsset", buffer)
 		if xmlmcErr != nil {
 			buffer.WriteString(loggerGen(1, "API Call XML: "+XMLSTRING))
 			err = errors.New("API Call failed when creating Supplier to Asset relationship record:" + xmlmcErr.Error())
@@ -38,8 +39,9 @@ func addSupplierToAsset(assetID, supplierID string, espXmlmc *apiLib.XmlmcInstSt
 		}
 		if xmlRespon.Params.Outcome != "success" {
 			if xmlRespon.Params.Outcome == "failure - the specified supplier asset already exists" {
-				counters.suppliersAssociatedSkipped++
+				incCounter(assetType.AssetType, counterSuppliersAssociatedSkipped)
 				buffer.WriteString(loggerGen(1, "Supplier Asset relationship already exists"))
+				exists = true
 				return
 			}
 			err = errors.New("Unable to create Supplier to Asset relationship record - unexpected outcome from SupplierAssets:addSupplierAsset: " + xmlRespon.Params.Outcome)
@@ -47,13 +49,12 @@ func addSupplierToAsset(assetID, supplierID string, espXmlmc *apiLib.XmlmcInstSt
 		}
 		debugLog(buffer, "Supplier to Asset relationship record successfully created: "+strconv.Itoa(xmlRespon.Params.SupplierAssetID))
 	}
-	mutexCounters.Lock()
-	counters.suppliersAssociatedSuccess++
-	mutexCounters.Unlock()
+	incCounter(assetType.AssetType, counterSuppliersAssociatedSuccess)
 	return
 }
 
-func addSupplierContractToAsset(assetID, contractID string, espXmlmc *apiLib.XmlmcInstStruct, buffer *bytes.Buffer) (err error) {
+// addSupplierContractToAsset -- associates a supplier contract with an asset. exists is returned true if the association was already in place
+func addSupplierContractToAsset(assetType assetTypesStruct, assetID, contractID string, espXmlmc *apiLib.XmlmcInstStruct, buffer *bytes.Buffer) (exists bool, err error) {
 	espXmlmc.SetParam("supplierContractId", contractID)
 	espXmlmc.SetParam("assetId", assetID)
 	XMLSTRING := espXmlmc.GetParam()
@@ -61,7 +62,7 @@ func addSupplierContractToAsset(assetID, contractID string, espXmlmc *apiLib.Xml
 
 	if !configDryRun {
 
-		XMLUpdate, xmlmcErr := espXmlmc.Invoke("apps/com.hornbill.suppliermanager/SupplierContractAssets", "addSupplierContractAsset")
+		XMLUpdate, xmlmcErr := invokeRetry(espXmlmc, "apps/com.hornbill.suppliermanager/SupplierContractAssets", "addSupplierContractAsset", buffer)
 		if xmlmcErr != nil {
 			buffer.WriteString(loggerGen(1, "API Call XML: "+XMLSTRING))
 			err = errors.New("API Call failed when creating Supplier Contract to Asset relationship record:" + xmlmcErr.Error())
@@ -83,8 +84,9 @@ func addSupplierContractToAsset(assetID, contractID string, espXmlmc *apiLib.Xml
 		}
 		if xmlRespon.Params.Outcome != "success" {
 			if xmlRespon.Params.Outcome == "failure - the specified supplier contract asset already exists" {
-				counters.supplierContractsAssociatedSkipped++
+				incCounter(assetType.AssetType, counterSupplierContractsAssociatedSkipped)
 				buffer.WriteString(loggerGen(1, "Supplier Asset Contract relationship already exists"))
+				exists = true
 				return
 			}
 			err = errors.New("Unable to create Supplier Contract to Asset relationship record - unexpected outcome from SupplierContractAssets:addSupplierContractAsset: " + xmlRespon.Params.Outcome)
@@ -92,8 +94,6 @@ func addSupplierContractToAsset(assetID, contractID string, espXmlmc *apiLib.Xml
 		}
 		debugLog(buffer, "Supplier Contract to Asset relationship record successfully created: "+strconv.Itoa(xmlRespon.Params.SupplierContractAssetID))
 	}
-	mutexCounters.Lock()
-	counters.supplierContractsAssociatedSuccess++
-	mutexCounters.Unlock()
+	incCounter(assetType.AssetType, counterSupplierContractsAssociatedSuccess)
 	return
 }
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Number of asset identifiers kept against each unresolved value, and the number of values per field output in the summary
+const (
+	unresolvedSampleSize  = 5
+	unresolvedSummarySize = 10
+)
+
+// unresolvedStruct -- a source value that could not be matched to a Hornbill user, site or group
+type unresolvedStruct struct {
+	Field        string   `json:"Field"`
+	Value        string   `json:"Value"`
+	Count        int      `json:"Count"`
+	AssetTypes   []string `json:"AssetTypes"`
+	SampleAssets []string `json:"SampleAssets"`
+}
+
+var (
+	unresolvedRefs  = make(map[string]*unresolvedStruct)
+	mutexUnresolved = &sync.Mutex{}
+)
+
+// trackReference -- records a user, site or group value from an asset that was not found in Hornbill.
+// Empty, cleared and shared values are ignored
+func trackReference(assetType, assetID, field, value string, resolved bool) {
+	if resolved || value == "" || value == "<nil>" || value == "__clear__" || value == "__sharedasset__" {
+		return
+	}
+	mutexUnresolved.Lock()
+	defer mutexUnresolved.Unlock()
+	//Lookups ignore case, so values differing only in case are the same reference
+	key := field + ":" + cacheKey(value)
+	ref, exists := unresolvedRefs[key]
+	if !exists {
+		ref = &unresolvedStruct{Field: field, Value: value}
+		unresolvedRefs[key] = ref
+	}
+	ref.Count++
+	found := false
+	for _, v := range ref.AssetTypes {
+		if v == assetType {
+			found = true
+			break
+		}
+	}
+	if !found {
+		ref.AssetTypes = append(ref.AssetTypes, assetType)
+	}
+	if len(ref.SampleAssets) < unresolvedSampleSize {
+		ref.SampleAssets = append(ref.SampleAssets, assetID)
+	}
+}
+
+// trackReferences -- records the site, group and user values of an asset that were not found in Hornbill. Users are
+// resolved when they have a URN, otherwise their ID holds the unmatched source value
+func trackReferences(assetType assetTypesStruct, assetID string, siteName string, siteID int, companyName, companyID, departmentName, departmentID string,
+	ownedByID, ownedByURN, usedByID, usedByURN, lastLoggedOnByID, lastLoggedOnByURN string) {
+	trackReference(assetType.AssetType, assetID, "h_site", siteName, siteID != 0)
+	trackReference(assetType.AssetType, assetID, "h_company_name", companyName, companyID != "")
+	trackReference(assetType.AssetType, assetID, "h_department_name", departmentName, departmentID != "")
+	trackReference(assetType.AssetType, assetID, "h_owned_by", ownedByID, ownedByURN != "")
+	trackReference(assetType.AssetType, assetID, "h_used_by", usedByID, usedByURN != "")
+	trackReference(assetType.AssetType, assetID, "h_last_logged_on_user", lastLoggedOnByID, lastLoggedOnByURN != "")
+}
+
+// checkReferences -- records the unresolved site, group and user values of an asset that is not updated, as its
+// record is unchanged. Values are only looked up in the caches, so nothing is auto created for an asset not written
+func checkReferences(assetType assetTypesStruct, assetID string, u map[string]interface{}, buffer *bytes.Buffer) {
+	siteName := getFieldValue("h_site", fmt.Sprintf("%v", importConf.AssetGenericFieldMapping["h_site"]), u, buffer)
+	_, siteFound := Sites.get(siteName)
+	trackReference(assetType.AssetType, assetID, "h_site", siteName, siteFound)
+	for _, group := range []struct {
+		column string
+		typeID int
+	}{{"h_company_name", 5}, {"h_department_name", 2}} {
+		groupName := getFieldValue(group.column, fmt.Sprintf("%v", importConf.AssetGenericFieldMapping[group.column]), u, buffer)
+		_, groupFound := Groups.get(group.typeID, groupName)
+		trackReference(assetType.AssetType, assetID, group.column, groupName, groupFound)
+	}
+	ownedByID, ownedByURN, _ := getUserID(u, "h_owned_by", buffer, false)
+	trackReference(assetType.AssetType, assetID, "h_owned_by", ownedByID, ownedByURN != "")
+	usedByID, usedByURN, _ := getUserID(u, "h_used_by", buffer, false)
+	trackReference(assetType.AssetType, assetID, "h_used_by", usedByID, usedByURN != "")
+	lastLoggedOnByID, lastLoggedOnByURN, _ := getUserID(u, "h_last_logged_on_user", buffer, true)
+	trackReference(assetType.AssetType, assetID, "h_last_logged_on_user", lastLoggedOnByID, lastLoggedOnByURN != "")
+}
+
+// getUnresolved -- returns the unresolved references, by field, then the most frequent first
+func getUnresolved() []unresolvedStruct {
+	mutexUnresolved.Lock()
+	defer mutexUnresolved.Unlock()
+	refs := make([]unresolvedStruct, 0, len(unresolvedRefs))
+	for _, ref := range unresolvedRefs {
+		refs = append(refs, *ref)
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Field != refs[j].Field {
+			return refs[i].Field < refs[j].Field
+		}
+		if refs[i].Count != refs[j].Count {
+			return refs[i].Count > refs[j].Count
+		}
+		return refs[i].Value < refs[j].Value
+	})
+	return refs
+}
+
+// logUnresolved -- outputs the most frequent unresolved values of each field, with sample asset identifiers
+func logUnresolved() {
+	refs := getUnresolved()
+	if len(refs) == 0 {
+		return
+	}
+	logger(3, "-=-=-= Unresolved References =-=-=-", true, true)
+	for i := 0; i < len(refs); {
+		field := refs[i].Field
+		j, assetCount := i, 0
+		for ; j < len(refs) && refs[j].Field == field; j++ {
+			assetCount += refs[j].Count
+		}
+		logger(3, field+": "+strconv.Itoa(j-i)+" value(s) not found in Hornbill, against "+strconv.Itoa(assetCount)+" asset(s)", true, true)
+		for k := i; k < j && k < i+unresolvedSummarySize; k++ {
+			logger(3, "  ["+refs[k].Value+"] "+strconv.Itoa(refs[k].Count)+" asset(s), e.g. "+strings.Join(refs[k].SampleAssets, ", "), true, true)
+		}
+		if j-i > unresolvedSummarySize {
+			logger(3, "  ... and "+strconv.Itoa(j-i-unresolvedSummarySize)+" more, see the -report file", true, true)
+		}
+		i = j
+	}
+}
@@ -32,13 +32,53 @@ type workspaceOneResponseStruct struct {
 	Total        int64                    `json:"Total"`
 }
 
-func getAssetsFromWorkspaceOne(assetType assetTypesStruct) (map[string]map[string]interface{}, error) {
-	//Initialise Asset Map
-	returnMap := make(map[string]map[string]interface{})
+// workspaceOneSource -- Source implementation for VMWare Workspace One UEM
+type workspaceOneSource struct {
+	conf        sourceConfStruct
+	key         keyDataStruct
+	accessToken string
+}
+
+func init() {
+	registerSource(func(conf sourceConfStruct, key keyDataStruct) Source {
+		return &workspaceOneSource{conf: conf, key: key}
+	}, "workspaceone")
+}
+
+// ValidateConfig -- Checks the Keysafe key holds the Workspace One UEM details
+func (s *workspaceOneSource) ValidateConfig() error {
+	if s.key.Domain == "" || s.key.Region == "" || s.key.ClientID == "" || s.key.ClientSecret == "" {
+		return errors.New("the Keysafe key must contain the domain, region, client_id and client_secret for Workspace One UEM")
+	}
+	return nil
+}
+
+// GetAssets -- Generates an access token, then pages through the devices matching the asset type filters
+func (s *workspaceOneSource) GetAssets(assetType assetTypesStruct) (map[string]map[string]interface{}, error) {
+	return collectAssets(s, assetType)
+}
+
+// StreamAssets -- Generates an access token, then pages through the devices matching the asset type filters,
+// emitting each device once its installed apps have been retrieved
+func (s *workspaceOneSource) StreamAssets(assetType assetTypesStruct, emit assetEmitter) error {
+	tokenObj, err := s.generateWorkspaceOneAccessToken()
+	if err != nil {
+		return err
+	}
+	s.accessToken = tokenObj.AccessToken
+	return s.getAssetsFromWorkspaceOne(assetType, emit)
+}
+
+// GetSoftwareInventory -- Returns the installed apps retrieved against the device record
+func (s *workspaceOneSource) GetSoftwareInventory(assetRecord map[string]interface{}, assetType assetTypesStruct, buffer *bytes.Buffer) (map[string]map[string]interface{}, string, error) {
+	return getSoftwareRecordsFromParentObject(assetRecord, assetType, "InstalledSoftware", buffer)
+}
+
+func (s *workspaceOneSource) getAssetsFromWorkspaceOne(assetType assetTypesStruct, emit assetEmitter) error {
 	logger(3, " ", false, false)
 	logger(3, "[WORKSPACEONE] Running VMWare Workspace One UEM query for "+assetType.AssetType+" assets. Please wait...", true, true)
 
-	pageURL := key.Domain + "/API/mdm/devices/search"
+	pageURL := s.key.Domain + "/API/mdm/devices/search"
 	filterAdded := false
 	v := reflect.ValueOf(assetType.Filters)
 	typeOfS := v.Type()
@@ -55,9 +95,9 @@ func getAssetsFromWorkspaceOne(assetType assetTypesStruct) (map[string]map[strin
 	}
 	pageNum := 0
 	for {
-		assetsList, err := getDevicesPageWorkspaceOne(assetType, pageURL, pageNum, filterAdded)
+		assetsList, err := s.getDevicesPageWorkspaceOne(assetType, pageURL, pageNum, filterAdded)
 		if err != nil {
-			return returnMap, err
+			return err
 		}
 		for _, v := range assetsList.Devices {
 			//Get the asset ID for the current record
@@ -72,31 +112,33 @@ func getAssetsFromWorkspaceOne(assetType assetTypesStruct) (map[string]map[strin
 			}
 			//Get installed software
 
-			v["InstalledSoftware"], err = getInstalledAppsWorkspaceOne(v["Uuid"].(string), assetType)
+			v["InstalledSoftware"], err = s.getInstalledAppsWorkspaceOne(v["Uuid"].(string), assetType)
 			if err != nil {
-				return returnMap, errors.New("error when retrieving apps list from Workspace One UEM: " + err.Error())
+				return errors.New("error when retrieving apps list from Workspace One UEM: " + err.Error())
+			}
+			if !emit(assetIdentifier, v) {
+				return nil
 			}
-			returnMap[assetIdentifier] = v
 		}
 		pageNum++
 		if len(assetsList.Devices) == 0 {
 			break
 		}
 	}
-	return returnMap, nil
+	return nil
 }
 
-func getInstalledAppsWorkspaceOne(deviceUUID string, assetType assetTypesStruct) ([]map[string]interface{}, error) {
+func (s *workspaceOneSource) getInstalledAppsWorkspaceOne(deviceUUID string, assetType assetTypesStruct) ([]map[string]interface{}, error) {
 	var (
 		installedApps []map[string]interface{}
 		err           error
 		pageNum       = 0
-		pageURL       = key.Domain + "/API/mdm/devices/" + deviceUUID + "/apps/search"
+		pageURL       = s.key.Domain + "/API/mdm/devices/" + deviceUUID + "/apps/search"
 	)
 	logger(3, "[WORKSPACEONE] Running VMWare Workspace One UEM query for apps installed on "+deviceUUID+". Please wait...", false, true)
 
 	for {
-		appsList, err := getAppsPageWorkspaceOne(assetType, pageURL, pageNum)
+		appsList, err := s.getAppsPageWorkspaceOne(assetType, pageURL, pageNum)
 		if err != nil {
 			return installedApps, err
 		}
@@ -109,20 +151,20 @@ func getInstalledAppsWorkspaceOne(deviceUUID string, assetType assetTypesStruct)
 	return installedApps, err
 }
 
-func getAppsPageWorkspaceOne(assetType assetTypesStruct, pageURL string, pageNum int) (appsResponse workspaceOneResponseStruct, err error) {
+func (s *workspaceOneSource) getAppsPageWorkspaceOne(assetType assetTypesStruct, pageURL string, pageNum int) (appsResponse workspaceOneResponseStruct, err error) {
 	currPageURL := pageURL + "?page=" + strconv.Itoa(pageNum)
 	logger(2, "Getting page of apps on from VMWare Workspace One UEM, URL: "+currPageURL, false, true)
-	req, err := http.NewRequest("GET", currPageURL, nil)
+	req, err := http.NewRequestWithContext(importCtx, "GET", currPageURL, nil)
 
 	if err != nil {
 		return
 	}
-	req.Header.Set("Authorization", "Bearer "+key.AccessToken)
+	req.Header.Set("Authorization", "Bearer "+s.accessToken)
 	req.Header.Set("User-Agent", appName+"/"+version)
 	req.Header.Set("Accept", "application/json;version=3")
 
 	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyFromEnvironment}, Timeout: 120 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := doRequestRetry(client, req, nil)
 	if err != nil {
 		return
 	}
@@ -148,7 +190,7 @@ func getAppsPageWorkspaceOne(assetType assetTypesStruct, pageURL string, pageNum
 	return
 }
 
-func getDevicesPageWorkspaceOne(assetType assetTypesStruct, pageURL string, pageNum int, filtered bool) (assetsResponse workspaceOneResponseStruct, err error) {
+func (s *workspaceOneSource) getDevicesPageWorkspaceOne(assetType assetTypesStruct, pageURL string, pageNum int, filtered bool) (assetsResponse workspaceOneResponseStruct, err error) {
 	currPageURL := pageURL
 	if filtered {
 		currPageURL += "&"
@@ -157,17 +199,17 @@ func getDevicesPageWorkspaceOne(assetType assetTypesStruct, pageURL string, page
 	}
 	currPageURL += "page=" + strconv.Itoa(pageNum)
 	logger(2, "Getting page of assets from VMWare Workspace One UEM, URL: "+currPageURL, false, true)
-	req, err := http.NewRequest("GET", currPageURL, nil)
+	req, err := http.NewRequestWithContext(importCtx, "GET", currPageURL, nil)
 
 	if err != nil {
 		return
 	}
-	req.Header.Set("Authorization", "Bearer "+key.AccessToken)
+	req.Header.Set("Authorization", "Bearer "+s.accessToken)
 	req.Header.Set("User-Agent", appName+"/"+version)
 	req.Header.Set("Accept", "application/json;version=3")
 
 	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyFromEnvironment}, Timeout: 120 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := doRequestRetry(client, req, nil)
 	if err != nil {
 		return
 	}
@@ -193,14 +235,19 @@ func getDevicesPageWorkspaceOne(assetType assetTypesStruct, pageURL string, page
 	return
 }
 
-func generateWorkspaceOneAccessToken() (tokenResponse workspaceOneTokenStruct, err error) {
-	formURL := "https://" + key.Region + ".uemauth.vmwservices.com/connect/token"
+func (s *workspaceOneSource) generateWorkspaceOneAccessToken() (tokenResponse workspaceOneTokenStruct, err error) {
+	formURL := "https://" + s.key.Region + ".uemauth.vmwservices.com/connect/token"
 	formPayload := url.Values{
 		"grant_type":    {"client_credentials"},
-		"client_id":     {key.ClientID},
-		"client_secret": {key.ClientSecret},
+		"client_id":     {s.key.ClientID},
+		"client_secret": {s.key.ClientSecret},
+	}
+	req, err := http.NewRequestWithContext(importCtx, "POST", formURL, strings.NewReader(formPayload.Encode()))
+	if err != nil {
+		return
 	}
-	resp, err := http.PostForm(formURL, formPayload)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := doRequestRetry(http.DefaultClient, req, nil)
 	if err != nil {
 		return
 	}